@@ -1,11 +1,7 @@
 package sdlang
 
 import (
-	"bytes"
-	"encoding/base64"
 	"errors"
-	"fmt"
-	"strconv"
 	"time"
 )
 
@@ -22,11 +18,43 @@ const (
 	tBinary
 )
 
+// SdlDebugLocation is where something is within a document. The locations of tags, values, and attributes are spans,
+// which start at the location and finish at End.
 type SdlDebugLocation struct {
-	File       string
-	Line       string
+	File string
+
+	// Line is the text of the line the location is on.
+	Line string
+
+	// Loc is the 0-based column within Line, counted in runes rather than bytes.
 	Loc        int
 	LineNumber int
+
+	// Offset is the 0-based byte offset of the location within the document.
+	Offset int
+
+	// End is where the span ends, exclusive. A tag ends after its last value or attribute, or after its closing brace
+	// if it has one. An attribute's span only covers its name, as its value has a location of its own.
+	// End is the zero value if the location isn't a span.
+	End SdlPosition
+}
+
+// SdlPosition is a position within a document, such as the end of an SdlDebugLocation.
+type SdlPosition struct {
+	// Offset is the 0-based byte offset within the document.
+	Offset int
+
+	// LineNumber is the 1-based line number.
+	LineNumber int
+
+	// Loc is the 0-based column within the line, counted in runes rather than bytes.
+	Loc int
+}
+
+// Generates a fancy error message that points at this location.
+// The returned error is an *SdlError.
+func (l SdlDebugLocation) NewError(msg string) error {
+	return &SdlError{Location: l, Message: msg}
 }
 
 // SdlValue is a tagged union for every possible type representable in SDLang.
@@ -158,7 +186,7 @@ func (v SdlValue) Int() (int64, error) {
 	return v.vInt, nil
 }
 func (v SdlValue) Float() (float64, error) {
-	if !v.IsString() {
+	if !v.IsFloat() {
 		return 0, errors.New("this value is not a float")
 	}
 	return v.vFloat, nil
@@ -221,132 +249,62 @@ func (t SdlTag) ForEachChildByNamespace(namespace string, f func(child *SdlTag))
 // Using the given SaxParser, an AST is constructed.
 // The returned value contains the root tag, which is nameless and only contains children.
 func (p SaxParser) ParseIntoAst() (SdlTag, error) {
-	var currTagStack []SdlTag
-	currTagStack = append(currTagStack, SdlTag{})
-
-	prevWasNewLine := true
-	for {
-		err := p.Next()
-		if err != nil {
-			return SdlTag{}, err
-		}
-		if p.IsEof() {
-			break
-		}
+	b := astBuilder{parser: &p, stack: []SdlTag{{}}}
+	err := p.Parse(&b)
+	if err != nil {
+		return SdlTag{}, err
+	}
+	return b.stack[0], nil
+}
 
-		dline, dloc, dln := p.getLine(p.cursor)
-		dbg := SdlDebugLocation{File: p.FileName, Line: dline, Loc: dloc, LineNumber: dln}
-
-		if p.IsTagName() {
-			if !prevWasNewLine {
-				return SdlTag{}, p.NewError(0, "(probably a bug) Tag names can only appear at the start of new lines.")
-			}
-			var tag SdlTag
-			tag.Name = p.Text()
-			tag.Namespace = p.AdditionalText()
-			tag.QualifiedName = p.AdditionalText() + ":" + p.Text()
-			tag.DebugLocation = dbg
-			if tag.QualifiedName[0] == ':' {
-				tag.QualifiedName = tag.QualifiedName[1:]
-			}
-			currTagStack = append(currTagStack, tag)
-			prevWasNewLine = false
-		} else if p.IsAttributeName() {
-			var attr SdlAttribute
-			attr.Name = p.Text()
-			attr.Namespace = p.AdditionalText()
-			attr.QualifiedName = p.AdditionalText() + ":" + p.Text()
-			attr.DebugLocation = dbg
-			if attr.QualifiedName[0] == ':' {
-				attr.QualifiedName = attr.QualifiedName[1:]
-			}
-			err = p.Next()
-			if err != nil {
-				return SdlTag{}, err
-			}
-			handleValue(&attr.Value, &p)
-
-			if currTagStack[len(currTagStack)-1].Attributes == nil {
-				currTagStack[len(currTagStack)-1].Attributes = map[string]SdlAttribute{}
-			}
-			currTagStack[len(currTagStack)-1].Attributes[attr.QualifiedName] = attr
-			prevWasNewLine = false
-		} else if p.IsNewLine() {
-			if !prevWasNewLine {
-				parent := &currTagStack[len(currTagStack)-2]
-				child := currTagStack[len(currTagStack)-1]
-				parent.Children = append(parent.Children, child)
-				currTagStack = currTagStack[0 : len(currTagStack)-1]
-			}
-			prevWasNewLine = true
-		} else if p.IsOpenTag() {
-			if prevWasNewLine {
-				return SdlTag{}, p.NewError(0, "Opening braces have to be on the same line as a tag.")
-			}
-
-			prevWasNewLine = true
-			err = p.Next()
-			if err != nil {
-				return SdlTag{}, err
-			}
-			if !p.IsNewLine() {
-				return SdlTag{}, p.NewError(0, "Expected a new line following opening brace.")
-			}
-		} else if p.IsCloseTag() {
-			if !prevWasNewLine {
-				return SdlTag{}, p.NewError(0, "Closing braces must be on their own line.")
-			}
-
-			err = p.Next()
-			if err != nil {
-				return SdlTag{}, err
-			}
-			if !p.IsNewLine() && !p.IsEof() {
-				return SdlTag{}, p.NewError(0, "Expected a new line or end of file following closing brace.")
-			}
-			parent := &currTagStack[len(currTagStack)-2]
-			child := currTagStack[len(currTagStack)-1]
-			parent.Children = append(parent.Children, child)
-			currTagStack = currTagStack[0 : len(currTagStack)-1]
-			prevWasNewLine = true
-		} else {
-			var val SdlValue
-			val.DebugLocation = dbg
-			handleValue(&val, &p)
-			currTagStack[len(currTagStack)-1].Values = append(currTagStack[len(currTagStack)-1].Values, val)
-			prevWasNewLine = false
-		}
+// ParseIntoAstTolerant is the same as ParseIntoAst, except that it uses ParseTolerant to recover from syntax errors.
+// The returned tag contains everything that could be parsed, along with every error that was found.
+func (p SaxParser) ParseIntoAstTolerant() (SdlTag, []error) {
+	b := astBuilder{parser: &p, stack: []SdlTag{{}}}
+	errs := p.ParseTolerant(&b)
+	// Parsing may have stopped early (e.g. due to Options), so anything still open is kept as-is.
+	for len(b.stack) > 1 {
+		b.EndTag()
 	}
+	return b.stack[0], errs
+}
+
+// astBuilder is a SaxHandler that constructs an AST.
+type astBuilder struct {
+	parser *SaxParser
+	stack  []SdlTag
+}
+
+func (b *astBuilder) StartTag(namespace, name string) error {
+	tag := NewTag(namespace, name)
+	tag.DebugLocation = b.parser.TokenLocation()
+	b.stack = append(b.stack, tag)
+	return nil
+}
+
+func (b *astBuilder) Value(value SdlValue) error {
+	top := &b.stack[len(b.stack)-1]
+	top.Values = append(top.Values, value)
+	return nil
+}
 
-	return currTagStack[0], nil
-}
-
-func handleValue(v *SdlValue, p *SaxParser) {
-	if p.IsBinary() {
-		v.tag = tBinary
-		base64.NewDecoder(base64.RawStdEncoding, bytes.NewBufferString(p.Text())).Read(v.vBinary)
-	} else if p.IsBool() {
-		v.tag = tBool
-		v.vBool = p.Bool()
-	} else if p.IsDate() || p.IsDateTime() {
-		v.tag = tDateTime
-		v.vDateTime = p.Time()
-	} else if p.IsDouble() || p.IsFloat() {
-		v.tag = tFloat
-		v.vFloat, _ = strconv.ParseFloat(p.Text(), 64)
-	} else if p.IsInteger() || p.IsLong() {
-		v.tag = tInt
-		v.vInt, _ = strconv.ParseInt(p.Text(), 10, 64)
-	} else if p.IsNull() {
-		v.tag = tNull
-	} else if p.IsString() {
-		v.tag = tString
-		v.vString = p.Text()
-	} else if p.IsTimeSpan() {
-		v.tag = tTimeSpan
-		v.vTimeSpan = p.TimeSpan()
-	} else {
-		fmt.Printf("p: %v\n", p.t)
-		panic("bug: this error should've been caught earlier on")
+func (b *astBuilder) Attribute(attr SdlAttribute) error {
+	top := &b.stack[len(b.stack)-1]
+	if top.Attributes == nil {
+		top.Attributes = map[string]SdlAttribute{}
 	}
+	top.Attributes[attr.QualifiedName] = attr
+	return nil
+}
+
+func (b *astBuilder) EndTag() error {
+	b.stack[len(b.stack)-1].DebugLocation.End = b.parser.positionAt(b.parser.tagEnd)
+	parent := &b.stack[len(b.stack)-2]
+	parent.Children = append(parent.Children, b.stack[len(b.stack)-1])
+	b.stack = b.stack[:len(b.stack)-1]
+	return nil
+}
+
+func (b *astBuilder) Comment(text string) error {
+	return nil
 }
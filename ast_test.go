@@ -6,6 +6,13 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+func parseForTest(t *testing.T, fileName, code string) SdlTag {
+	p := SaxParser{Input: code, FileName: fileName}
+	ast, err := p.ParseIntoAst()
+	assert.NoError(t, err)
+	return ast
+}
+
 func TestAst(t *testing.T) {
 	code := `# a tag having only a name
 	my_tag
@@ -102,3 +109,79 @@ func TestAst(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "Akiko", s)
 }
+
+func TestAstLastTagWithoutNewLine(t *testing.T) {
+	p := SaxParser{Input: "a 1\nb 2"}
+	ast, err := p.ParseIntoAst()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(ast.Children))
+	assert.Equal(t, "b", ast.Children[1].Name)
+}
+
+func TestAstKeywordAtLineStart(t *testing.T) {
+	ast := parseForTest(t, "", "true\nnull 1\n")
+	first := NewTag("", "content")
+	first.AddValue(Bool(true))
+	second := NewTag("", "content")
+	second.AddValue(Null(), Int(1))
+	expected := NewTag("", "")
+	expected.AddChild(first, second)
+	assert.True(t, expected.Equal(ast), FormatChanges(Diff(&expected, &ast)))
+}
+
+func TestAstAnonymousTagAfterNamespace(t *testing.T) {
+	ast := parseForTest(t, "", "ns:a\n\"b\"\n")
+	assert.Equal(t, 2, len(ast.Children))
+	assert.Equal(t, "", ast.Children[1].Namespace)
+	assert.Equal(t, "content", ast.Children[1].QualifiedName)
+}
+
+func TestAstSpans(t *testing.T) {
+	input := "ns:a 1 \"é\" key=true {\n\tb\n\t\"anon\" 2\n}\ntrue\n"
+	ast := parseForTest(t, "", input)
+	span := func(loc SdlDebugLocation) string {
+		return input[loc.Offset:loc.End.Offset]
+	}
+
+	a := ast.Children[0]
+	assert.Equal(t, "ns:a 1 \"é\" key=true {\n\tb\n\t\"anon\" 2\n}", span(a.DebugLocation))
+	assert.Equal(t, SdlPosition{Offset: 37, LineNumber: 4, Loc: 1}, a.DebugLocation.End)
+	assert.Equal(t, "1", span(a.Values[0].DebugLocation))
+	assert.Equal(t, "\"é\"", span(a.Values[1].DebugLocation))
+	assert.Equal(t, 10, a.Values[1].DebugLocation.End.Loc, "columns are counted in runes")
+	assert.Equal(t, "key", span(a.Attributes["key"].DebugLocation))
+	assert.Equal(t, "true", span(a.Attributes["key"].Value.DebugLocation))
+
+	b := a.Children[0]
+	assert.Equal(t, "b", span(b.DebugLocation))
+	assert.Equal(t, 2, b.DebugLocation.LineNumber)
+	assert.Equal(t, 1, b.DebugLocation.Loc)
+	assert.Equal(t, "\"anon\" 2", span(a.Children[1].DebugLocation))
+	assert.Equal(t, "true", span(ast.Children[1].DebugLocation))
+}
+
+func TestAstTolerant(t *testing.T) {
+	p := SaxParser{Input: "a 1 \"unterminated\nb {\n\tc 2.2.2\n\td 4\n", FileName: "test.sdl"}
+	ast, errs := p.ParseIntoAstTolerant()
+
+	a := NewTag("", "a")
+	a.AddValue(Int(1))
+	d := NewTag("", "d")
+	d.AddValue(Int(4))
+	b := NewTag("", "b")
+	b.AddChild(NewTag("", "c"), d)
+	expected := NewTag("", "")
+	expected.AddChild(a, b)
+	assert.True(t, expected.Equal(ast), FormatChanges(Diff(&expected, &ast)))
+
+	var lines []int
+	for _, err := range errs {
+		lines = append(lines, err.(*SdlError).Location.LineNumber)
+	}
+	assert.Equal(t, []int{1, 3, 5}, lines)
+
+	p = SaxParser{Input: "a 1\nb 2\nc 3\n", Options: ParseOptions{MaxTags: 2}}
+	ast, errs = p.ParseIntoAstTolerant()
+	assert.Equal(t, 2, len(ast.Children))
+	assert.Equal(t, 1, len(errs))
+}
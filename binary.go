@@ -0,0 +1,561 @@
+package sdlang
+
+import (
+	binenc "encoding/binary" // Aliased, as `binary` is a token type.
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// The binary encoding starts with binaryMagic, a version byte, and a flags byte. This is followed by a string table,
+// which is a count and then each string, and then the root tag.
+//
+// Each tag is its namespace and name (as indexes into the string table), its debug location if binaryFlagLocations is set,
+// the count of its values followed by each value, the count of its attributes followed by each attribute's
+// namespace, name, debug location, and value, and finally the count of its children. Its children immediately follow it.
+//
+// Each value is a kind byte (see binaryNull and friends) followed by its content, and then its debug location.
+// Debug locations are a file and line (as indexes into the string table), then a column and a line number. If binaryFlagSpans
+// is set, these are followed by the location's offset, and its end's offset, line number, and column.
+//
+// Counts, lengths, and indexes are unsigned varints, and every other integer is a signed varint.
+const (
+	binaryMagic   = "SDLB"
+	binaryVersion = 1
+
+	binaryFlagLocations = 1 << 0
+	binaryFlagSpans     = 1 << 1 // Only valid along with binaryFlagLocations.
+)
+
+// The kind of each value in the binary encoding. These must never change, as they're part of the format.
+const (
+	binaryNull     = 0 // No content.
+	binaryString   = 1 // Length, then UTF-8 bytes.
+	binaryInt      = 2 // Varint.
+	binaryFloat    = 3 // IEEE 754 bits as 8 little endian bytes.
+	binaryDateTime = 4 // Unix seconds, nanoseconds, zone offset in seconds, then the zone's name in the string table.
+	binaryTimeSpan = 5 // Nanoseconds.
+	binaryFalse    = 6 // No content.
+	binaryTrue     = 7 // No content.
+	binaryBinary   = 8 // Length, then bytes.
+)
+
+// BinaryOptions configures EncodeBinary.
+type BinaryOptions struct {
+	// DebugLocations keeps the debug location of every tag, attribute, and value, which makes the output larger.
+	DebugLocations bool
+}
+
+// EncodeBinary encodes `tag`, which is usually the root tag from ParseIntoAst, into a compact binary form that can be
+// decoded much faster than parsing text. Names are stored once in a string table, and every value keeps its exact type
+// and content. See DecodeBinary.
+func EncodeBinary(tag *SdlTag, opts BinaryOptions) []byte {
+	e := binaryEncoder{opts: opts, indexes: map[string]uint64{}}
+	e.tag(tag)
+
+	flags := byte(0)
+	if opts.DebugLocations {
+		flags |= binaryFlagLocations | binaryFlagSpans
+	}
+	out := append([]byte(binaryMagic), binaryVersion, flags)
+	out = appendUvarint(out, uint64(len(e.table)))
+	for _, s := range e.table {
+		out = appendUvarint(out, uint64(len(s)))
+		out = append(out, s...)
+	}
+	return append(out, e.body...)
+}
+
+// ParseIntoBinary parses the input into an AST, and returns the result of EncodeBinary for its root tag.
+func (p SaxParser) ParseIntoBinary(opts BinaryOptions) ([]byte, error) {
+	root, err := p.ParseIntoAst()
+	if err != nil {
+		return nil, err
+	}
+	return EncodeBinary(&root, opts), nil
+}
+
+// BinaryToText decodes the output of EncodeBinary and writes it as SDLang text, with one tag per line and tabs for indentation.
+//
+// Text is less precise than the binary encoding, so datetimes and timespans are truncated to milliseconds, and time zones are
+// written as their offset. An error is returned for anything that can't be written as text, such as a tag without a name,
+// a name that isn't a valid identifier, a float that's infinite or NaN, or a datetime whose year isn't between 0 and 9999.
+func BinaryToText(data []byte, limits ParseOptions) (string, error) {
+	root, err := DecodeBinary(data, limits)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	for i := range root.Children {
+		if err = writeTagText(&b, &root.Children[i], 0); err != nil {
+			return "", err
+		}
+	}
+	return b.String(), nil
+}
+
+func appendUvarint(out []byte, value uint64) []byte {
+	var buf [binenc.MaxVarintLen64]byte
+	return append(out, buf[:binenc.PutUvarint(buf[:], value)]...)
+}
+
+func appendVarint(out []byte, value int64) []byte {
+	var buf [binenc.MaxVarintLen64]byte
+	return append(out, buf[:binenc.PutVarint(buf[:], value)]...)
+}
+
+type binaryEncoder struct {
+	opts    BinaryOptions
+	body    []byte
+	table   []string
+	indexes map[string]uint64
+}
+
+func (e *binaryEncoder) uvarint(value uint64) {
+	e.body = appendUvarint(e.body, value)
+}
+
+func (e *binaryEncoder) varint(value int64) {
+	e.body = appendVarint(e.body, value)
+}
+
+func (e *binaryEncoder) bytes(value []byte) {
+	e.uvarint(uint64(len(value)))
+	e.body = append(e.body, value...)
+}
+
+// stringRef writes the index of `s` in the string table, adding it if it isn't there already.
+func (e *binaryEncoder) stringRef(s string) {
+	index, ok := e.indexes[s]
+	if !ok {
+		index = uint64(len(e.table))
+		e.indexes[s] = index
+		e.table = append(e.table, s)
+	}
+	e.uvarint(index)
+}
+
+func (e *binaryEncoder) location(l SdlDebugLocation) {
+	if !e.opts.DebugLocations {
+		return
+	}
+	e.stringRef(l.File)
+	e.stringRef(l.Line)
+	e.varint(int64(l.Loc))
+	e.varint(int64(l.LineNumber))
+	e.varint(int64(l.Offset))
+	e.varint(int64(l.End.Offset))
+	e.varint(int64(l.End.LineNumber))
+	e.varint(int64(l.End.Loc))
+}
+
+func (e *binaryEncoder) tag(t *SdlTag) {
+	e.stringRef(t.Namespace)
+	e.stringRef(t.Name)
+	e.location(t.DebugLocation)
+
+	e.uvarint(uint64(len(t.Values)))
+	for _, value := range t.Values {
+		e.value(value)
+	}
+
+	e.uvarint(uint64(len(t.Attributes)))
+	for _, key := range t.sortedAttributeKeys() {
+		attr := t.Attributes[key]
+		e.stringRef(attr.Namespace)
+		e.stringRef(attr.Name)
+		e.location(attr.DebugLocation)
+		e.value(attr.Value)
+	}
+
+	e.uvarint(uint64(len(t.Children)))
+	for i := range t.Children {
+		e.tag(&t.Children[i])
+	}
+}
+
+func (e *binaryEncoder) value(v SdlValue) {
+	switch v.tag {
+	case tString:
+		e.body = append(e.body, binaryString)
+		e.bytes([]byte(v.vString))
+	case tInt:
+		e.body = append(e.body, binaryInt)
+		e.varint(v.vInt)
+	case tFloat:
+		e.body = append(e.body, binaryFloat)
+		var buf [8]byte
+		binenc.LittleEndian.PutUint64(buf[:], math.Float64bits(v.vFloat))
+		e.body = append(e.body, buf[:]...)
+	case tDateTime:
+		e.body = append(e.body, binaryDateTime)
+		name, offset := v.vDateTime.Zone()
+		e.varint(v.vDateTime.Unix())
+		e.uvarint(uint64(v.vDateTime.Nanosecond()))
+		e.varint(int64(offset))
+		e.stringRef(name)
+	case tTimeSpan:
+		e.body = append(e.body, binaryTimeSpan)
+		e.varint(int64(v.vTimeSpan))
+	case tBool:
+		if v.vBool {
+			e.body = append(e.body, binaryTrue)
+		} else {
+			e.body = append(e.body, binaryFalse)
+		}
+	case tBinary:
+		e.body = append(e.body, binaryBinary)
+		e.bytes(v.vBinary)
+	default:
+		e.body = append(e.body, binaryNull)
+	}
+	e.location(v.DebugLocation)
+}
+
+// DecodeBinary decodes the output of EncodeBinary. The data is untrusted, so it's fully validated, and `limits` are applied
+// in the same way as when parsing text. Like the parser, decoding never recurses no matter how deeply tags are nested.
+func DecodeBinary(data []byte, limits ParseOptions) (SdlTag, error) {
+	if len(data) < len(binaryMagic)+2 || string(data[:len(binaryMagic)]) != binaryMagic {
+		return SdlTag{}, errors.New("data is not binary SDLang")
+	}
+	version, flags := data[len(binaryMagic)], data[len(binaryMagic)+1]
+	if version != binaryVersion {
+		return SdlTag{}, fmt.Errorf("unsupported binary SDLang version %d", version)
+	}
+	if flags&^(binaryFlagLocations|binaryFlagSpans) != 0 || flags == binaryFlagSpans {
+		return SdlTag{}, fmt.Errorf("unsupported binary SDLang flags %#x", flags)
+	}
+
+	d := binaryDecoder{
+		data:      data,
+		pos:       len(binaryMagic) + 2,
+		locations: flags&binaryFlagLocations != 0,
+		spans:     flags&binaryFlagSpans != 0,
+		limits:    limits,
+	}
+	count := d.count()
+	for i := 0; i < count && d.err == nil; i++ {
+		d.table = append(d.table, string(d.bytes()))
+	}
+
+	type frame struct {
+		tag      SdlTag
+		children int
+	}
+	root, children := d.tagHeader()
+	stack := []frame{{root, children}}
+	tags := 0
+	for d.err == nil {
+		top := &stack[len(stack)-1]
+		if top.children == 0 {
+			if len(stack) == 1 {
+				break
+			}
+			stack = stack[:len(stack)-1]
+			parent := &stack[len(stack)-1].tag
+			parent.Children = append(parent.Children, top.tag)
+			continue
+		}
+		top.children--
+
+		tags++
+		if limits.MaxTags > 0 && tags > limits.MaxTags {
+			d.fail(fmt.Sprintf("there are more than the maximum allowed %d tags", limits.MaxTags))
+		} else if limits.MaxDepth > 0 && len(stack) > limits.MaxDepth {
+			d.fail(fmt.Sprintf("tags are nested more deeply than the maximum allowed %d", limits.MaxDepth))
+		}
+		child, children := d.tagHeader()
+		stack = append(stack, frame{child, children})
+	}
+
+	if d.err == nil && d.pos != len(d.data) {
+		d.fail("unexpected data after the root tag")
+	}
+	if d.err != nil {
+		return SdlTag{}, d.err
+	}
+	return stack[0].tag, nil
+}
+
+// binaryDecoder reads from `data`. The first problem is kept in `err`, after which every read returns a zero value.
+type binaryDecoder struct {
+	data      []byte
+	pos       int
+	table     []string
+	locations bool
+	spans     bool
+	limits    ParseOptions
+	err       error
+}
+
+func (d *binaryDecoder) fail(msg string) {
+	if d.err == nil {
+		d.err = fmt.Errorf("invalid binary SDLang at byte %d: %s", d.pos, msg)
+	}
+}
+
+func (d *binaryDecoder) readByte() byte {
+	if d.err != nil {
+		return 0
+	}
+	if d.pos >= len(d.data) {
+		d.fail("unexpected end of data")
+		return 0
+	}
+	d.pos++
+	return d.data[d.pos-1]
+}
+
+func (d *binaryDecoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	value, n := binenc.Uvarint(d.data[d.pos:])
+	if n <= 0 {
+		d.fail("invalid varint")
+		return 0
+	}
+	d.pos += n
+	return value
+}
+
+func (d *binaryDecoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	value, n := binenc.Varint(d.data[d.pos:])
+	if n <= 0 {
+		d.fail("invalid varint")
+		return 0
+	}
+	d.pos += n
+	return value
+}
+
+// count reads the number of items that follow. Every item takes at least one byte, so a count larger than
+// the remaining data must be invalid, which prevents huge allocations.
+func (d *binaryDecoder) count() int {
+	value := d.uvarint()
+	if value > uint64(len(d.data)-d.pos) {
+		d.fail("count is larger than the remaining data")
+		return 0
+	}
+	return int(value)
+}
+
+func (d *binaryDecoder) bytes() []byte {
+	length := d.count()
+	if d.limits.MaxTokenLength > 0 && length > d.limits.MaxTokenLength {
+		d.fail(fmt.Sprintf("length is more than the maximum allowed %d bytes", d.limits.MaxTokenLength))
+	}
+	if d.err != nil {
+		return nil
+	}
+	d.pos += length
+	return d.data[d.pos-length : d.pos : d.pos]
+}
+
+func (d *binaryDecoder) stringRef() string {
+	index := d.uvarint()
+	if d.err != nil {
+		return ""
+	}
+	if index >= uint64(len(d.table)) {
+		d.fail("string index is out of range")
+		return ""
+	}
+	return d.table[index]
+}
+
+func (d *binaryDecoder) int() int {
+	value := d.varint()
+	if int64(int(value)) != value {
+		d.fail("integer is out of range")
+		return 0
+	}
+	return int(value)
+}
+
+func (d *binaryDecoder) location() SdlDebugLocation {
+	if !d.locations {
+		return SdlDebugLocation{}
+	}
+	l := SdlDebugLocation{File: d.stringRef(), Line: d.stringRef(), Loc: d.int(), LineNumber: d.int()}
+	if d.spans {
+		l.Offset = d.int()
+		l.End = SdlPosition{Offset: d.int(), LineNumber: d.int(), Loc: d.int()}
+	}
+	return l
+}
+
+// tagHeader reads everything about a tag except its children, and returns how many children follow it.
+func (d *binaryDecoder) tagHeader() (SdlTag, int) {
+	tag := NewTag(d.stringRef(), d.stringRef())
+	tag.DebugLocation = d.location()
+
+	values := d.count()
+	if d.limits.MaxValues > 0 && values > d.limits.MaxValues {
+		d.fail(fmt.Sprintf("a tag has more than the maximum allowed %d values", d.limits.MaxValues))
+	}
+	for i := 0; i < values && d.err == nil; i++ {
+		tag.Values = append(tag.Values, d.value())
+	}
+
+	attributes := d.count()
+	if d.limits.MaxAttributes > 0 && attributes > d.limits.MaxAttributes {
+		d.fail(fmt.Sprintf("a tag has more than the maximum allowed %d attributes", d.limits.MaxAttributes))
+	}
+	for i := 0; i < attributes && d.err == nil; i++ {
+		attr := NewAttribute(d.stringRef(), d.stringRef(), SdlValue{})
+		attr.DebugLocation = d.location()
+		attr.Value = d.value()
+		if tag.Attributes == nil {
+			tag.Attributes = map[string]SdlAttribute{}
+		}
+		if _, exists := tag.Attributes[attr.QualifiedName]; exists {
+			d.fail("duplicate attribute " + attr.QualifiedName)
+		}
+		tag.Attributes[attr.QualifiedName] = attr
+	}
+
+	return tag, d.count()
+}
+
+func (d *binaryDecoder) value() SdlValue {
+	var v SdlValue
+	switch kind := d.readByte(); kind {
+	case binaryNull:
+		v = Null()
+	case binaryString:
+		v = String(string(d.bytes()))
+	case binaryInt:
+		v = Int(d.varint())
+	case binaryFloat:
+		if len(d.data)-d.pos < 8 {
+			d.fail("unexpected end of data")
+			break
+		}
+		v = Float(math.Float64frombits(binenc.LittleEndian.Uint64(d.data[d.pos:])))
+		d.pos += 8
+	case binaryDateTime:
+		seconds := d.varint()
+		nanoseconds := d.uvarint()
+		offset := d.int()
+		name := d.stringRef()
+		if nanoseconds >= uint64(time.Second) {
+			d.fail("datetime has too many nanoseconds")
+			break
+		}
+		zone := time.UTC
+		if name != "UTC" || offset != 0 {
+			zone = time.FixedZone(name, offset)
+		}
+		v = DateTime(time.Unix(seconds, int64(nanoseconds)).In(zone))
+	case binaryTimeSpan:
+		v = TimeSpan(time.Duration(d.varint()))
+	case binaryFalse, binaryTrue:
+		v = Bool(kind == binaryTrue)
+	case binaryBinary:
+		v = Binary(append([]byte{}, d.bytes()...))
+	default:
+		d.fail(fmt.Sprintf("unknown value kind %d", kind))
+	}
+	v.DebugLocation = d.location()
+	return v
+}
+
+// writeTagText writes `tag` and its children as SDLang text, indented by `depth` tabs.
+func writeTagText(b *strings.Builder, tag *SdlTag, depth int) error {
+	if !isTextName(tag.Namespace, tag.Name) {
+		return fmt.Errorf("tag name %q can't be written as text", tag.QualifiedName)
+	}
+	b.WriteString(strings.Repeat("\t", depth))
+	b.WriteString(qualifiedName(tag.Namespace, tag.Name))
+
+	for _, value := range tag.Values {
+		text, err := valueText(value)
+		if err != nil {
+			return fmt.Errorf("tag %s: %w", tag.QualifiedName, err)
+		}
+		b.WriteByte(' ')
+		b.WriteString(text)
+	}
+	for _, key := range tag.sortedAttributeKeys() {
+		attr := tag.Attributes[key]
+		if !isTextName(attr.Namespace, attr.Name) {
+			return fmt.Errorf("attribute name %q can't be written as text", attr.QualifiedName)
+		}
+		text, err := valueText(attr.Value)
+		if err != nil {
+			return fmt.Errorf("attribute %s: %w", attr.QualifiedName, err)
+		}
+		b.WriteByte(' ')
+		b.WriteString(qualifiedName(attr.Namespace, attr.Name))
+		b.WriteByte('=')
+		b.WriteString(text)
+	}
+
+	if len(tag.Children) == 0 {
+		b.WriteByte('\n')
+		return nil
+	}
+	b.WriteString(" {\n")
+	for i := range tag.Children {
+		if err := writeTagText(b, &tag.Children[i], depth+1); err != nil {
+			return err
+		}
+	}
+	b.WriteString(strings.Repeat("\t", depth))
+	b.WriteString("}\n")
+	return nil
+}
+
+func valueText(v SdlValue) (string, error) {
+	if v.tag == tFloat && (math.IsInf(v.vFloat, 0) || math.IsNaN(v.vFloat)) {
+		return "", fmt.Errorf("float %v can't be written as text", v.vFloat)
+	}
+	if v.tag == tDateTime {
+		// The parser only reads four digit years, and time zone offsets of less than a day.
+		_, offset := v.vDateTime.Zone()
+		if year := v.vDateTime.Year(); year < 0 || year > 9999 {
+			return "", fmt.Errorf("datetime with the year %d can't be written as text", year)
+		} else if offset <= -24*60*60 || offset >= 24*60*60 {
+			return "", fmt.Errorf("datetime with a time zone offset of %ds can't be written as text", offset)
+		}
+	}
+	return v.literal(), nil
+}
+
+// isTextName returns true if the qualified name would be parsed as a name, rather than as something else such as a keyword.
+// The name following a namespace may be a keyword, as in "ns:true".
+func isTextName(namespace, name string) bool {
+	first := name
+	if namespace != "" {
+		first = namespace
+		if !isIdentifier(name) {
+			return false
+		}
+	}
+
+	switch first {
+	case "true", "false", "on", "off", "null":
+		return false
+	}
+	return isIdentifier(first)
+}
+
+// isIdentifier returns true if `text` is made of identifier characters, and starts with one that can start an identifier.
+func isIdentifier(text string) bool {
+	if text == "" {
+		return false
+	}
+	for i, r := range text {
+		if (i == 0 && !isIdentifierStart(r)) || !isIdentifierContinue(r) {
+			return false
+		}
+	}
+	return true
+}
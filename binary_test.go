@@ -0,0 +1,202 @@
+package sdlang
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const binaryTestInput = `# Every kind of value.
+values null "str" 'c' 123 -9223372036854775808 4.5 2005/12/05 2005/12/05 14:12:23.345-GMT+01:30 -1d:02:03:04.500 true off [AQID]
+ns:tag ns:attr="a" attr=1 {
+	child 1 {
+		grandchild
+	}
+	"anonymous"
+}
+`
+
+func TestBinaryRoundTrip(t *testing.T) {
+	p := SaxParser{Input: binaryTestInput, FileName: "test.sdl"}
+	expected, err := p.ParseIntoAst()
+	assert.NoError(t, err)
+
+	for _, opts := range []BinaryOptions{{}, {DebugLocations: true}} {
+		data, err := p.ParseIntoBinary(opts)
+		assert.NoError(t, err)
+		assert.Equal(t, data, EncodeBinary(&expected, opts), "encoding must be deterministic")
+
+		ast, err := DecodeBinary(data, DefaultParseOptions())
+		assert.NoError(t, err)
+		assert.True(t, expected.Equal(ast), FormatChanges(Diff(&expected, &ast)))
+
+		tag := ast.Children[1]
+		attr := tag.Attributes["ns:attr"]
+		assert.Equal(t, "ns", tag.Namespace)
+		assert.Equal(t, "ns:attr", attr.QualifiedName)
+		if opts.DebugLocations {
+			assert.Equal(t, expected.Children[1].DebugLocation, tag.DebugLocation)
+			assert.Equal(t, expected.Children[1].Attributes["ns:attr"].DebugLocation, attr.DebugLocation)
+			assert.Equal(t, expected.Children[0].Values[3].DebugLocation, ast.Children[0].Values[3].DebugLocation)
+			assert.Equal(t, "test.sdl", tag.DebugLocation.File)
+		} else {
+			assert.Equal(t, SdlDebugLocation{}, tag.DebugLocation)
+		}
+	}
+}
+
+func TestBinaryExactValues(t *testing.T) {
+	// These can't be written as text, but are kept exactly by the binary encoding.
+	zone := time.FixedZone("Somewhere", -(3*60*60 + 15*60))
+	root := NewTag("", "")
+	tag := NewTag("", "exact")
+	tag.AddValue(
+		DateTime(time.Date(2021, 3, 4, 5, 6, 7, 123456789, zone)),
+		TimeSpan(time.Nanosecond),
+		Float(math.Inf(-1)),
+		Float(math.Copysign(0, -1)),
+		String("invalid \xff utf-8"),
+		Binary([]byte{}),
+	)
+	root.AddChild(tag)
+
+	ast, err := DecodeBinary(EncodeBinary(&root, BinaryOptions{}), ParseOptions{})
+	assert.NoError(t, err)
+	assert.True(t, root.Equal(ast), FormatChanges(Diff(&root, &ast)))
+
+	decoded, _ := ast.Children[0].Values[0].DateTime()
+	name, offset := decoded.Zone()
+	assert.Equal(t, "Somewhere", name)
+	assert.Equal(t, -(3*60*60 + 15*60), offset)
+	assert.Equal(t, 123456789, decoded.Nanosecond())
+	f, _ := ast.Children[0].Values[3].Float()
+	assert.True(t, math.Signbit(f))
+
+	_, err = BinaryToText(EncodeBinary(&root, BinaryOptions{}), ParseOptions{})
+	assert.EqualError(t, err, "tag exact: float -Inf can't be written as text")
+}
+
+func TestBinaryToText(t *testing.T) {
+	p := SaxParser{Input: binaryTestInput}
+	data, err := p.ParseIntoBinary(BinaryOptions{})
+	assert.NoError(t, err)
+
+	text, err := BinaryToText(data, ParseOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, `values null "str" "c" 123 -9223372036854775808 4.5 2005/12/05 2005/12/05 14:12:23.345-GMT+01:30 -1d:02:03:04.500 true false [AQID]
+ns:tag attr=1 ns:attr="a" {
+	child 1 {
+		grandchild
+	}
+	content "anonymous"
+}
+`, text)
+
+	expected, _ := p.ParseIntoAst()
+	p = SaxParser{Input: text}
+	ast, err := p.ParseIntoAst()
+	assert.NoError(t, err)
+	assert.True(t, expected.Equal(ast), FormatChanges(Diff(&expected, &ast)))
+
+	for _, name := range []string{"", "true", "1a", "a b", "true:a", "ns:a b", "ns:", "ns:1a"} {
+		namespace, local := splitQualifiedName(name)
+		root := NewTag("", "")
+		root.AddChild(NewTag(namespace, local))
+		_, err = BinaryToText(EncodeBinary(&root, BinaryOptions{}), ParseOptions{})
+		assert.Error(t, err, name)
+
+		root = NewTag("", "")
+		tag := NewTag("", "a")
+		tag.Attributes = map[string]SdlAttribute{name: NewAttribute(namespace, local, Null())}
+		root.AddChild(tag)
+		_, err = BinaryToText(EncodeBinary(&root, BinaryOptions{}), ParseOptions{})
+		assert.Error(t, err, name)
+	}
+
+	// Keywords are allowed after a namespace.
+	root := NewTag("", "")
+	root.AddChild(NewTag("ns", "true"), NewTag("ns", "a-1"))
+	text, err = BinaryToText(EncodeBinary(&root, BinaryOptions{}), ParseOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "ns:true\nns:a-1\n", text)
+
+	// Datetimes can only be written as text if the parser can read them back.
+	for _, value := range []time.Time{
+		time.Date(10000, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(-1, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2005, 1, 1, 0, 0, 0, 0, time.FixedZone("", 25*60*60)),
+	} {
+		root, tag := NewTag("", ""), NewTag("", "a")
+		root.AddChild(*tag.AddValue(DateTime(value)))
+		_, err = BinaryToText(EncodeBinary(&root, BinaryOptions{}), ParseOptions{})
+		assert.Error(t, err, value.String())
+	}
+	root, tag := NewTag("", ""), NewTag("", "a")
+	root.AddChild(*tag.AddValue(DateTime(time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC)), DateTime(time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC))))
+	text, err = BinaryToText(EncodeBinary(&root, BinaryOptions{}), ParseOptions{})
+	assert.NoError(t, err)
+	_, err = SaxParser{Input: text}.ParseIntoAst()
+	assert.NoError(t, err, text)
+}
+
+func TestDecodeBinaryErrors(t *testing.T) {
+	p := SaxParser{Input: binaryTestInput}
+	valid, err := p.ParseIntoBinary(BinaryOptions{DebugLocations: true})
+	assert.NoError(t, err)
+
+	_, err = DecodeBinary([]byte("SDL"), ParseOptions{})
+	assert.EqualError(t, err, "data is not binary SDLang")
+	_, err = DecodeBinary([]byte("SDLB\x02\x00"), ParseOptions{})
+	assert.EqualError(t, err, "unsupported binary SDLang version 2")
+	_, err = DecodeBinary([]byte("SDLB\x01\x02"), ParseOptions{})
+	assert.EqualError(t, err, "unsupported binary SDLang flags 0x2")
+	_, err = DecodeBinary([]byte("SDLB\x01\x07"), ParseOptions{})
+	assert.EqualError(t, err, "unsupported binary SDLang flags 0x7")
+
+	// Locations without spans, from before spans were added.
+	root, err := DecodeBinary([]byte("SDLB\x01\x01\x01\x00\x00\x00\x00\x00\x02\x04\x00\x00\x00"), ParseOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, SdlDebugLocation{Loc: 1, LineNumber: 2}, root.DebugLocation)
+	_, err = DecodeBinary(append(append([]byte{}, valid...), 0), ParseOptions{})
+	assert.Error(t, err)
+	assert.True(t, strings.HasSuffix(err.Error(), "unexpected data after the root tag"))
+
+	// Every truncation must fail cleanly.
+	for i := 0; i < len(valid); i++ {
+		_, err = DecodeBinary(valid[:i], ParseOptions{})
+		assert.Error(t, err, i)
+	}
+
+	// The only string in the table claims to be far longer than the data.
+	_, err = DecodeBinary([]byte("SDLB\x01\x00\x01\x7fabc"), ParseOptions{})
+	assert.EqualError(t, err, "invalid binary SDLang at byte 8: count is larger than the remaining data")
+}
+
+func TestDecodeBinaryLimits(t *testing.T) {
+	p := SaxParser{Input: binaryTestInput}
+	data, err := p.ParseIntoBinary(BinaryOptions{})
+	assert.NoError(t, err)
+
+	limits := []struct {
+		opts    ParseOptions
+		message string
+	}{
+		{ParseOptions{MaxDepth: 2}, "tags are nested more deeply than the maximum allowed 2"},
+		{ParseOptions{MaxTags: 4}, "there are more than the maximum allowed 4 tags"},
+		{ParseOptions{MaxValues: 11}, "a tag has more than the maximum allowed 11 values"},
+		{ParseOptions{MaxAttributes: 1}, "a tag has more than the maximum allowed 1 attributes"},
+		{ParseOptions{MaxTokenLength: 2}, "length is more than the maximum allowed 2 bytes"},
+	}
+	for _, limit := range limits {
+		_, err = DecodeBinary(data, limit.opts)
+		if assert.Error(t, err, limit.message) {
+			assert.Contains(t, err.Error(), limit.message)
+		}
+	}
+
+	_, err = DecodeBinary(data, ParseOptions{MaxDepth: 3, MaxTags: 5, MaxValues: 12, MaxAttributes: 2, MaxTokenLength: 10})
+	assert.NoError(t, err)
+}
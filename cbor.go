@@ -0,0 +1,412 @@
+package sdlang
+
+import (
+	binenc "encoding/binary" // Aliased, as `binary` is a token type.
+	"fmt"
+	"math"
+	"time"
+)
+
+// CBOR major types, which are the top 3 bits of each item's initial byte.
+const (
+	cborUint   = 0 << 5
+	cborNegInt = 1 << 5
+	cborBytes  = 2 << 5
+	cborText   = 3 << 5
+	cborArray  = 4 << 5
+	cborMap    = 5 << 5
+	cborTag    = 6 << 5
+	cborSimple = 7 << 5
+
+	cborFalse   = cborSimple | 20
+	cborTrue    = cborSimple | 21
+	cborNull    = cborSimple | 22
+	cborFloat16 = cborSimple | 25
+	cborFloat32 = cborSimple | 26
+	cborFloat64 = cborSimple | 27
+)
+
+// CBOR tags used for values without a type of their own.
+const (
+	cborTagDateTimeString = 0    // RFC 3339 text, see RFC 8949.
+	cborTagEpochDateTime  = 1    // Seconds since the Unix epoch, see RFC 8949.
+	cborTagDuration       = 1002 // A map of seconds (key 1) and nanoseconds (key -9), see RFC 9581.
+
+	cborDurationSeconds     = 1
+	cborDurationNanoseconds = -9
+)
+
+// EncodeCBOR encodes `tag` and its children as CBOR (RFC 8949), in the shape described by encodeTree.
+// Debug locations aren't kept.
+//
+// Datetimes in UTC that are a whole number of seconds use tag 1 with an integer, and other datetimes use tag 0 so that their
+// time zone offset and nanoseconds are kept. Datetimes outside of the years 0-9999 always use tag 1, and lose any fraction of a second.
+// Timespans use tag 1002 from RFC 9581. Everything is written with the shortest possible lengths, so the output is deterministic.
+func EncodeCBOR(tag *SdlTag) []byte {
+	e := cborEncoder{}
+	encodeTree(&e, tag)
+	return e.out
+}
+
+// DecodeCBOR decodes CBOR in the shape produced by EncodeCBOR. The data is untrusted, so it's fully validated, and `limits`
+// are applied in the same way as when parsing text. Decoding doesn't recurse, so deeply nested data can't overflow the stack.
+// Indefinite lengths aren't supported.
+//
+// Datetimes may use either tag 0 or tag 1, with an integer or float.
+func DecodeCBOR(data []byte, limits ParseOptions) (SdlTag, error) {
+	d := cborDecoder{data: data, limits: limits}
+	t := treeDecoding{d: &d, limits: limits}
+	root := t.decodeTree()
+	if d.err == nil && d.pos != len(d.data) {
+		d.fail("unexpected data after the root tag")
+	}
+	if d.err != nil {
+		return SdlTag{}, d.err
+	}
+	return root, nil
+}
+
+type cborEncoder struct {
+	out []byte
+}
+
+// writeHeader writes an item's initial byte and argument, using as few bytes as possible.
+func (e *cborEncoder) writeHeader(major byte, argument uint64) {
+	switch {
+	case argument < 24:
+		e.out = append(e.out, major|byte(argument))
+	case argument <= math.MaxUint8:
+		e.out = append(e.out, major|24, byte(argument))
+	case argument <= math.MaxUint16:
+		e.out = append(e.out, major|25, byte(argument>>8), byte(argument))
+	case argument <= math.MaxUint32:
+		e.out = append(e.out, major|26, byte(argument>>24), byte(argument>>16), byte(argument>>8), byte(argument))
+	default:
+		e.out = append(e.out, major|27)
+		e.writeUint64(argument)
+	}
+}
+
+func (e *cborEncoder) writeUint64(value uint64) {
+	var buf [8]byte
+	binenc.BigEndian.PutUint64(buf[:], value)
+	e.out = append(e.out, buf[:]...)
+}
+
+func (e *cborEncoder) writeMapHeader(n int) {
+	e.writeHeader(cborMap, uint64(n))
+}
+
+func (e *cborEncoder) writeArrayHeader(n int) {
+	e.writeHeader(cborArray, uint64(n))
+}
+
+func (e *cborEncoder) writeString(s string) {
+	e.writeHeader(cborText, uint64(len(s)))
+	e.out = append(e.out, s...)
+}
+
+func (e *cborEncoder) writeInt(value int64) {
+	if value >= 0 {
+		e.writeHeader(cborUint, uint64(value))
+	} else {
+		e.writeHeader(cborNegInt, uint64(-1-value))
+	}
+}
+
+func (e *cborEncoder) writeValue(v SdlValue) {
+	switch v.tag {
+	case tString:
+		e.writeString(v.vString)
+	case tInt:
+		e.writeInt(v.vInt)
+	case tFloat:
+		e.out = append(e.out, cborFloat64)
+		e.writeUint64(math.Float64bits(v.vFloat))
+	case tDateTime:
+		_, offset := v.vDateTime.Zone()
+		year := v.vDateTime.Year()
+		if (offset == 0 && v.vDateTime.Nanosecond() == 0) || year < 0 || year > 9999 {
+			e.writeHeader(cborTag, cborTagEpochDateTime)
+			e.writeInt(v.vDateTime.Unix())
+		} else {
+			e.writeHeader(cborTag, cborTagDateTimeString)
+			e.writeString(v.vDateTime.Format(time.RFC3339Nano))
+		}
+	case tTimeSpan:
+		seconds, nanoseconds := int64(v.vTimeSpan/time.Second), int64(v.vTimeSpan%time.Second)
+		e.writeHeader(cborTag, cborTagDuration)
+		if nanoseconds == 0 {
+			e.writeMapHeader(1)
+		} else {
+			e.writeMapHeader(2)
+		}
+		e.writeInt(cborDurationSeconds)
+		e.writeInt(seconds)
+		if nanoseconds != 0 {
+			e.writeInt(cborDurationNanoseconds)
+			e.writeInt(nanoseconds)
+		}
+	case tBool:
+		if v.vBool {
+			e.out = append(e.out, cborTrue)
+		} else {
+			e.out = append(e.out, cborFalse)
+		}
+	case tBinary:
+		e.writeHeader(cborBytes, uint64(len(v.vBinary)))
+		e.out = append(e.out, v.vBinary...)
+	default:
+		e.out = append(e.out, cborNull)
+	}
+}
+
+// cborDecoder reads from `data`. The first problem is kept in `err`, after which every read returns a zero value.
+type cborDecoder struct {
+	data   []byte
+	pos    int
+	limits ParseOptions
+	err    error
+}
+
+func (d *cborDecoder) fail(msg string) {
+	if d.err == nil {
+		d.err = fmt.Errorf("invalid CBOR at byte %d: %s", d.pos, msg)
+	}
+}
+
+func (d *cborDecoder) failed() bool {
+	return d.err != nil
+}
+
+func (d *cborDecoder) take(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(d.data)-d.pos {
+		d.fail("unexpected end of data")
+		return nil
+	}
+	d.pos += n
+	return d.data[d.pos-n : d.pos : d.pos]
+}
+
+// peek returns the major type of the next item, without reading it.
+func (d *cborDecoder) peek() byte {
+	if d.err != nil || d.pos >= len(d.data) {
+		return 0xff
+	}
+	return d.data[d.pos] & 0xe0
+}
+
+// readHeader reads an item's initial byte, returning its major type, additional information, and argument.
+func (d *cborDecoder) readHeader() (byte, byte, uint64) {
+	initial := d.take(1)
+	if initial == nil {
+		return 0xff, 0, 0
+	}
+	major, info := initial[0]&0xe0, initial[0]&0x1f
+	switch {
+	case info < 24:
+		return major, info, uint64(info)
+	case info == 24:
+		if b := d.take(1); b != nil {
+			return major, info, uint64(b[0])
+		}
+	case info == 25:
+		if b := d.take(2); b != nil {
+			return major, info, uint64(binenc.BigEndian.Uint16(b))
+		}
+	case info == 26:
+		if b := d.take(4); b != nil {
+			return major, info, uint64(binenc.BigEndian.Uint32(b))
+		}
+	case info == 27:
+		if b := d.take(8); b != nil {
+			return major, info, binenc.BigEndian.Uint64(b)
+		}
+	case info == 31:
+		d.fail("indefinite lengths are not supported")
+	default:
+		d.fail("reserved additional information")
+	}
+	return 0xff, 0, 0
+}
+
+// readCount reads the header of an item with the given major type, whose argument is a count of items or bytes.
+// Every item takes at least one byte, so a count larger than the remaining data must be invalid, which prevents huge allocations.
+func (d *cborDecoder) readCount(major byte, what string) int {
+	actual, _, argument := d.readHeader()
+	if d.err != nil {
+		return 0
+	}
+	if actual != major {
+		d.fail("expected " + what)
+		return 0
+	}
+	if argument > uint64(len(d.data)-d.pos) {
+		d.fail("length is larger than the remaining data")
+		return 0
+	}
+	return int(argument)
+}
+
+func (d *cborDecoder) readMapHeader() int {
+	return d.readCount(cborMap, "a map")
+}
+
+func (d *cborDecoder) readArrayHeader() int {
+	return d.readCount(cborArray, "an array")
+}
+
+func (d *cborDecoder) readBytes(major byte, what string) []byte {
+	length := d.readCount(major, what)
+	if d.limits.MaxTokenLength > 0 && length > d.limits.MaxTokenLength {
+		d.fail(fmt.Sprintf("length is more than the maximum allowed %d bytes", d.limits.MaxTokenLength))
+	}
+	return d.take(length)
+}
+
+func (d *cborDecoder) readString() string {
+	return string(d.readBytes(cborText, "a text string"))
+}
+
+func (d *cborDecoder) readInt() int64 {
+	major, _, argument := d.readHeader()
+	if d.err != nil {
+		return 0
+	}
+	if (major != cborUint && major != cborNegInt) || argument > math.MaxInt64 {
+		d.fail("expected a 64-bit integer")
+		return 0
+	}
+	if major == cborNegInt {
+		return -1 - int64(argument)
+	}
+	return int64(argument)
+}
+
+func (d *cborDecoder) readValue() SdlValue {
+	switch d.peek() {
+	case cborUint, cborNegInt:
+		return Int(d.readInt())
+	case cborBytes:
+		return Binary(append([]byte{}, d.readBytes(cborBytes, "a byte string")...))
+	case cborText:
+		return String(d.readString())
+	case cborTag:
+		return d.readTaggedValue()
+	case cborSimple:
+		switch d.data[d.pos] {
+		case cborFalse:
+			d.pos++
+			return Bool(false)
+		case cborTrue:
+			d.pos++
+			return Bool(true)
+		case cborNull:
+			d.pos++
+			return Null()
+		}
+		return Float(d.readFloat())
+	}
+	d.fail("expected a value")
+	return SdlValue{}
+}
+
+func (d *cborDecoder) readFloat() float64 {
+	major, info, argument := d.readHeader()
+	if d.err != nil {
+		return 0
+	}
+	if major == cborSimple {
+		switch major | info {
+		case cborFloat16:
+			return float16ToFloat64(uint16(argument))
+		case cborFloat32:
+			return float64(math.Float32frombits(uint32(argument)))
+		case cborFloat64:
+			return math.Float64frombits(argument)
+		}
+	}
+	d.fail("expected a float")
+	return 0
+}
+
+func (d *cborDecoder) readTaggedValue() SdlValue {
+	_, _, tag := d.readHeader()
+	switch tag {
+	case cborTagDateTimeString:
+		text := d.readString()
+		if d.err != nil {
+			return SdlValue{}
+		}
+		value, err := time.Parse(time.RFC3339Nano, text)
+		if err != nil {
+			d.fail("invalid RFC 3339 datetime")
+			return SdlValue{}
+		}
+		return DateTime(value)
+	case cborTagEpochDateTime:
+		var value time.Time
+		switch d.peek() {
+		case cborUint, cborNegInt:
+			value = time.Unix(d.readInt(), 0).UTC()
+		default:
+			seconds := d.readFloat()
+			if d.err != nil {
+				return SdlValue{}
+			}
+			// Outside of these years, a float doesn't have enough precision for fractions of a second anyway.
+			if math.IsNaN(seconds) || seconds < -62167219200 || seconds >= 253402300800 {
+				d.fail("datetime is out of range")
+				return SdlValue{}
+			}
+			whole := math.Floor(seconds)
+			value = time.Unix(int64(whole), int64(math.Round((seconds-whole)*1e9))).UTC()
+		}
+		return DateTime(value)
+	case cborTagDuration:
+		var seconds, nanoseconds int64
+		fields := d.readMapHeader()
+		for i := 0; i < fields && d.err == nil; i++ {
+			switch key := d.readInt(); key {
+			case cborDurationSeconds:
+				seconds = d.readInt()
+			case cborDurationNanoseconds:
+				nanoseconds = d.readInt()
+			default:
+				d.fail(fmt.Sprintf("unsupported duration key %d", key))
+			}
+		}
+		total := time.Duration(seconds) * time.Second
+		if nanoseconds <= -int64(time.Second) || nanoseconds >= int64(time.Second) ||
+			seconds < math.MinInt64/int64(time.Second) || seconds > math.MaxInt64/int64(time.Second) ||
+			(nanoseconds > 0 && total > math.MaxInt64-time.Duration(nanoseconds)) ||
+			(nanoseconds < 0 && total < math.MinInt64-time.Duration(nanoseconds)) {
+			d.fail("duration is out of range")
+		}
+		return TimeSpan(total + time.Duration(nanoseconds))
+	}
+	d.fail(fmt.Sprintf("unsupported tag %d", tag))
+	return SdlValue{}
+}
+
+// float16ToFloat64 converts an IEEE 754 half-precision float.
+func float16ToFloat64(bits uint16) float64 {
+	sign := 1.0
+	if bits&0x8000 != 0 {
+		sign = -1
+	}
+	exponent, fraction := int(bits>>10&0x1f), float64(bits&0x3ff)
+	switch exponent {
+	case 0:
+		return sign * math.Ldexp(fraction, -24)
+	case 0x1f:
+		if fraction == 0 {
+			return math.Inf(int(sign))
+		}
+		return math.NaN()
+	}
+	return sign * math.Ldexp(fraction+1024, exponent-25)
+}
@@ -0,0 +1,103 @@
+package sdlang
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCBORValues(t *testing.T) {
+	// Most of these are from RFC 8949, appendix A.
+	tests := []struct {
+		value   SdlValue
+		encoded []byte
+	}{
+		{Int(0), []byte{0x00}},
+		{Int(23), []byte{0x17}},
+		{Int(24), []byte{0x18, 0x18}},
+		{Int(1000), []byte{0x19, 0x03, 0xe8}},
+		{Int(1000000000000), []byte{0x1b, 0x00, 0x00, 0x00, 0xe8, 0xd4, 0xa5, 0x10, 0x00}},
+		{Int(-1), []byte{0x20}},
+		{Int(-1000), []byte{0x39, 0x03, 0xe7}},
+		{Int(math.MinInt64), []byte{0x3b, 0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{Float(1.1), []byte{0xfb, 0x3f, 0xf1, 0x99, 0x99, 0x99, 0x99, 0x99, 0x9a}},
+		{Bool(false), []byte{0xf4}},
+		{Bool(true), []byte{0xf5}},
+		{Null(), []byte{0xf6}},
+		{Binary([]byte{1, 2, 3, 4}), []byte{0x44, 0x01, 0x02, 0x03, 0x04}},
+		{String("IETF"), []byte{0x64, 0x49, 0x45, 0x54, 0x46}},
+		{String("ü"), []byte{0x62, 0xc3, 0xbc}},
+		{DateTime(time.Unix(1363896240, 0).UTC()), []byte{0xc1, 0x1a, 0x51, 0x4b, 0x67, 0xb0}},
+		{DateTime(time.Date(2013, 3, 21, 20, 4, 0, 0, time.FixedZone("", 3600))),
+			append([]byte{0xc0, 0x78, 0x19}, "2013-03-21T20:04:00+01:00"...)},
+		{TimeSpan(90 * time.Second), []byte{0xd9, 0x03, 0xea, 0xa1, 0x01, 0x18, 0x5a}},
+		{TimeSpan(-1500 * time.Millisecond), []byte{0xd9, 0x03, 0xea, 0xa2, 0x01, 0x20, 0x28, 0x3a, 0x1d, 0xcd, 0x64, 0xff}},
+	}
+	for _, test := range tests {
+		e := cborEncoder{}
+		e.writeValue(test.value)
+		assert.Equal(t, test.encoded, e.out, test.value.literal())
+
+		d := cborDecoder{data: test.encoded}
+		decoded := d.readValue()
+		assert.NoError(t, d.err, test.value.literal())
+		assert.Equal(t, len(test.encoded), d.pos)
+		assert.True(t, test.value.Equal(decoded), "%s decoded as %s", test.value.literal(), decoded.literal())
+	}
+}
+
+func TestCBORDecodeOnly(t *testing.T) {
+	// Encodings that EncodeCBOR doesn't produce, but which are accepted.
+	tests := []struct {
+		encoded []byte
+		value   SdlValue
+	}{
+		{[]byte{0xf9, 0x3c, 0x00}, Float(1)},
+		{[]byte{0xf9, 0x00, 0x01}, Float(5.960464477539063e-8)},
+		{[]byte{0xf9, 0xc4, 0x00}, Float(-4)},
+		{[]byte{0xf9, 0x7c, 0x00}, Float(math.Inf(1))},
+		{[]byte{0xf9, 0x7e, 0x00}, Float(math.NaN())},
+		{[]byte{0xfa, 0x47, 0xc3, 0x50, 0x00}, Float(100000)},
+		{[]byte{0x18, 0x01}, Int(1)},
+		{append([]byte{0xc0, 0x74}, "2013-03-21T20:04:00Z"...), DateTime(time.Unix(1363896240, 0))},
+		{[]byte{0xc1, 0xfb, 0x41, 0xd4, 0x52, 0xd9, 0xec, 0x20, 0x00, 0x00}, DateTime(time.Unix(1363896240, 500000000))},
+		{[]byte{0xc1, 0x3a, 0x00, 0x01, 0x51, 0x7f}, DateTime(time.Unix(-86400, 0))},
+		{[]byte{0xd9, 0x03, 0xea, 0xa0}, TimeSpan(0)},
+	}
+	for _, test := range tests {
+		d := cborDecoder{data: test.encoded}
+		decoded := d.readValue()
+		assert.NoError(t, d.err, "%x", test.encoded)
+		assert.True(t, test.value.Equal(decoded), "%x decoded as %s", test.encoded, decoded.literal())
+	}
+}
+
+func TestCBORInvalidValues(t *testing.T) {
+	tests := []struct {
+		encoded []byte
+		message string
+	}{
+		{[]byte{0x1c}, "reserved additional information"},
+		{[]byte{0x5f, 0xff}, "indefinite lengths are not supported"},
+		{[]byte{0x1b, 0x80, 0, 0, 0, 0, 0, 0, 0}, "expected a 64-bit integer"},
+		{[]byte{0xf7}, "expected a float"},
+		{[]byte{0x80}, "expected a value"},
+		{[]byte{0xc2, 0x40}, "unsupported tag 2"},
+		{[]byte{0xc1, 0xc1, 0x00}, "expected a float"},
+		{[]byte{0xc1, 0xfb, 0x7f, 0xf8, 0, 0, 0, 0, 0, 0}, "datetime is out of range"},
+		{append([]byte{0xc0, 0x64}, "2013"...), "invalid RFC 3339 datetime"},
+		{[]byte{0xd9, 0x03, 0xea, 0xa1, 0x02, 0x00}, "unsupported duration key 2"},
+		{[]byte{0xd9, 0x03, 0xea, 0xa1, 0x28, 0x1a, 0x3b, 0x9a, 0xca, 0x00}, "duration is out of range"},
+		{[]byte{0xd9, 0x03, 0xea, 0xa2, 0x01, 0x1b, 0, 0, 0, 0x02, 0x25, 0xc1, 0x7d, 0x04, 0x28, 0x1a, 0x3b, 0x9a, 0xc9, 0xff},
+			"duration is out of range"},
+	}
+	for _, test := range tests {
+		d := cborDecoder{data: test.encoded}
+		d.readValue()
+		if assert.Error(t, d.err, test.message) {
+			assert.Contains(t, d.err.Error(), test.message)
+		}
+	}
+}
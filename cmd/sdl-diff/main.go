@@ -0,0 +1,62 @@
+// Command sdl-diff prints the structural differences between two SDLang documents, one change per line.
+//
+//	sdl-diff old.sdl new.sdl
+//
+// Unlike a text diff, it ignores formatting and comments, and reports added, removed and moved child tags, and changed
+// values and attributes, along with where each change is in both files. Like diff, it exits with 0 if the documents
+// are the same, 1 if they differ, and 2 if either of them couldn't be read or parsed.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	sdlang "github.com/SdlangInitiative/sdlanggo"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("sdl-diff", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: sdl-diff old.sdl new.sdl")
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return 2
+	}
+
+	from, err := parseFile(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	to, err := parseFile(flags.Arg(1))
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	changes := sdlang.Diff(&from, &to)
+	fmt.Fprint(stdout, sdlang.FormatChanges(changes))
+	if len(changes) > 0 {
+		return 1
+	}
+	return 0
+}
+
+func parseFile(fileName string) (sdlang.SdlTag, error) {
+	input, err := os.ReadFile(fileName)
+	if err != nil {
+		return sdlang.SdlTag{}, err
+	}
+	return sdlang.SaxParser{Input: string(input), FileName: fileName, Options: sdlang.DefaultParseOptions()}.ParseIntoAst()
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFile(t *testing.T, name, contents string) string {
+	file := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(file, []byte(contents), 0o644))
+	return file
+}
+
+func TestRun(t *testing.T) {
+	from := writeFile(t, "old.sdl", "server \"a\" port=80 {\n\tlisten 1\n}\n")
+	same := writeFile(t, "same.sdl", "// Only the formatting differs.\n\nserver  \"a\"  port=80 {\n  listen 1\n}\n")
+	to := writeFile(t, "new.sdl", "server \"a\" port=81 {\n\tlisten 1\n}\n")
+
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, 0, run([]string{from, same}, &stdout, &stderr))
+	assert.Empty(t, stdout.String())
+
+	assert.Equal(t, 1, run([]string{from, to}, &stdout, &stderr))
+	assert.Equal(t, "server: changed attribute port from 80 to 81 ("+from+" @ 1:12 -> "+to+" @ 1:12)\n", stdout.String())
+	assert.Empty(t, stderr.String())
+}
+
+func TestRunErrors(t *testing.T) {
+	valid := writeFile(t, "valid.sdl", "a\n")
+	invalid := writeFile(t, "invalid.sdl", "a \"unterminated\n")
+
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, 2, run([]string{valid}, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "usage")
+
+	stderr.Reset()
+	assert.Equal(t, 2, run([]string{valid, invalid}, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "invalid.sdl")
+
+	stderr.Reset()
+	assert.Equal(t, 2, run([]string{valid, filepath.Join(t.TempDir(), "missing.sdl")}, &stdout, &stderr))
+	assert.NotEmpty(t, stderr.String())
+	assert.Empty(t, stdout.String())
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf16"
+
+	sdlang "github.com/SdlangInitiative/sdlanggo"
+)
+
+// document is an open text document, along with everything found by parsing it.
+type document struct {
+	uri    string
+	text   string
+	lines  []string
+	root   *tagNode
+	errors []error
+}
+
+// tagNode is a tag, and where each of its parts are in the document.
+type tagNode struct {
+	namespace  string
+	name       string
+	anonymous  bool
+	nameRange  Range // Empty for anonymous tags.
+	rng        Range // From the start of the name to the end of the tag, including any closing brace.
+	values     []valueNode
+	attributes []attributeNode
+	children   []*tagNode
+	parent     *tagNode
+}
+
+type valueNode struct {
+	value sdlang.SdlValue
+	rng   Range
+}
+
+type attributeNode struct {
+	attr       sdlang.SdlAttribute
+	nameRange  Range
+	valueRange Range
+}
+
+func (t *tagNode) qualifiedName() string {
+	if t.namespace == "" {
+		return t.name
+	}
+	return t.namespace + ":" + t.name
+}
+
+func newDocument(uri, text string) *document {
+	d := &document{uri: uri, text: text, lines: strings.Split(text, "\n")}
+	for i, line := range d.lines {
+		d.lines[i] = strings.TrimSuffix(line, "\r")
+	}
+
+	p := sdlang.SaxParser{Input: text, FileName: uri, Options: sdlang.DefaultParseOptions()}
+	ix := indexer{parser: &p, stack: []*tagNode{{}}}
+	d.errors = p.ParseTolerant(&ix)
+	// Parsing may have stopped early, so any tags that are still open end where parsing stopped.
+	for len(ix.stack) > 1 {
+		ix.EndTag()
+	}
+	d.root = ix.stack[0]
+	return d
+}
+
+// indexer is a SaxHandler that builds a tree of tagNodes.
+type indexer struct {
+	parser *sdlang.SaxParser
+	stack  []*tagNode
+}
+
+func (ix *indexer) top() *tagNode {
+	return ix.stack[len(ix.stack)-1]
+}
+
+func (ix *indexer) StartTag(namespace, name string) error {
+	start := toPosition(ix.parser.TokenLocation())
+	end := toPosition(ix.parser.Location())
+	tag := &tagNode{
+		namespace: namespace,
+		name:      name,
+		anonymous: start == end,
+		nameRange: Range{start, end},
+		rng:       Range{start, end},
+		parent:    ix.top(),
+	}
+	ix.top().children = append(ix.top().children, tag)
+	ix.stack = append(ix.stack, tag)
+	return nil
+}
+
+func (ix *indexer) Value(value sdlang.SdlValue) error {
+	top := ix.top()
+	top.values = append(top.values, valueNode{
+		value: value,
+		rng:   Range{toPosition(ix.parser.TokenLocation()), toPosition(ix.parser.Location())},
+	})
+	return nil
+}
+
+func (ix *indexer) Attribute(attr sdlang.SdlAttribute) error {
+	// The attribute's location spans its name, and names can't span multiple lines.
+	nameEnd := attr.DebugLocation
+	nameEnd.Loc = nameEnd.End.Loc
+
+	top := ix.top()
+	top.attributes = append(top.attributes, attributeNode{
+		attr:       attr,
+		nameRange:  Range{toPosition(attr.DebugLocation), toPosition(nameEnd)},
+		valueRange: Range{toPosition(ix.parser.TokenLocation()), toPosition(ix.parser.Location())},
+	})
+	return nil
+}
+
+func (ix *indexer) EndTag() error {
+	top := ix.top()
+	// The current token is whatever ended the tag, such as a new line.
+	top.rng.End = toPosition(ix.parser.TokenLocation())
+	if before(top.rng.End, top.nameRange.End) {
+		top.rng.End = top.nameRange.End
+	}
+	ix.stack = ix.stack[:len(ix.stack)-1]
+	return nil
+}
+
+func (ix *indexer) Comment(text string) error {
+	return nil
+}
+
+// toPosition converts a location, whose column is counted in runes, into an LSP position counted in UTF-16 code units.
+func toPosition(loc sdlang.SdlDebugLocation) Position {
+	character := 0
+	runes := loc.Loc
+	for _, r := range loc.Line {
+		if runes == 0 {
+			break
+		}
+		runes--
+		character += len(utf16.Encode([]rune{r}))
+	}
+	return Position{Line: loc.LineNumber - 1, Character: character + runes}
+}
+
+// byteOffset converts `character`, counted in UTF-16 code units, into a byte offset within `line`.
+func byteOffset(line string, character int) int {
+	for i, r := range line {
+		if character <= 0 {
+			return i
+		}
+		character -= len(utf16.Encode([]rune{r}))
+	}
+	return len(line)
+}
+
+// before returns true if `a` comes before `b`.
+func before(a, b Position) bool {
+	return a.Line < b.Line || (a.Line == b.Line && a.Character < b.Character)
+}
+
+// contains returns true if `pos` is within `r`, including its end.
+func (r Range) contains(pos Position) bool {
+	return !before(pos, r.Start) && !before(r.End, pos)
+}
+
+// textInRange returns the text of the document within `r`, with line endings normalised to "\n".
+func (d *document) textInRange(r Range) string {
+	if r.Start.Line < 0 || r.End.Line >= len(d.lines) || before(r.End, r.Start) {
+		return ""
+	}
+	if r.Start.Line == r.End.Line {
+		line := d.lines[r.Start.Line]
+		return line[byteOffset(line, r.Start.Character):byteOffset(line, r.End.Character)]
+	}
+
+	first := d.lines[r.Start.Line]
+	text := []string{first[byteOffset(first, r.Start.Character):]}
+	text = append(text, d.lines[r.Start.Line+1:r.End.Line]...)
+	last := d.lines[r.End.Line]
+	return strings.Join(append(text, last[:byteOffset(last, r.End.Character)]), "\n")
+}
+
+// end is the position just after the last character of the document.
+func (d *document) end() Position {
+	last := d.lines[len(d.lines)-1]
+	return Position{Line: len(d.lines) - 1, Character: len(utf16.Encode([]rune(last)))}
+}
+
+// hasBlock returns true if `tag` has braces. Closing braces must be on their own line, so a tag has a block
+// if it ends on a later line that starts with one.
+func (d *document) hasBlock(tag *tagNode) bool {
+	line := tag.rng.End.Line
+	return line > tag.rng.Start.Line && line < len(d.lines) && strings.HasPrefix(strings.TrimSpace(d.lines[line]), "}")
+}
+
+// tagsOnLine returns the tags whose names are on `line`.
+func (d *document) tagsOnLine(line int) []*tagNode {
+	var tags []*tagNode
+	d.walk(func(tag *tagNode) {
+		if tag.nameRange.Start.Line == line {
+			tags = append(tags, tag)
+		}
+	})
+	return tags
+}
+
+// walk calls `f` for every tag in the document, parents before children.
+func (d *document) walk(f func(tag *tagNode)) {
+	var stack []*tagNode
+	push := func(tags []*tagNode) {
+		for i := len(tags) - 1; i >= 0; i-- {
+			stack = append(stack, tags[i])
+		}
+	}
+	push(d.root.children)
+	for len(stack) > 0 {
+		tag := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		f(tag)
+		push(tag.children)
+	}
+}
+
+// valueKind names the kind of an SdlValue, as shown to the user.
+func valueKind(v sdlang.SdlValue) string {
+	switch {
+	case v.IsString():
+		return "string"
+	case v.IsInt():
+		return "integer"
+	case v.IsFloat():
+		return "float"
+	case v.IsBool():
+		return "bool"
+	case v.IsDateTime():
+		return "datetime"
+	case v.IsTimeSpan():
+		return "timespan"
+	case v.IsBinary():
+		return "binary"
+	default:
+		return "null"
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+)
+
+// JSON-RPC error codes used by LSP.
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInternalError  = -32603
+)
+
+// message is any JSON-RPC message. Requests have an ID and a method, notifications only have a method,
+// and responses only have an ID.
+type message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  json.RawMessage  `json:"result,omitempty"` // Always set for successful responses, even if it is null.
+	Error   *responseError   `json:"error,omitempty"`
+}
+
+type responseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *responseError) Error() string {
+	return e.Message
+}
+
+// maxMessageLength is the largest message body that will be read, so that a client can't make the server run out of memory.
+const maxMessageLength = 64 * 1024 * 1024
+
+// readMessage reads a single message framed with a Content-Length header, as used by LSP.
+// The body of a message larger than maxMessageLength is skipped, and a protocol error is returned for it.
+func readMessage(r *bufio.Reader) (message, error) {
+	header, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil {
+		return message{}, err
+	}
+	length, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+	if err != nil || length < 0 {
+		return message{}, errors.New("missing or invalid Content-Length header")
+	}
+	if length > maxMessageLength {
+		if _, err = io.CopyN(io.Discard, r, length); err != nil {
+			return message{}, err
+		}
+		return message{}, &responseError{Code: codeInvalidRequest, Message: fmt.Sprintf("message of %d bytes is larger than the limit of %d bytes", length, maxMessageLength)}
+	}
+
+	body := make([]byte, length)
+	if _, err = io.ReadFull(r, body); err != nil {
+		return message{}, err
+	}
+
+	var msg message
+	if err = json.Unmarshal(body, &msg); err != nil {
+		return message{}, &responseError{Code: codeParseError, Message: err.Error()}
+	}
+	return msg, nil
+}
+
+// writeMessage writes `msg` framed with a Content-Length header.
+func writeMessage(w io.Writer, msg message) error {
+	msg.JSONRPC = "2.0"
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	return err
+}
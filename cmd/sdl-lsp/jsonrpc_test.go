@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageRoundTrip(t *testing.T) {
+	id := json.RawMessage(`"abc"`)
+	var b bytes.Buffer
+	assert.NoError(t, writeMessage(&b, message{ID: &id, Result: json.RawMessage("null")}))
+	assert.Equal(t, "Content-Length: 42\r\n\r\n{\"jsonrpc\":\"2.0\",\"id\":\"abc\",\"result\":null}", b.String())
+
+	msg, err := readMessage(bufio.NewReader(&b))
+	assert.NoError(t, err)
+	assert.Equal(t, "2.0", msg.JSONRPC)
+	assert.Equal(t, `"abc"`, string(*msg.ID))
+	assert.Equal(t, "null", string(msg.Result))
+}
+
+func TestReadMessageHeaders(t *testing.T) {
+	body := `{"jsonrpc":"2.0","method":"initialized","params":{}}`
+	input := "Content-Type: application/vscode-jsonrpc; charset=utf-8\r\ncontent-length: 52\r\n\r\n" + body
+	msg, err := readMessage(bufio.NewReader(strings.NewReader(input)))
+	assert.NoError(t, err)
+	assert.Equal(t, "initialized", msg.Method)
+	assert.Nil(t, msg.ID)
+
+	_, err = readMessage(bufio.NewReader(strings.NewReader("Content-Type: x\r\n\r\n{}")))
+	assert.EqualError(t, err, "missing or invalid Content-Length header")
+
+	_, err = readMessage(bufio.NewReader(strings.NewReader("Content-Length: 100\r\n\r\n{}")))
+	assert.Error(t, err, "the body is shorter than its length")
+
+	_, err = readMessage(bufio.NewReader(strings.NewReader("Content-Length: 2\r\n\r\n{x")))
+	var rpcErr *responseError
+	if assert.True(t, errors.As(err, &rpcErr)) {
+		assert.Equal(t, codeParseError, rpcErr.Code)
+	}
+}
+
+// zeros is an endless stream of zero bytes.
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+func TestReadMessageTooLarge(t *testing.T) {
+	// The body is skipped without being held in memory, and the next message can still be read.
+	length := maxMessageLength + 1
+	next := `{"jsonrpc":"2.0","method":"exit"}`
+	r := bufio.NewReader(io.MultiReader(
+		strings.NewReader("Content-Length: "+fmtInt(length)+"\r\n\r\n"),
+		io.LimitReader(zeros{}, int64(length)),
+		strings.NewReader("Content-Length: 33\r\n\r\n"+next),
+	))
+	_, err := readMessage(r)
+	var rpcErr *responseError
+	if assert.True(t, errors.As(err, &rpcErr)) {
+		assert.Equal(t, codeInvalidRequest, rpcErr.Code)
+	}
+	msg, err := readMessage(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "exit", msg.Method)
+
+	// A client that claims to be sending a huge message doesn't make the server allocate it.
+	_, err = readMessage(bufio.NewReader(strings.NewReader("Content-Length: 1099511627776\r\n\r\n{}")))
+	assert.Error(t, err)
+}
+
+func TestServeInvalidJSON(t *testing.T) {
+	var out bytes.Buffer
+	in := "Content-Length: 2\r\n\r\n{x"
+	in += "Content-Length: 58\r\n\r\n{\"jsonrpc\":\"2.0\",\"id\":1,\"method\":\"shutdown\",\"params\":null}"
+	in += "Content-Length: 33\r\n\r\n{\"jsonrpc\":\"2.0\",\"method\":\"exit\"}"
+	assert.Equal(t, 0, newServer(&out, nil).serve(strings.NewReader(in)))
+
+	// The request's ID can't be known, so it's null.
+	assert.Contains(t, out.String(), `"id":null`)
+	r := bufio.NewReader(&out)
+	msg, err := readMessage(r)
+	assert.NoError(t, err)
+	if assert.NotNil(t, msg.Error) {
+		assert.Equal(t, codeParseError, msg.Error.Code)
+	}
+	msg, err = readMessage(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", string(*msg.ID))
+}
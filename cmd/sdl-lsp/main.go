@@ -0,0 +1,28 @@
+// Command sdl-lsp is a Language Server Protocol server for SDLang documents, which talks to its client over stdin and stdout.
+//
+// It reports parse errors as diagnostics, and provides document symbols, hover, formatting, and folding ranges.
+// Given a schema, either with the -schema flag or the "schema" initialization option, it also completes tag and
+// attribute names. See schemaTag for the format of a schema.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+func main() {
+	schemaFile := flag.String("schema", "", "path to a schema file describing the expected tags and attributes")
+	flag.Parse()
+
+	var schema *schemaTag
+	if *schemaFile != "" {
+		var err error
+		if schema, err = loadSchema(*schemaFile); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+
+	os.Exit(newServer(os.Stdout, schema).serve(os.Stdin))
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+
+	sdlang "github.com/SdlangInitiative/sdlanggo"
+)
+
+// schemaTag describes a tag that's expected in a document, and is used for completion and hover.
+// A schema is written in SDLang, where the top-level `tag` tags are allowed at the root of a document:
+//
+//	tag "server" description="A server to connect to" {
+//		attribute "port" description="Defaults to 80"
+//		tag "route" {
+//			attribute "path"
+//		}
+//	}
+type schemaTag struct {
+	name        string
+	description string
+	attributes  []schemaAttribute
+	tags        []*schemaTag
+}
+
+type schemaAttribute struct {
+	name        string
+	description string
+}
+
+func loadSchema(fileName string) (*schemaTag, error) {
+	text, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	p := sdlang.SaxParser{Input: string(text), FileName: fileName}
+	root, err := p.ParseIntoAst()
+	if err != nil {
+		return nil, err
+	}
+
+	schema := &schemaTag{}
+	return schema, schema.load(&root)
+}
+
+func (s *schemaTag) load(tag *sdlang.SdlTag) error {
+	for i := range tag.Children {
+		child := &tag.Children[i]
+		if len(child.Values) != 1 || !child.Values[0].IsString() {
+			return child.DebugLocation.NewError("Schema tags must have exactly one string value, which is the name being described.")
+		}
+		name, _ := child.Values[0].String()
+		description := ""
+		if attr, ok := child.Attributes["description"]; ok {
+			description, _ = attr.Value.String()
+		}
+
+		switch child.QualifiedName {
+		case "tag":
+			nested := &schemaTag{name: name, description: description}
+			if err := nested.load(child); err != nil {
+				return err
+			}
+			s.tags = append(s.tags, nested)
+		case "attribute":
+			if len(child.Children) > 0 {
+				return child.DebugLocation.NewError("Schema attributes can't have children.")
+			}
+			s.attributes = append(s.attributes, schemaAttribute{name: name, description: description})
+		default:
+			return child.DebugLocation.NewError("Expected a 'tag' or 'attribute' tag in the schema.")
+		}
+	}
+	return nil
+}
+
+func (s *schemaTag) tag(name string) *schemaTag {
+	if s == nil {
+		return nil
+	}
+	for _, tag := range s.tags {
+		if tag.name == name {
+			return tag
+		}
+	}
+	return nil
+}
+
+// find returns the schema for `tag`, by following the names of its parents from the root of the schema.
+func (s *schemaTag) find(tag *tagNode) *schemaTag {
+	if tag.parent == nil {
+		return s
+	}
+	return s.find(tag.parent).tag(tag.qualifiedName())
+}
@@ -0,0 +1,430 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	sdlang "github.com/SdlangInitiative/sdlanggo"
+)
+
+// server is a language server for a single client, which talks to it over `out`.
+type server struct {
+	out       io.Writer
+	schema    *schemaTag
+	documents map[string]*document
+	shutdown  bool
+}
+
+func newServer(out io.Writer, schema *schemaTag) *server {
+	return &server{out: out, schema: schema, documents: map[string]*document{}}
+}
+
+// serve handles messages from `in` until the client asks the server to exit, returning the process's exit code.
+func (s *server) serve(in io.Reader) int {
+	r := bufio.NewReader(in)
+	for {
+		msg, err := readMessage(r)
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return 1
+		} else if err != nil {
+			var rpcErr *responseError
+			if !errors.As(err, &rpcErr) {
+				return 1
+			}
+			s.write(message{ID: nullID(), Error: rpcErr})
+			continue
+		}
+
+		if msg.Method == "exit" {
+			if s.shutdown {
+				return 0
+			}
+			return 1
+		}
+
+		result, err := s.handleSafely(msg)
+		if msg.ID == nil {
+			continue // Notifications don't have a response, even if they fail.
+		}
+		response := message{ID: msg.ID}
+		if err != nil {
+			var rpcErr *responseError
+			if !errors.As(err, &rpcErr) {
+				rpcErr = &responseError{Code: codeInternalError, Message: err.Error()}
+			}
+			response.Error = rpcErr
+		} else {
+			response.Result, err = json.Marshal(result)
+			if err != nil {
+				response.Error = &responseError{Code: codeInternalError, Message: err.Error()}
+			}
+		}
+		s.write(response)
+	}
+}
+
+func nullID() *json.RawMessage {
+	id := json.RawMessage("null")
+	return &id
+}
+
+func (s *server) write(msg message) {
+	// If the client has gone away there's nobody to tell, and the next read will fail anyway.
+	_ = writeMessage(s.out, msg)
+}
+
+func (s *server) notify(method string, params interface{}) {
+	body, err := json.Marshal(params)
+	if err == nil {
+		s.write(message{Method: method, Params: body})
+	}
+}
+
+// handleSafely is handle, except a panic is turned into an internal error so that one bad request can't stop the server.
+func (s *server) handleSafely(msg message) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = nil, &responseError{Code: codeInternalError, Message: fmt.Sprintf("internal error handling %s: %v", msg.Method, r)}
+		}
+	}()
+	return s.handle(msg)
+}
+
+// handle dispatches a request or notification, returning the result for requests.
+func (s *server) handle(msg message) (interface{}, error) {
+	decode := func(params interface{}) error {
+		if len(msg.Params) == 0 {
+			return nil
+		}
+		if err := json.Unmarshal(msg.Params, params); err != nil {
+			return &responseError{Code: codeInvalidParams, Message: err.Error()}
+		}
+		return nil
+	}
+
+	switch msg.Method {
+	case "initialize":
+		var params InitializeParams
+		if err := decode(&params); err != nil {
+			return nil, err
+		}
+		return s.initialize(params)
+	case "initialized", "$/cancelRequest", "$/setTrace", "workspace/didChangeConfiguration":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var params DidOpenTextDocumentParams
+		if err := decode(&params); err != nil {
+			return nil, err
+		}
+		s.update(params.TextDocument.URI, params.TextDocument.Text)
+		return nil, nil
+	case "textDocument/didChange":
+		var params DidChangeTextDocumentParams
+		if err := decode(&params); err != nil {
+			return nil, err
+		}
+		if n := len(params.ContentChanges); n > 0 {
+			s.update(params.TextDocument.URI, params.ContentChanges[n-1].Text)
+		}
+		return nil, nil
+	case "textDocument/didClose":
+		var params DidCloseTextDocumentParams
+		if err := decode(&params); err != nil {
+			return nil, err
+		}
+		delete(s.documents, params.TextDocument.URI)
+		s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: params.TextDocument.URI, Diagnostics: []Diagnostic{}})
+		return nil, nil
+	case "textDocument/documentSymbol":
+		var params DocumentSymbolParams
+		if err := decode(&params); err != nil {
+			return nil, err
+		}
+		d, err := s.document(params.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		return d.symbols(d.root.children), nil
+	case "textDocument/hover":
+		var params TextDocumentPositionParams
+		if err := decode(&params); err != nil {
+			return nil, err
+		}
+		d, err := s.document(params.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		return d.hover(params.Position, s.schema), nil
+	case "textDocument/formatting":
+		var params DocumentFormattingParams
+		if err := decode(&params); err != nil {
+			return nil, err
+		}
+		d, err := s.document(params.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		indent := "\t"
+		if params.Options.InsertSpaces && params.Options.TabSize > 0 {
+			indent = strings.Repeat(" ", params.Options.TabSize)
+		}
+		return d.format(indent), nil
+	case "textDocument/foldingRange":
+		var params FoldingRangeParams
+		if err := decode(&params); err != nil {
+			return nil, err
+		}
+		d, err := s.document(params.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		return d.foldingRanges(), nil
+	case "textDocument/completion":
+		var params TextDocumentPositionParams
+		if err := decode(&params); err != nil {
+			return nil, err
+		}
+		d, err := s.document(params.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		return d.complete(params.Position, s.schema), nil
+	}
+
+	return nil, &responseError{Code: codeMethodNotFound, Message: "Method not found: " + msg.Method}
+}
+
+func (s *server) initialize(params InitializeParams) (InitializeResult, error) {
+	if path := params.InitializationOptions.Schema; path != "" {
+		schema, err := loadSchema(path)
+		if err != nil {
+			return InitializeResult{}, &responseError{Code: codeInvalidParams, Message: "Could not load schema: " + err.Error()}
+		}
+		s.schema = schema
+	}
+
+	var result InitializeResult
+	result.ServerInfo.Name = "sdl-lsp"
+	result.Capabilities = ServerCapabilities{
+		TextDocumentSync:           textDocumentSyncFull,
+		DocumentSymbolProvider:     true,
+		HoverProvider:              true,
+		DocumentFormattingProvider: true,
+		FoldingRangeProvider:       true,
+	}
+	if s.schema != nil {
+		result.Capabilities.CompletionProvider = &CompletionOptions{}
+	}
+	return result, nil
+}
+
+func (s *server) document(uri string) (*document, error) {
+	d, ok := s.documents[uri]
+	if !ok {
+		return nil, &responseError{Code: codeInvalidParams, Message: "Document is not open: " + uri}
+	}
+	return d, nil
+}
+
+// update reparses a document and publishes its diagnostics.
+func (s *server) update(uri, text string) {
+	d := newDocument(uri, text)
+	s.documents[uri] = d
+	s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: uri, Diagnostics: d.diagnostics()})
+}
+
+func (d *document) diagnostics() []Diagnostic {
+	diagnostics := []Diagnostic{}
+	for _, err := range d.errors {
+		var sdlErr *sdlang.SdlError
+		if !errors.As(err, &sdlErr) {
+			continue
+		}
+		diagnostic := Diagnostic{
+			Range:    d.errorRange(sdlErr.Location),
+			Severity: severityError,
+			Source:   "sdlang",
+			Message:  sdlErr.Message,
+		}
+		for _, related := range sdlErr.Related {
+			diagnostic.RelatedInformation = append(diagnostic.RelatedInformation, DiagnosticRelatedInformation{
+				Location: Location{URI: d.uri, Range: pointRange(toPosition(related.Location))},
+				Message:  related.Message,
+			})
+		}
+		diagnostics = append(diagnostics, diagnostic)
+	}
+	return diagnostics
+}
+
+// errorRange is the range covered by an error, which is the single character at its location unless it has an end.
+func (d *document) errorRange(loc sdlang.SdlDebugLocation) Range {
+	start := toPosition(loc)
+	if loc.End.LineNumber == 0 || loc.End.LineNumber > len(d.lines) {
+		return pointRange(start)
+	}
+	end := loc
+	end.Line, end.LineNumber, end.Loc = d.lines[loc.End.LineNumber-1], loc.End.LineNumber, loc.End.Loc
+	return Range{start, toPosition(end)}
+}
+
+// pointRange is a range covering the single character at `pos`.
+func pointRange(pos Position) Range {
+	return Range{pos, Position{Line: pos.Line, Character: pos.Character + 1}}
+}
+
+func (d *document) symbols(tags []*tagNode) []DocumentSymbol {
+	symbols := []DocumentSymbol{}
+	for _, tag := range tags {
+		var detail []string
+		for _, value := range tag.values {
+			detail = append(detail, d.textInRange(value.rng))
+		}
+		kind := symbolKindObject
+		if tag.namespace != "" {
+			kind = symbolKindNamespace
+		}
+		symbols = append(symbols, DocumentSymbol{
+			Name:           tag.qualifiedName(),
+			Detail:         strings.Join(detail, " "),
+			Kind:           kind,
+			Range:          tag.rng,
+			SelectionRange: tag.nameRange,
+			Children:       d.symbols(tag.children),
+		})
+	}
+	return symbols
+}
+
+// hover describes the value, attribute, or tag name at `pos`.
+func (d *document) hover(pos Position, schema *schemaTag) *Hover {
+	var result *Hover
+	describe := func(r Range, text string) {
+		r2 := r
+		result = &Hover{Contents: MarkupContent{Kind: "markdown", Value: text}, Range: &r2}
+	}
+
+	d.walk(func(tag *tagNode) {
+		if result != nil {
+			return
+		}
+		for _, value := range tag.values {
+			if value.rng.contains(pos) {
+				describe(value.rng, fmt.Sprintf("`%s` value", valueKind(value.value)))
+				return
+			}
+		}
+		for _, attr := range tag.attributes {
+			if attr.nameRange.contains(pos) || attr.valueRange.contains(pos) {
+				text := fmt.Sprintf("attribute `%s`: `%s` value", attr.attr.QualifiedName, valueKind(attr.attr.Value))
+				for _, described := range schema.find(tag).attributesOrNil() {
+					if described.name == attr.attr.QualifiedName && described.description != "" {
+						text += "\n\n" + described.description
+					}
+				}
+				describe(Range{attr.nameRange.Start, attr.valueRange.End}, text)
+				return
+			}
+		}
+		if !tag.anonymous && tag.nameRange.contains(pos) {
+			text := fmt.Sprintf("tag `%s`: %s, %s, %s", tag.qualifiedName(),
+				plural(len(tag.values), "value"), plural(len(tag.attributes), "attribute"), plural(len(tag.children), "child"))
+			if described := schema.find(tag); described != nil && described.description != "" {
+				text += "\n\n" + described.description
+			}
+			describe(tag.nameRange, text)
+		}
+	})
+	return result
+}
+
+func (s *schemaTag) attributesOrNil() []schemaAttribute {
+	if s == nil {
+		return nil
+	}
+	return s.attributes
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	} else if noun == "child" {
+		return fmt.Sprintf("%d children", n)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// format replaces the entire document with its formatted text, or does nothing if it can't be parsed.
+func (d *document) format(indent string) []TextEdit {
+	p := sdlang.SaxParser{Input: d.text}
+	formatted, err := p.Format(sdlang.FormatOptions{Indent: indent})
+	if err != nil || formatted == d.text {
+		return []TextEdit{}
+	}
+	return []TextEdit{{Range: Range{End: d.end()}, NewText: formatted}}
+}
+
+func (d *document) foldingRanges() []FoldingRange {
+	ranges := []FoldingRange{}
+	d.walk(func(tag *tagNode) {
+		if d.hasBlock(tag) {
+			// The closing brace is left visible.
+			ranges = append(ranges, FoldingRange{StartLine: tag.rng.Start.Line, EndLine: tag.rng.End.Line - 1})
+		}
+	})
+	return ranges
+}
+
+// complete suggests tag names at the start of a line, and attribute names after a tag name, using the schema.
+func (d *document) complete(pos Position, schema *schemaTag) []CompletionItem {
+	items := []CompletionItem{}
+	if schema == nil || pos.Line < 0 || pos.Line >= len(d.lines) {
+		return items
+	}
+
+	line := d.lines[pos.Line]
+	typed := strings.TrimLeft(line[:byteOffset(line, pos.Character)], " \t")
+	if !strings.ContainsAny(typed, " \t") {
+		// The only thing on the line is (the start of) a tag name, so suggest the tags allowed in the enclosing block.
+		parent := d.root
+		d.walk(func(tag *tagNode) {
+			if d.hasBlock(tag) && tag.rng.Start.Line < pos.Line && pos.Line <= tag.rng.End.Line {
+				parent = tag // Parents are visited first, so this ends up as the innermost block.
+			}
+		})
+		for _, tag := range schema.find(parent).tagsOrNil() {
+			items = append(items, CompletionItem{Label: tag.name, Kind: completionKindClass, Documentation: tag.description})
+		}
+		return items
+	}
+
+	tags := d.tagsOnLine(pos.Line)
+	if len(tags) == 0 {
+		return items
+	}
+	tag := tags[len(tags)-1]
+	for _, attr := range schema.find(tag).attributesOrNil() {
+		used := false
+		for _, existing := range tag.attributes {
+			used = used || existing.attr.QualifiedName == attr.name
+		}
+		if !used {
+			items = append(items, CompletionItem{Label: attr.name, Kind: completionKindProperty, Documentation: attr.description, InsertText: attr.name + "="})
+		}
+	}
+	return items
+}
+
+func (s *schemaTag) tagsOrNil() []*schemaTag {
+	if s == nil {
+		return nil
+	}
+	return s.tags
+}
@@ -0,0 +1,423 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testURI = "file:///test.sdl"
+
+// session is a list of messages for the server to handle, which are sent once it's run.
+type session struct {
+	in bytes.Buffer
+}
+
+func (s *session) request(id int, method string, params interface{}) {
+	s.send(&id, method, params)
+}
+
+func (s *session) notify(method string, params interface{}) {
+	s.send(nil, method, params)
+}
+
+func (s *session) send(id *int, method string, params interface{}) {
+	msg := message{Method: method}
+	if id != nil {
+		raw := json.RawMessage(fmtInt(*id))
+		msg.ID = &raw
+	}
+	if params != nil {
+		msg.Params, _ = json.Marshal(params)
+	}
+	_ = writeMessage(&s.in, msg)
+}
+
+func fmtInt(i int) string {
+	b, _ := json.Marshal(i)
+	return string(b)
+}
+
+// run runs the server over the session's messages, and returns everything it sent back along with its exit code.
+func (s *session) run(t *testing.T, schema *schemaTag) ([]message, int) {
+	var out bytes.Buffer
+	code := newServer(&out, schema).serve(&s.in)
+
+	var messages []message
+	r := bufio.NewReader(&out)
+	for r.Buffered() > 0 || out.Len() > 0 {
+		msg, err := readMessage(r)
+		if !assert.NoError(t, err) {
+			break
+		}
+		messages = append(messages, msg)
+	}
+	return messages, code
+}
+
+// open starts a session with a single document open.
+func open(text string) *session {
+	s := &session{}
+	s.request(1, "initialize", map[string]interface{}{})
+	s.notify("initialized", map[string]interface{}{})
+	s.notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": TextDocumentItem{URI: testURI, LanguageID: "sdlang", Version: 1, Text: text},
+	})
+	return s
+}
+
+func (s *session) end() {
+	s.request(99, "shutdown", nil)
+	s.notify("exit", nil)
+}
+
+// response finds the response to the request with `id`, and decodes its result into `result`.
+func response(t *testing.T, messages []message, id int, result interface{}) *responseError {
+	for _, msg := range messages {
+		if msg.ID != nil && string(*msg.ID) == fmtInt(id) {
+			if msg.Error != nil {
+				return msg.Error
+			}
+			assert.NoError(t, json.Unmarshal(msg.Result, result))
+			return nil
+		}
+	}
+	t.Fatalf("no response to request %d", id)
+	return nil
+}
+
+func diagnostics(t *testing.T, messages []message) []PublishDiagnosticsParams {
+	var published []PublishDiagnosticsParams
+	for _, msg := range messages {
+		if msg.Method == "textDocument/publishDiagnostics" {
+			var params PublishDiagnosticsParams
+			assert.NoError(t, json.Unmarshal(msg.Params, &params))
+			published = append(published, params)
+		}
+	}
+	return published
+}
+
+func at(line, character int) map[string]interface{} {
+	return map[string]interface{}{
+		"textDocument": TextDocumentIdentifier{URI: testURI},
+		"position":     Position{Line: line, Character: character},
+	}
+}
+
+func docParams(extra map[string]interface{}) map[string]interface{} {
+	params := map[string]interface{}{"textDocument": TextDocumentIdentifier{URI: testURI}}
+	for k, v := range extra {
+		params[k] = v
+	}
+	return params
+}
+
+func TestLifecycle(t *testing.T) {
+	s := open("")
+	s.end()
+	messages, code := s.run(t, nil)
+	assert.Equal(t, 0, code)
+
+	var result InitializeResult
+	assert.Nil(t, response(t, messages, 1, &result))
+	assert.Equal(t, "sdl-lsp", result.ServerInfo.Name)
+	assert.Equal(t, textDocumentSyncFull, result.Capabilities.TextDocumentSync)
+	assert.True(t, result.Capabilities.HoverProvider)
+	assert.Nil(t, result.Capabilities.CompletionProvider, "completion needs a schema")
+
+	// Shutdown's result is null, which must still be sent.
+	for _, msg := range messages {
+		if msg.ID != nil && string(*msg.ID) == "99" {
+			assert.Equal(t, "null", string(msg.Result))
+		}
+	}
+}
+
+func TestExitWithoutShutdown(t *testing.T) {
+	s := open("")
+	s.notify("exit", nil)
+	_, code := s.run(t, nil)
+	assert.Equal(t, 1, code)
+
+	_, code = (&session{}).run(t, nil)
+	assert.Equal(t, 1, code, "the client going away without exiting is an error")
+}
+
+func TestUnknownMethod(t *testing.T) {
+	s := open("")
+	s.request(2, "textDocument/rename", at(0, 0))
+	s.notify("textDocument/didSave", docParams(nil))
+	s.request(3, "textDocument/hover", map[string]interface{}{
+		"textDocument": TextDocumentIdentifier{URI: "file:///missing.sdl"},
+	})
+	s.end()
+	messages, _ := s.run(t, nil)
+
+	var result interface{}
+	err := response(t, messages, 2, &result)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, codeMethodNotFound, err.Code)
+	}
+	err = response(t, messages, 3, &result)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, codeInvalidParams, err.Code)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	s := open("a 1\nb \"abc\nc")
+	s.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   TextDocumentIdentifier{URI: testURI},
+		"contentChanges": []map[string]string{{"text": "a 1"}},
+	})
+	s.notify("textDocument/didClose", docParams(nil))
+	s.end()
+	messages, _ := s.run(t, nil)
+
+	published := diagnostics(t, messages)
+	if !assert.Len(t, published, 3) {
+		return
+	}
+	if assert.Len(t, published[0].Diagnostics, 1) {
+		d := published[0].Diagnostics[0]
+		assert.Equal(t, severityError, d.Severity)
+		assert.Equal(t, Range{Position{1, 2}, Position{1, 3}}, d.Range)
+		assert.Contains(t, d.Message, "string")
+	}
+	assert.Empty(t, published[1].Diagnostics, "fixing the document clears its diagnostics")
+	assert.Empty(t, published[2].Diagnostics, "closing the document clears its diagnostics")
+	assert.Equal(t, testURI, published[2].URI)
+
+	// Errors about a whole token cover all of it.
+	s = open("é 99999999999999999999\n")
+	s.end()
+	messages, _ = s.run(t, nil)
+	published = diagnostics(t, messages)
+	if assert.Len(t, published, 1) && assert.Len(t, published[0].Diagnostics, 1) {
+		assert.Equal(t, Range{Position{0, 2}, Position{0, 22}}, published[0].Diagnostics[0].Range)
+	}
+}
+
+func TestDocumentSymbol(t *testing.T) {
+	s := open("server \"main\" port=80 {\n\troute \"/\"\n}\nns:thing\n\"anonymous\"")
+	s.request(2, "textDocument/documentSymbol", docParams(nil))
+	s.end()
+	messages, _ := s.run(t, nil)
+
+	var symbols []DocumentSymbol
+	assert.Nil(t, response(t, messages, 2, &symbols))
+	if !assert.Len(t, symbols, 3) {
+		return
+	}
+
+	server := symbols[0]
+	assert.Equal(t, "server", server.Name)
+	assert.Equal(t, `"main"`, server.Detail)
+	assert.Equal(t, symbolKindObject, server.Kind)
+	assert.Equal(t, Range{Position{0, 0}, Position{0, 6}}, server.SelectionRange)
+	assert.Equal(t, Position{0, 0}, server.Range.Start)
+	assert.Equal(t, 2, server.Range.End.Line)
+	if assert.Len(t, server.Children, 1) {
+		assert.Equal(t, "route", server.Children[0].Name)
+		assert.Equal(t, Range{Position{1, 1}, Position{1, 6}}, server.Children[0].SelectionRange)
+	}
+
+	assert.Equal(t, "ns:thing", symbols[1].Name)
+	assert.Equal(t, symbolKindNamespace, symbols[1].Kind)
+	assert.Equal(t, "content", symbols[2].Name)
+	assert.Equal(t, `"anonymous"`, symbols[2].Detail)
+}
+
+func TestHover(t *testing.T) {
+	s := open("server 1.5 \"é\" port=80 {\n\troute \"/\"\n}")
+	s.request(2, "textDocument/hover", at(0, 8))   // 1.5
+	s.request(3, "textDocument/hover", at(0, 13))  // "é"
+	s.request(4, "textDocument/hover", at(0, 16))  // port
+	s.request(5, "textDocument/hover", at(0, 2))   // server
+	s.request(6, "textDocument/hover", at(2, 0))   // }
+	s.request(7, "textDocument/hover", at(10, 10)) // Past the end of the document.
+	s.end()
+	schema := &schemaTag{tags: []*schemaTag{{name: "server", description: "A server.", attributes: []schemaAttribute{{name: "port", description: "Defaults to 80."}}}}}
+	messages, _ := s.run(t, schema)
+
+	var hover *Hover
+	assert.Nil(t, response(t, messages, 2, &hover))
+	if assert.NotNil(t, hover) {
+		assert.Equal(t, "`float` value", hover.Contents.Value)
+		assert.Equal(t, &Range{Position{0, 7}, Position{0, 10}}, hover.Range)
+	}
+
+	hover = nil
+	assert.Nil(t, response(t, messages, 3, &hover))
+	if assert.NotNil(t, hover) {
+		assert.Equal(t, "`string` value", hover.Contents.Value)
+	}
+
+	hover = nil
+	assert.Nil(t, response(t, messages, 4, &hover))
+	if assert.NotNil(t, hover) {
+		assert.Equal(t, "attribute `port`: `integer` value\n\nDefaults to 80.", hover.Contents.Value)
+		assert.Equal(t, &Range{Position{0, 15}, Position{0, 22}}, hover.Range)
+	}
+
+	hover = nil
+	assert.Nil(t, response(t, messages, 5, &hover))
+	if assert.NotNil(t, hover) {
+		assert.Equal(t, "tag `server`: 2 values, 1 attribute, 1 child\n\nA server.", hover.Contents.Value)
+	}
+
+	hover = &Hover{}
+	assert.Nil(t, response(t, messages, 6, &hover))
+	assert.Nil(t, hover)
+	hover = &Hover{}
+	assert.Nil(t, response(t, messages, 7, &hover))
+	assert.Nil(t, hover)
+}
+
+func TestFormatting(t *testing.T) {
+	s := open("a {\nb 1;c 2\n}")
+	s.request(2, "textDocument/formatting", docParams(map[string]interface{}{
+		"options": map[string]interface{}{"tabSize": 2, "insertSpaces": true},
+	}))
+	s.request(3, "textDocument/formatting", docParams(map[string]interface{}{
+		"options": map[string]interface{}{"tabSize": 4, "insertSpaces": false},
+	}))
+	s.end()
+	messages, _ := s.run(t, nil)
+
+	var edits []TextEdit
+	assert.Nil(t, response(t, messages, 2, &edits))
+	if assert.Len(t, edits, 1) {
+		assert.Equal(t, "a {\n  b 1\n  c 2\n}\n", edits[0].NewText)
+		assert.Equal(t, Range{Position{0, 0}, Position{2, 1}}, edits[0].Range)
+	}
+	assert.Nil(t, response(t, messages, 3, &edits))
+	if assert.Len(t, edits, 1) {
+		assert.Equal(t, "a {\n\tb 1\n\tc 2\n}\n", edits[0].NewText)
+	}
+
+	// Documents that are already formatted, or that can't be parsed, aren't changed.
+	for _, text := range []string{"a {\n\tb 1\n}\n", "a \"unterminated"} {
+		s = open(text)
+		s.request(2, "textDocument/formatting", docParams(map[string]interface{}{"options": map[string]interface{}{}}))
+		s.end()
+		messages, _ = s.run(t, nil)
+		edits = nil
+		assert.Nil(t, response(t, messages, 2, &edits))
+		assert.NotNil(t, edits, text)
+		assert.Empty(t, edits, text)
+	}
+}
+
+func TestFoldingRange(t *testing.T) {
+	s := open("a {\n\tb {\n\t\tc\n\t}\n\td\n}\ne")
+	s.request(2, "textDocument/foldingRange", docParams(nil))
+	s.end()
+	messages, _ := s.run(t, nil)
+
+	var ranges []FoldingRange
+	assert.Nil(t, response(t, messages, 2, &ranges))
+	assert.Equal(t, []FoldingRange{{StartLine: 0, EndLine: 4}, {StartLine: 1, EndLine: 2}}, ranges)
+}
+
+func TestCompletion(t *testing.T) {
+	dir := t.TempDir()
+	schemaFile := filepath.Join(dir, "schema.sdl")
+	assert.NoError(t, os.WriteFile(schemaFile, []byte(`
+tag "server" description="A server" {
+	attribute "host"
+	attribute "port" description="Defaults to 80"
+	tag "route"
+}
+tag "client"
+`), 0o600))
+
+	s := &session{}
+	s.request(1, "initialize", map[string]interface{}{"initializationOptions": map[string]string{"schema": schemaFile}})
+	s.notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": TextDocumentItem{URI: testURI, Text: "se\nserver port=80 {\n\tr\n}\n"},
+	})
+	s.request(2, "textDocument/completion", at(0, 2))  // Tags at the root.
+	s.request(3, "textDocument/completion", at(1, 7))  // Attributes of server.
+	s.request(4, "textDocument/completion", at(2, 2))  // Tags within server.
+	s.request(5, "textDocument/completion", at(0, 0))  // An empty line.
+	s.request(6, "textDocument/completion", at(10, 0)) // Past the end of the document.
+	s.request(7, "textDocument/completion", at(-1, 0)) // Before the start of the document.
+	s.end()
+	messages, _ := s.run(t, nil)
+
+	var result InitializeResult
+	assert.Nil(t, response(t, messages, 1, &result))
+	assert.NotNil(t, result.Capabilities.CompletionProvider)
+
+	labels := func(id int) []string {
+		var items []CompletionItem
+		assert.Nil(t, response(t, messages, id, &items))
+		labels := []string{}
+		for _, item := range items {
+			labels = append(labels, item.Label)
+		}
+		return labels
+	}
+	assert.Equal(t, []string{"server", "client"}, labels(2))
+	assert.Equal(t, []string{"host"}, labels(3), "port is already set")
+	assert.Equal(t, []string{"route"}, labels(4))
+	assert.Equal(t, []string{"server", "client"}, labels(5))
+	assert.Equal(t, []string{}, labels(6))
+	assert.Equal(t, []string{}, labels(7))
+
+	var items []CompletionItem
+	assert.Nil(t, response(t, messages, 3, &items))
+	if assert.Len(t, items, 1) {
+		assert.Equal(t, "host=", items[0].InsertText)
+		assert.Equal(t, completionKindProperty, items[0].Kind)
+	}
+}
+
+func TestInitializeWithBadSchema(t *testing.T) {
+	dir := t.TempDir()
+	schemaFile := filepath.Join(dir, "schema.sdl")
+	assert.NoError(t, os.WriteFile(schemaFile, []byte(`thing "x"`), 0o600))
+
+	s := &session{}
+	s.request(1, "initialize", map[string]interface{}{"initializationOptions": map[string]string{"schema": schemaFile}})
+	s.request(2, "initialize", map[string]interface{}{"initializationOptions": map[string]string{"schema": filepath.Join(dir, "missing.sdl")}})
+	s.end()
+	messages, _ := s.run(t, nil)
+
+	var result InitializeResult
+	for _, id := range []int{1, 2} {
+		err := response(t, messages, id, &result)
+		if assert.NotNil(t, err) {
+			assert.Equal(t, codeInvalidParams, err.Code)
+			assert.Contains(t, err.Message, "Could not load schema")
+		}
+	}
+}
+
+func TestPanicsDontStopTheServer(t *testing.T) {
+	s := open("a\n")
+	s.request(2, "textDocument/hover", at(0, 0))
+	s.end()
+
+	var out bytes.Buffer
+	srv := newServer(&out, nil)
+	srv.documents["file:///broken.sdl"] = nil // Makes the hover below panic.
+	broken := message{Method: "textDocument/hover", Params: json.RawMessage(`{"textDocument":{"uri":"file:///broken.sdl"},"position":{"line":0,"character":0}}`)}
+	_, err := srv.handleSafely(broken)
+	var rpcErr *responseError
+	if assert.True(t, errors.As(err, &rpcErr)) {
+		assert.Equal(t, codeInternalError, rpcErr.Code)
+	}
+
+	// The server keeps handling requests after a panic.
+	assert.Equal(t, 0, srv.serve(&s.in))
+	assert.Contains(t, out.String(), `"id":2`)
+}
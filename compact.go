@@ -0,0 +1,398 @@
+package sdlang
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CompactDocument is a read-only representation of a parsed document that uses much less memory than SdlTag.
+//
+// Names and string values are interned, so each distinct one is only stored once. Tags, values, and attributes are
+// stored in contiguous arrays rather than as separate allocations. Locations are stored as byte offsets into the
+// source, and are only turned into SdlDebugLocations when they're asked for.
+//
+// CompactTag is used to navigate the document, and CompactTag.Tag materializes an SdlTag when the classic
+// representation is needed. A CompactDocument is safe to use from multiple goroutines.
+type CompactDocument struct {
+	source   string
+	fileName string
+	lines    []uint32 // Where each line after the first starts, so that a location can be found without scanning from the start.
+
+	names   []compactName
+	strings []string
+	times   []time.Time
+	data    []byte
+
+	tags   []compactTag // In the order they start in, so a tag's descendants directly follow it.
+	values []compactValue
+	attrs  []compactAttribute
+}
+
+// compactName is an interned tag or attribute name.
+type compactName struct {
+	namespace, name, qualifiedName string
+}
+
+type compactTag struct {
+	name       uint32
+	end        uint32 // The index after the tag's last descendant.
+	values     uint32
+	valueCount uint32
+	attrs      uint32
+	attrCount  uint32
+	start      uint32
+	stop       uint32
+}
+
+type compactValue struct {
+	// bits holds the value itself for numbers, bools and timespans, or an index into `strings`, `times`, or `data`.
+	bits  uint64
+	size  uint32 // The length of a binary value.
+	start uint32
+	end   uint32
+	tag   sdlValueTag
+}
+
+type compactAttribute struct {
+	name  uint32
+	start uint32
+	end   uint32
+	value compactValue
+}
+
+// CompactTag is a tag within a CompactDocument. It's a small handle that can be freely copied.
+type CompactTag struct {
+	doc   *CompactDocument
+	index uint32
+}
+
+// ParseIntoCompact is the same as ParseIntoAst, except the document is parsed into a CompactDocument.
+// Inputs larger than 4GiB aren't supported.
+func (p SaxParser) ParseIntoCompact() (*CompactDocument, error) {
+	if uint64(len(p.Input)) > math.MaxUint32 {
+		return nil, errors.New("the input is too large for a compact document")
+	}
+
+	b := compactBuilder{
+		parser: &p,
+		doc:    &CompactDocument{source: p.Input, fileName: p.FileName, names: []compactName{{}}},
+		names:  map[string]uint32{"": 0},
+		values: map[string]uint32{},
+		stack:  []uint32{0},
+	}
+	b.doc.tags = append(b.doc.tags, compactTag{})
+	if err := p.Parse(&b); err != nil {
+		return nil, err
+	}
+	b.doc.tags[0].end = uint32(len(b.doc.tags))
+	for at := 0; ; {
+		i := strings.IndexByte(p.Input[at:], '\n')
+		if i < 0 {
+			break
+		}
+		at += i + 1
+		b.doc.lines = append(b.doc.lines, uint32(at))
+	}
+	return b.doc, nil
+}
+
+// Source is the text the document was parsed from.
+func (d *CompactDocument) Source() string {
+	return d.source
+}
+
+// Root is the root tag, which is nameless and only contains children.
+func (d *CompactDocument) Root() CompactTag {
+	return CompactTag{doc: d, index: 0}
+}
+
+// TagCount is the amount of tags in the document, not including the root tag.
+func (d *CompactDocument) TagCount() int {
+	return len(d.tags) - 1
+}
+
+// Tag materializes the entire document as an SdlTag, which is identical to the one returned by ParseIntoAst.
+func (d *CompactDocument) Tag() SdlTag {
+	return d.Root().Tag()
+}
+
+func (t CompactTag) tag() *compactTag {
+	return &t.doc.tags[t.index]
+}
+
+func (t CompactTag) name() compactName {
+	return t.doc.names[t.tag().name]
+}
+
+// Namespace is the namespace of this tag.
+func (t CompactTag) Namespace() string {
+	return t.name().namespace
+}
+
+// Name is the name of this tag.
+func (t CompactTag) Name() string {
+	return t.name().name
+}
+
+// QualifiedName is the fully qualified ("namespace:name") name of this tag.
+func (t CompactTag) QualifiedName() string {
+	return t.name().qualifiedName
+}
+
+// DebugLocation is the span of this tag. It's the zero value for the root tag.
+func (t CompactTag) DebugLocation() SdlDebugLocation {
+	if t.index == 0 {
+		return SdlDebugLocation{}
+	}
+	return t.doc.locator(t.tag().start).spanAt(int(t.tag().start), int(t.tag().stop))
+}
+
+// ValueCount is the amount of values this tag has.
+func (t CompactTag) ValueCount() int {
+	return int(t.tag().valueCount)
+}
+
+// Value materializes the value at `index`, which must be less than ValueCount.
+func (t CompactTag) Value(index int) SdlValue {
+	if index < 0 || index >= t.ValueCount() {
+		panic("sdlang: value index out of range")
+	}
+	value := t.doc.values[int(t.tag().values)+index]
+	return t.doc.value(value, t.doc.locator(value.start))
+}
+
+// Values materializes every value of this tag.
+func (t CompactTag) Values() []SdlValue {
+	return t.doc.tagValues(t.tag(), t.doc.locator(t.tag().start))
+}
+
+// AttributeCount is the amount of attributes this tag has.
+func (t CompactTag) AttributeCount() int {
+	return int(t.tag().attrCount)
+}
+
+// Attribute materializes the attribute with the given qualified name, returning false if there isn't one.
+func (t CompactTag) Attribute(qualifiedName string) (SdlAttribute, bool) {
+	for _, attr := range t.doc.tagAttributes(t.tag()) {
+		if t.doc.names[attr.name].qualifiedName == qualifiedName {
+			return t.doc.attribute(attr, t.doc.locator(attr.start)), true
+		}
+	}
+	return SdlAttribute{}, false
+}
+
+// ForEachChild applies the function `f` onto each child of the tag.
+func (t CompactTag) ForEachChild(f func(child CompactTag)) {
+	for i := t.index + 1; i < t.tag().end; i = t.doc.tags[i].end {
+		f(CompactTag{doc: t.doc, index: i})
+	}
+}
+
+// Children returns every child of the tag.
+func (t CompactTag) Children() []CompactTag {
+	var children []CompactTag
+	t.ForEachChild(func(child CompactTag) {
+		children = append(children, child)
+	})
+	return children
+}
+
+// Tag materializes this tag and all of its descendants as an SdlTag.
+func (t CompactTag) Tag() SdlTag {
+	return t.doc.materialize(t.index, t.doc.locator(t.tag().start))
+}
+
+// locator is used to turn offsets into locations, starting from the line that `at` is on. Each caller needs its own,
+// as it remembers the most recent location. Locations should be requested in order, so that each one is found by
+// moving forward from the previous one.
+func (d *CompactDocument) locator(at uint32) *SaxParser {
+	locator := &SaxParser{Input: d.source, FileName: d.fileName}
+	if line := sort.Search(len(d.lines), func(i int) bool { return d.lines[i] > at }); line > 0 {
+		start := int(d.lines[line-1])
+		locator.pos = linePosition{offset: start, line: line + 1, lineStart: start, lineEnd: -1}
+	}
+	return locator
+}
+
+func (d *CompactDocument) materialize(index uint32, locator *SaxParser) SdlTag {
+	t := &d.tags[index]
+	name := d.names[t.name]
+	tag := SdlTag{Namespace: name.namespace, Name: name.name, QualifiedName: name.qualifiedName}
+	if index != 0 {
+		tag.DebugLocation = locator.locationAt(int(t.start))
+	}
+	tag.Values = d.tagValues(t, locator)
+	for _, attr := range d.tagAttributes(t) {
+		if tag.Attributes == nil {
+			tag.Attributes = make(map[string]SdlAttribute, t.attrCount)
+		}
+		tag.Attributes[d.names[attr.name].qualifiedName] = d.attribute(attr, locator)
+	}
+	for i := index + 1; i < t.end; i = d.tags[i].end {
+		tag.Children = append(tag.Children, d.materialize(i, locator))
+	}
+	// The tag's end is found last, as everything else in the tag comes before it.
+	if index != 0 {
+		tag.DebugLocation.End = locator.positionAt(int(t.stop))
+	}
+	return tag
+}
+
+func (d *CompactDocument) tagValues(t *compactTag, locator *SaxParser) []SdlValue {
+	if t.valueCount == 0 {
+		return nil
+	}
+	values := make([]SdlValue, t.valueCount)
+	for i, value := range d.values[t.values : t.values+t.valueCount] {
+		values[i] = d.value(value, locator)
+	}
+	return values
+}
+
+func (d *CompactDocument) tagAttributes(t *compactTag) []compactAttribute {
+	return d.attrs[t.attrs : t.attrs+t.attrCount]
+}
+
+func (d *CompactDocument) attribute(attr compactAttribute, locator *SaxParser) SdlAttribute {
+	name := d.names[attr.name]
+	location := locator.spanAt(int(attr.start), int(attr.end))
+	return SdlAttribute{
+		Namespace:     name.namespace,
+		Name:          name.name,
+		QualifiedName: name.qualifiedName,
+		Value:         d.value(attr.value, locator),
+		DebugLocation: location,
+	}
+}
+
+func (d *CompactDocument) value(v compactValue, locator *SaxParser) SdlValue {
+	value := SdlValue{tag: v.tag, DebugLocation: locator.spanAt(int(v.start), int(v.end))}
+	switch v.tag {
+	case tString:
+		value.vString = d.strings[v.bits]
+	case tInt:
+		value.vInt = int64(v.bits)
+	case tFloat:
+		value.vFloat = math.Float64frombits(v.bits)
+	case tBool:
+		value.vBool = v.bits != 0
+	case tDateTime:
+		value.vDateTime = d.times[v.bits]
+	case tTimeSpan:
+		value.vTimeSpan = time.Duration(v.bits)
+	case tBinary:
+		value.vBinary = append([]byte{}, d.data[v.bits:v.bits+uint64(v.size)]...)
+	}
+	return value
+}
+
+// compactBuilder is a SaxHandler that constructs a CompactDocument.
+type compactBuilder struct {
+	parser *SaxParser
+	doc    *CompactDocument
+	names  map[string]uint32 // Qualified name -> index into doc.names.
+	values map[string]uint32 // String value -> index into doc.strings.
+	stack  []uint32
+}
+
+func (b *compactBuilder) top() *compactTag {
+	return &b.doc.tags[b.stack[len(b.stack)-1]]
+}
+
+func (b *compactBuilder) intern(namespace, name string) uint32 {
+	qualified := qualifiedName(namespace, name)
+	index, exists := b.names[qualified]
+	if !exists {
+		index = uint32(len(b.doc.names))
+		b.names[qualified] = index
+		b.doc.names = append(b.doc.names, compactName{namespace: namespace, name: name, qualifiedName: qualified})
+	}
+	return index
+}
+
+func (b *compactBuilder) value(value SdlValue) compactValue {
+	v := compactValue{tag: value.tag, start: uint32(value.DebugLocation.Offset), end: uint32(value.DebugLocation.End.Offset)}
+	switch value.tag {
+	case tString:
+		index, exists := b.values[value.vString]
+		if !exists {
+			index = uint32(len(b.doc.strings))
+			b.values[value.vString] = index
+			b.doc.strings = append(b.doc.strings, value.vString)
+		}
+		v.bits = uint64(index)
+	case tInt:
+		v.bits = uint64(value.vInt)
+	case tFloat:
+		v.bits = math.Float64bits(value.vFloat)
+	case tBool:
+		if value.vBool {
+			v.bits = 1
+		}
+	case tDateTime:
+		v.bits = uint64(len(b.doc.times))
+		b.doc.times = append(b.doc.times, value.vDateTime)
+	case tTimeSpan:
+		v.bits = uint64(value.vTimeSpan)
+	case tBinary:
+		v.bits, v.size = uint64(len(b.doc.data)), uint32(len(value.vBinary))
+		b.doc.data = append(b.doc.data, value.vBinary...)
+	}
+	return v
+}
+
+func (b *compactBuilder) StartTag(namespace, name string) error {
+	b.stack = append(b.stack, uint32(len(b.doc.tags)))
+	b.doc.tags = append(b.doc.tags, compactTag{name: b.intern(namespace, name), start: uint32(b.parser.tokenStart)})
+	return nil
+}
+
+// Value and Attribute rely on a tag's values and attributes all being parsed before any of its children,
+// which keeps them contiguous.
+func (b *compactBuilder) Value(value SdlValue) error {
+	top := b.top()
+	if top.valueCount == 0 {
+		top.values = uint32(len(b.doc.values))
+	}
+	top.valueCount++
+	b.doc.values = append(b.doc.values, b.value(value))
+	return nil
+}
+
+func (b *compactBuilder) Attribute(attr SdlAttribute) error {
+	top := b.top()
+	a := compactAttribute{
+		name:  b.intern(attr.Namespace, attr.Name),
+		start: uint32(attr.DebugLocation.Offset),
+		end:   uint32(attr.DebugLocation.End.Offset),
+		value: b.value(attr.Value),
+	}
+	// Like ParseIntoAst, later attributes replace earlier ones with the same name.
+	for i := range b.doc.tagAttributes(top) {
+		if b.doc.attrs[top.attrs+uint32(i)].name == a.name {
+			b.doc.attrs[top.attrs+uint32(i)] = a
+			return nil
+		}
+	}
+	if top.attrCount == 0 {
+		top.attrs = uint32(len(b.doc.attrs))
+	}
+	top.attrCount++
+	b.doc.attrs = append(b.doc.attrs, a)
+	return nil
+}
+
+func (b *compactBuilder) EndTag() error {
+	top := b.top()
+	top.stop = uint32(b.parser.tagEnd)
+	top.end = uint32(len(b.doc.tags))
+	b.stack = b.stack[:len(b.stack)-1]
+	return nil
+}
+
+func (b *compactBuilder) Comment(text string) error {
+	return nil
+}
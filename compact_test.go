@@ -0,0 +1,119 @@
+package sdlang
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompactDocument(t *testing.T) {
+	input := "ns:a 1 \"two\" key=true ns:key=[aGk=] {\n\tb 2021/01/02 12:00:00 12:30:00 1.5 null\n\t\"two\"\n}\nc key=1 key=2\n"
+	p := SaxParser{Input: input, FileName: "test.sdl"}
+	doc, err := p.ParseIntoCompact()
+	assert.NoError(t, err)
+	assert.Equal(t, input, doc.Source())
+	assert.Equal(t, 4, doc.TagCount())
+
+	root := doc.Root()
+	assert.Equal(t, "", root.QualifiedName())
+	assert.Equal(t, SdlDebugLocation{}, root.DebugLocation())
+	children := root.Children()
+	assert.Equal(t, 2, len(children))
+
+	a := children[0]
+	assert.Equal(t, "ns", a.Namespace())
+	assert.Equal(t, "a", a.Name())
+	assert.Equal(t, "ns:a", a.QualifiedName())
+	assert.Equal(t, 1, a.DebugLocation().LineNumber)
+	assert.Equal(t, 4, a.DebugLocation().End.LineNumber)
+	assert.Equal(t, 2, a.ValueCount())
+	s, _ := a.Value(1).String()
+	assert.Equal(t, "two", s)
+	assert.Equal(t, 7, a.Value(1).DebugLocation.Loc)
+	assert.Equal(t, 2, a.AttributeCount())
+	attr, ok := a.Attribute("ns:key")
+	assert.True(t, ok)
+	assert.Equal(t, "ns", attr.Namespace)
+	b, _ := attr.Value.Binary()
+	assert.Equal(t, []byte("hi"), b)
+	_, ok = a.Attribute("missing")
+	assert.False(t, ok)
+
+	var names []string
+	a.ForEachChild(func(child CompactTag) {
+		names = append(names, child.QualifiedName())
+	})
+	assert.Equal(t, []string{"b", "content"}, names)
+	values := a.Children()[0].Values()
+	dt, _ := values[0].DateTime()
+	assert.Equal(t, time.Date(2021, 1, 2, 12, 0, 0, 0, time.UTC), dt.UTC())
+	ts, _ := values[1].TimeSpan()
+	assert.Equal(t, 12*time.Hour+30*time.Minute, ts)
+	assert.True(t, values[3].IsNull())
+	assert.Empty(t, a.Children()[1].Children())
+
+	// Later attributes replace earlier ones with the same name.
+	c := children[1]
+	assert.Equal(t, 1, c.AttributeCount())
+	attr, _ = c.Attribute("key")
+	i, _ := attr.Value.Int()
+	assert.Equal(t, int64(2), i)
+
+	// Names and strings are only stored once.
+	assert.Equal(t, 7, len(doc.names))
+	assert.Equal(t, []string{"two"}, doc.strings)
+
+	ast, _ := p.ParseIntoAst()
+	assert.Equal(t, ast, doc.Tag())
+	assert.Equal(t, ast.Children[0], a.Tag())
+
+	p = SaxParser{Input: "a \"unterminated\n"}
+	_, err = p.ParseIntoCompact()
+	assert.Error(t, err)
+}
+
+func TestCompactDocumentMatchesAst(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "conformance", "valid", "*.sdl"))
+	assert.NoError(t, err)
+	assert.NotEmpty(t, files)
+	for _, file := range files {
+		input, err := os.ReadFile(file)
+		assert.NoError(t, err)
+		p := SaxParser{Input: string(input), FileName: file}
+		ast, err := p.ParseIntoAst()
+		assert.NoError(t, err)
+		doc, err := p.ParseIntoCompact()
+		if assert.NoError(t, err, file) {
+			assert.Equal(t, ast, doc.Tag(), file)
+		}
+	}
+}
+
+func TestCompactLocatingIsLinear(t *testing.T) {
+	inputs := map[string]string{
+		"many lines in a tag": "a {\n" + strings.Repeat("\tb 1\n", 10000) + "}\n",
+		"many attributes":     "a" + strings.Repeat(" b=1", 10000) + "\n",
+		"many tags":           strings.Repeat("a 1\n", 10000),
+	}
+	for name, input := range inputs {
+		doc, err := SaxParser{Input: input}.ParseIntoCompact()
+		assert.NoError(t, err)
+
+		locator := doc.locator(0)
+		doc.materialize(0, locator)
+		assert.Less(t, locator.scanned, 4*len(input), name)
+
+		// Like CompactTag.DebugLocation, each tag gets its own locator, which should start from the tag's line.
+		scanned := 0
+		doc.Root().ForEachChild(func(child CompactTag) {
+			locator := doc.locator(child.tag().start)
+			locator.spanAt(int(child.tag().start), int(child.tag().stop))
+			scanned += locator.scanned
+		})
+		assert.Less(t, scanned, 4*len(input), name)
+	}
+}
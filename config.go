@@ -0,0 +1,257 @@
+package sdlang
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ConfigOptions configures LoadConfig.
+type ConfigOptions struct {
+	// Options limits how much input the config file, and every file it includes, may contain.
+	// MaxTags applies to all of the files put together, rather than to each file separately.
+	Options ParseOptions
+
+	// IncludeTagName is the qualified name of the tag that includes another file (see ParseIntoAstWithIncludes).
+	// Defaults to "include".
+	IncludeTagName string
+
+	// Validate is called with every newly parsed config before it's used. If it returns an error then the config is rejected.
+	Validate func(root *SdlTag) error
+
+	// Debounce is how long Watch waits for changes to stop before reloading the config. Defaults to 100ms.
+	Debounce time.Duration
+
+	// OnError is called by Watch whenever a reload fails.
+	OnError func(err error)
+}
+
+// ConfigUpdate describes a change to the config held by a ConfigLoader.
+type ConfigUpdate struct {
+	// Old and New are the config before and after the change.
+	Old *SdlTag
+	New *SdlTag
+
+	// Changes are the differences between Old and New, as produced by Diff.
+	Changes []Change
+}
+
+// ChangedPaths returns the path of every tag whose values, attributes or children changed, in the order
+// they're first mentioned by Changes. The root tag has an empty path. See Change.Path for the format of a path.
+func (u ConfigUpdate) ChangedPaths() []string {
+	var paths []string
+	seen := map[string]bool{}
+	for _, change := range u.Changes {
+		if !seen[change.Path] {
+			seen[change.Path] = true
+			paths = append(paths, change.Path)
+		}
+	}
+	return paths
+}
+
+// ConfigLoader holds the most recent valid version of a config file, and can reload it when the file changes.
+// It's safe to use from multiple goroutines.
+type ConfigLoader struct {
+	fileName string
+	opts     ConfigOptions
+	current  atomic.Value // *SdlTag
+
+	reloading sync.Mutex
+	files     []string
+
+	// notifying is held while reloading and notifying subscribers, so that they see updates in the order they're made.
+	// Unlike `reloading`, it isn't needed by Files, so subscribers can call it.
+	notifying sync.Mutex
+
+	subscribing sync.Mutex
+	subscribers []*func(ConfigUpdate)
+
+	watching func() // Called once Watch has started watching the files, which tests wait for.
+}
+
+// LoadConfig parses the given config file, along with every file it includes.
+func LoadConfig(fileName string, opts ConfigOptions) (*ConfigLoader, error) {
+	if opts.IncludeTagName == "" {
+		opts.IncludeTagName = "include"
+	}
+	if opts.Debounce <= 0 {
+		opts.Debounce = 100 * time.Millisecond
+	}
+
+	abs, err := filepath.Abs(fileName)
+	if err != nil {
+		return nil, err
+	}
+	l := &ConfigLoader{fileName: filepath.ToSlash(abs), opts: opts}
+
+	root, files, err := l.load()
+	if err != nil {
+		return nil, err
+	}
+	l.files = files
+	l.current.Store(root)
+	return l, nil
+}
+
+// Current returns the most recently loaded config. It must not be modified, as it's shared with every other caller.
+func (l *ConfigLoader) Current() *SdlTag {
+	return l.current.Load().(*SdlTag)
+}
+
+// Files returns the absolute path of every file the config was loaded from, starting with the config file itself.
+func (l *ConfigLoader) Files() []string {
+	l.reloading.Lock()
+	defer l.reloading.Unlock()
+	return append([]string{}, l.files...)
+}
+
+// Subscribe calls `f` whenever the config changes. Subscribers are called one at a time, in the order they
+// subscribed. They may call Current and Files, but must not call Reload themselves.
+// The returned function stops `f` from being called.
+func (l *ConfigLoader) Subscribe(f func(update ConfigUpdate)) (unsubscribe func()) {
+	l.subscribing.Lock()
+	defer l.subscribing.Unlock()
+	subscriber := &f
+	l.subscribers = append(l.subscribers, subscriber)
+
+	return func() {
+		l.subscribing.Lock()
+		defer l.subscribing.Unlock()
+		for i, s := range l.subscribers {
+			if s == subscriber {
+				l.subscribers = append(l.subscribers[:i:i], l.subscribers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Reload parses and validates the config again. If that succeeds, and the config is different, it replaces the current
+// config and subscribers are notified. Otherwise the current config is kept and the error is returned.
+func (l *ConfigLoader) Reload() error {
+	l.notifying.Lock()
+	defer l.notifying.Unlock()
+
+	update, err := l.reload()
+	if err != nil || update == nil {
+		return err
+	}
+
+	l.subscribing.Lock()
+	subscribers := append([]*func(ConfigUpdate){}, l.subscribers...)
+	l.subscribing.Unlock()
+	for _, f := range subscribers {
+		(*f)(*update)
+	}
+	return nil
+}
+
+// reload is Reload without notifying subscribers. It returns nil if the config didn't change.
+func (l *ConfigLoader) reload() (*ConfigUpdate, error) {
+	l.reloading.Lock()
+	defer l.reloading.Unlock()
+
+	root, files, err := l.load()
+	if err != nil {
+		// Keep watching the files that were used before as well, as the failure may be fixed by changing any of them.
+		for _, file := range l.files {
+			if !containsString(files, file) {
+				files = append(files, file)
+			}
+		}
+		l.files = files
+		return nil, err
+	}
+	l.files = files
+
+	old := l.Current()
+	changes := Diff(old, root)
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	l.current.Store(root)
+	return &ConfigUpdate{Old: old, New: root, Changes: changes}, nil
+}
+
+// Watch reloads the config whenever any of its files change, until the context is cancelled.
+// Changes are debounced, so a burst of writes causes a single reload. Failed reloads are passed to `opts.OnError`.
+//
+// Watching is only supported on Linux, where it uses inotify.
+func (l *ConfigLoader) Watch(ctx context.Context) error {
+	w, err := newFileWatcher()
+	if err != nil {
+		return err
+	}
+	defer w.close()
+
+	if err = w.watch(l.Files()); err != nil {
+		return err
+	}
+	if l.watching != nil {
+		l.watching()
+	}
+
+	var debounce <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case _, ok := <-w.changes:
+			if !ok {
+				return errors.New("the file watcher stopped unexpectedly")
+			}
+			debounce = time.After(l.opts.Debounce)
+
+		case <-debounce:
+			debounce = nil
+			if err = l.Reload(); err != nil && l.opts.OnError != nil {
+				l.opts.OnError(err)
+			}
+			if err = w.watch(l.Files()); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (l *ConfigLoader) load() (*SdlTag, []string, error) {
+	input, err := os.ReadFile(filepath.FromSlash(l.fileName))
+	if err != nil {
+		return nil, []string{l.fileName}, err
+	}
+
+	parser := SaxParser{Input: string(input), FileName: l.fileName, Options: l.opts.Options}
+	root, files, err := parser.parseWithIncludes(IncludeOptions{TagName: l.opts.IncludeTagName, FS: osFS{}})
+	if err != nil {
+		return nil, files, err
+	}
+	if l.opts.Validate != nil {
+		if err = l.opts.Validate(&root); err != nil {
+			return nil, files, err
+		}
+	}
+	return &root, files, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// osFS reads files directly from the operating system, using slash-separated paths that may be absolute.
+type osFS struct{}
+
+func (osFS) Open(name string) (fs.File, error) {
+	return os.Open(filepath.FromSlash(name))
+}
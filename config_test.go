@@ -0,0 +1,176 @@
+package sdlang
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfigFile(t *testing.T, fileName, contents string) {
+	t.Helper()
+	assert.NoError(t, os.WriteFile(fileName, []byte(contents), 0o644))
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	main := filepath.Join(dir, "main.sdl")
+	writeConfigFile(t, main, "name \"main\"\ninclude \"conf/common.sdl\"\n")
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "conf"), 0o755))
+	writeConfigFile(t, filepath.Join(dir, "conf", "common.sdl"), "timeout 30\n")
+
+	l, err := LoadConfig(main, ConfigOptions{})
+	assert.NoError(t, err)
+	expected := parseForTest(t, "", "name \"main\"\ntimeout 30\n")
+	assert.True(t, expected.Equal(*l.Current()), FormatChanges(Diff(&expected, l.Current())))
+	assert.Equal(t, []string{filepath.ToSlash(main), filepath.ToSlash(filepath.Join(dir, "conf", "common.sdl"))}, l.Files())
+
+	_, err = LoadConfig(filepath.Join(dir, "missing.sdl"), ConfigOptions{})
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+
+	_, err = LoadConfig(main, ConfigOptions{Options: ParseOptions{MaxTags: 1}})
+	assert.Error(t, err)
+
+	_, err = LoadConfig(main, ConfigOptions{Validate: func(root *SdlTag) error {
+		return errors.New("invalid config")
+	}})
+	assert.EqualError(t, err, "invalid config")
+}
+
+func TestConfigReload(t *testing.T) {
+	dir := t.TempDir()
+	main := filepath.Join(dir, "main.sdl")
+	other := filepath.Join(dir, "other.sdl")
+	writeConfigFile(t, main, "server \"a\" port=80\nlimits {\n\trate 10\n}\n")
+	writeConfigFile(t, other, "extra 1\n")
+
+	l, err := LoadConfig(main, ConfigOptions{Validate: func(root *SdlTag) error {
+		if len(root.Children) == 0 {
+			return errors.New("empty config")
+		}
+		return nil
+	}})
+	assert.NoError(t, err)
+	first := l.Current()
+
+	var updates []ConfigUpdate
+	var files []string
+	unsubscribe := l.Subscribe(func(update ConfigUpdate) {
+		updates = append(updates, update)
+		files = l.Files()
+	})
+
+	assert.NoError(t, l.Reload())
+	assert.Empty(t, updates, "unchanged configs don't notify subscribers")
+	assert.Same(t, first, l.Current())
+
+	writeConfigFile(t, main, "server \"a\" port=81\nlimits {\n\trate 20\n}\ninclude \"other.sdl\"\n")
+	assert.NoError(t, l.Reload())
+	if assert.Equal(t, 1, len(updates)) {
+		assert.Same(t, first, updates[0].Old)
+		assert.Same(t, l.Current(), updates[0].New)
+		assert.Equal(t, 3, len(updates[0].Changes))
+		assert.Equal(t, []string{"", "server", "limits/rate"}, updates[0].ChangedPaths())
+	}
+	assert.Equal(t, 2, len(l.Files()))
+	assert.Equal(t, l.Files(), files, "subscribers can see which files the new config came from")
+
+	// Failures keep the old config, and keep watching the old files.
+	second := l.Current()
+	writeConfigFile(t, main, "server \"unterminated\n")
+	assert.Error(t, l.Reload())
+	writeConfigFile(t, main, "")
+	assert.EqualError(t, l.Reload(), "empty config")
+	assert.Same(t, second, l.Current())
+	assert.Equal(t, 1, len(updates))
+	assert.Equal(t, []string{filepath.ToSlash(main), filepath.ToSlash(other)}, l.Files())
+
+	unsubscribe()
+	writeConfigFile(t, main, "server \"b\"\n")
+	assert.NoError(t, l.Reload())
+	assert.Equal(t, 1, len(updates))
+	server, _ := l.Current().Children[0].Values[0].String()
+	assert.Equal(t, "b", server)
+	assert.Equal(t, []string{filepath.ToSlash(main)}, l.Files())
+}
+
+func TestConfigWatch(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("watching is only supported on Linux")
+	}
+
+	dir := t.TempDir()
+	main := filepath.Join(dir, "main.sdl")
+	other := filepath.Join(dir, "other.sdl")
+	writeConfigFile(t, main, "version 1\ninclude \"other.sdl\"\n")
+	writeConfigFile(t, other, "extra 1\n")
+
+	errs := make(chan error, 10)
+	l, err := LoadConfig(main, ConfigOptions{Debounce: 10 * time.Millisecond, OnError: func(err error) { errs <- err }})
+	assert.NoError(t, err)
+	updates := make(chan ConfigUpdate, 10)
+	l.Subscribe(func(update ConfigUpdate) { updates <- update })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error)
+	watching := make(chan struct{})
+	l.watching = func() { close(watching) }
+	go func() { stopped <- l.Watch(ctx) }()
+
+	// Wait for the watcher to start, as changes made before then aren't noticed.
+	select {
+	case <-watching:
+	case err := <-stopped:
+		t.Fatalf("the watcher stopped: %v", err)
+	}
+
+	expectUpdate := func(changedPaths ...string) {
+		t.Helper()
+		select {
+		case update := <-updates:
+			assert.Equal(t, changedPaths, update.ChangedPaths(), FormatChanges(update.Changes))
+		case err := <-errs:
+			t.Fatalf("reload failed: %v", err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no update after the config changed")
+		}
+	}
+
+	writeConfigFile(t, main, "version 2\ninclude \"other.sdl\"\n")
+	expectUpdate("version")
+
+	// Included files are watched too, including when they're replaced by a rename.
+	writeConfigFile(t, other+".tmp", "extra 2\n")
+	assert.NoError(t, os.Rename(other+".tmp", other))
+	expectUpdate("extra")
+
+	// Other files in the same directory are ignored.
+	writeConfigFile(t, filepath.Join(dir, "unrelated.sdl"), "unrelated\n")
+
+	writeConfigFile(t, main, "version \"unterminated\n")
+	select {
+	case err := <-errs:
+		assert.Contains(t, err.Error(), "main.sdl")
+	case update := <-updates:
+		t.Fatalf("unexpected update: %s", FormatChanges(update.Changes))
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no error after the config was broken")
+	}
+	version, _ := l.Current().Children[0].Values[0].Int()
+	assert.Equal(t, int64(2), version)
+
+	writeConfigFile(t, main, "version 3\n")
+	expectUpdate("", "version")
+	version, _ = l.Current().Children[0].Values[0].Int()
+	assert.Equal(t, int64(3), version)
+
+	cancel()
+	assert.Equal(t, context.Canceled, <-stopped)
+	assert.Empty(t, updates)
+	assert.Empty(t, errs)
+}
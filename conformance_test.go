@@ -0,0 +1,161 @@
+package sdlang
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The conformance suite lives in testdata/conformance. Each .sdl file in `valid` has a .json file next to it holding
+// the expected AST, and each .sdl file in `invalid` has a .json file holding the expected error position.
+// Files in `recovery` are parsed with ParseIntoAstTolerant, and expect both the partial AST and every error.
+//
+// The expected output is a regression snapshot of this implementation's behaviour, which is edited by hand rather than
+// regenerated; see testdata/conformance/README.md. Error messages aren't compared, so that they can be reworded freely.
+
+type conformanceTag struct {
+	Namespace  string                      `json:"namespace,omitempty"`
+	Name       string                      `json:"name"`
+	Values     []conformanceValue          `json:"values,omitempty"`
+	Attributes map[string]conformanceValue `json:"attributes,omitempty"`
+	Children   []conformanceTag            `json:"children,omitempty"`
+}
+
+type conformanceValue struct {
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+type conformanceError struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+type conformanceRecovery struct {
+	Tags   []conformanceTag   `json:"tags"`
+	Errors []conformanceError `json:"errors"`
+}
+
+func toConformanceError(err error) conformanceError {
+	sdlErr := err.(*SdlError)
+	return conformanceError{
+		Line:   sdlErr.Location.LineNumber,
+		Column: sdlErr.Location.Loc + 1,
+	}
+}
+
+func toConformanceTag(tag SdlTag) conformanceTag {
+	out := conformanceTag{Namespace: tag.Namespace, Name: tag.Name}
+	for _, value := range tag.Values {
+		out.Values = append(out.Values, toConformanceValue(value))
+	}
+	for key, attr := range tag.Attributes {
+		if out.Attributes == nil {
+			out.Attributes = map[string]conformanceValue{}
+		}
+		out.Attributes[key] = toConformanceValue(attr.Value)
+	}
+	for _, child := range tag.Children {
+		out.Children = append(out.Children, toConformanceTag(child))
+	}
+	return out
+}
+
+func toConformanceValue(value SdlValue) conformanceValue {
+	switch value.tag {
+	case tString:
+		return conformanceValue{"string", value.vString}
+	case tInt:
+		return conformanceValue{"int", value.vInt}
+	case tFloat:
+		return conformanceValue{"float", value.vFloat}
+	case tBool:
+		return conformanceValue{"bool", value.vBool}
+	case tDateTime:
+		return conformanceValue{"datetime", value.vDateTime.Format(time.RFC3339Nano)}
+	case tTimeSpan:
+		return conformanceValue{"timespan", value.vTimeSpan.String()}
+	case tBinary:
+		return conformanceValue{"binary", base64.StdEncoding.EncodeToString(value.vBinary)}
+	default:
+		return conformanceValue{"null", nil}
+	}
+}
+
+// conformanceOutput parses `file`, returning the JSON that is compared against the expected output, and whether it failed to parse.
+func conformanceOutput(t *testing.T, file string) ([]byte, bool) {
+	input, err := os.ReadFile(file)
+	assert.NoError(t, err)
+
+	p := SaxParser{Input: string(input), FileName: filepath.Base(file)}
+	if strings.Contains(file, "recovery") {
+		root, errs := p.ParseIntoAstTolerant()
+		result := conformanceRecovery{Tags: toConformanceTag(root).Children}
+		for _, err := range errs {
+			result.Errors = append(result.Errors, toConformanceError(err))
+		}
+		output, err := json.MarshalIndent(result, "", "  ")
+		assert.NoError(t, err)
+		return append(output, '\n'), len(errs) > 0
+	}
+
+	root, parseErr := p.ParseIntoAst()
+
+	var result interface{}
+	if parseErr != nil {
+		if _, ok := parseErr.(*SdlError); !assert.True(t, ok, "expected an *SdlError, got %T", parseErr) {
+			return nil, true
+		}
+		result = toConformanceError(parseErr)
+	} else {
+		result = toConformanceTag(root).Children
+		if result.([]conformanceTag) == nil {
+			result = []conformanceTag{}
+		}
+	}
+
+	output, err := json.MarshalIndent(result, "", "  ")
+	assert.NoError(t, err)
+	return append(output, '\n'), parseErr != nil
+}
+
+func runConformance(t *testing.T, dir string, expectValid bool) {
+	files, err := filepath.Glob(filepath.Join("testdata", "conformance", dir, "*.sdl"))
+	assert.NoError(t, err)
+	assert.NotEmpty(t, files)
+
+	for _, file := range files {
+		file := file
+		t.Run(strings.TrimSuffix(filepath.Base(file), ".sdl"), func(t *testing.T) {
+			output, failed := conformanceOutput(t, file)
+			if output == nil {
+				return
+			}
+			assert.Equal(t, expectValid, !failed, "%s", output)
+
+			expectedFile := strings.TrimSuffix(file, ".sdl") + ".json"
+			expected, err := os.ReadFile(expectedFile)
+			if assert.NoError(t, err) {
+				assert.Equal(t, string(expected), string(output))
+			}
+		})
+	}
+}
+
+func TestConformanceValid(t *testing.T) {
+	runConformance(t, "valid", true)
+}
+
+func TestConformanceInvalid(t *testing.T) {
+	runConformance(t, "invalid", false)
+}
+
+func TestConformanceRecovery(t *testing.T) {
+	runConformance(t, "recovery", false)
+}
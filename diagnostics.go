@@ -0,0 +1,330 @@
+package sdlang
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/BradleyChatha/decorator"
+)
+
+// Severity is how serious a Diagnostic is.
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+	SeverityNote
+)
+
+// String returns "error", "warning", or "note", which are also the levels used by SARIF.
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	case SeverityNote:
+		return "note"
+	}
+	return "unknown"
+}
+
+// Diagnostic is a problem found within an SDLang document, which can be rendered for people with RenderDiagnostics,
+// or for tools with WriteDiagnosticsJSON and WriteDiagnosticsSARIF.
+type Diagnostic struct {
+	Severity Severity
+
+	// Rule identifies the kind of problem, such as "syntax". See SdlError.Rule. It may be empty.
+	Rule string
+
+	Message string
+
+	// Location is the span of the problem. It's the zero value for problems that aren't about a specific location,
+	// such as a file that couldn't be read, although File may still be set.
+	Location SdlDebugLocation
+
+	// Related contains additional locations that help explain the problem.
+	Related []SdlError
+
+	// Fix automatically fixes the problem. It's nil if the problem has to be fixed by hand. See ApplyFixes.
+	Fix *Fix
+}
+
+// DiagnosticsFromErrors converts errors into diagnostics, with one diagnostic per error.
+// FileErrors are flattened into a diagnostic for each of their errors.
+func DiagnosticsFromErrors(errs ...error) []Diagnostic {
+	var diagnostics []Diagnostic
+	for _, err := range errs {
+		var fileErrs FileErrors
+		var sdlErr *SdlError
+		var pathErr *fs.PathError
+		if errors.As(err, &fileErrs) {
+			diagnostics = append(diagnostics, DiagnosticsFromErrors(fileErrs...)...)
+		} else if errors.As(err, &sdlErr) {
+			d := Diagnostic{Rule: sdlErr.Rule, Message: sdlErr.Message, Location: sdlErr.Location, Related: sdlErr.Related}
+			if d.Location.End == (SdlPosition{}) {
+				// Errors at a single point cover the character they point at.
+				d.Location.End = SdlPosition{Offset: d.Location.Offset + 1, LineNumber: d.Location.LineNumber, Loc: d.Location.Loc + 1}
+			}
+			diagnostics = append(diagnostics, d)
+		} else if errors.As(err, &pathErr) {
+			diagnostics = append(diagnostics, Diagnostic{Message: err.Error(), Location: SdlDebugLocation{File: pathErr.Path}})
+		} else if err != nil {
+			diagnostics = append(diagnostics, Diagnostic{Message: err.Error()})
+		}
+	}
+	return diagnostics
+}
+
+// RenderOptions configures RenderDiagnostics.
+type RenderOptions struct {
+	// Color highlights each problem and its message using ANSI escape codes.
+	Color bool
+
+	// ContextLines is how many lines before and after each problem are shown. Lines can only be shown for files in Sources.
+	ContextLines int
+
+	// Sources maps file names to their contents.
+	Sources map[string]string
+}
+
+// RenderDiagnostics renders diagnostics as human-readable text, in the same style as SdlError.
+// Each diagnostic starts with a line such as "error[syntax]: Unterminated string", followed by the lines it points at.
+func RenderDiagnostics(diagnostics []Diagnostic, opts RenderOptions) string {
+	var b strings.Builder
+	for i, diagnostic := range diagnostics {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+
+		heading := diagnostic.Severity.String()
+		if diagnostic.Rule != "" {
+			heading += "[" + diagnostic.Rule + "]"
+		}
+		colour := decorator.LineColourEnum("")
+		if opts.Color {
+			colour = decorator.FgRed
+			if diagnostic.Severity == SeverityWarning {
+				colour = decorator.FgYellow
+			} else if diagnostic.Severity == SeverityNote {
+				colour = decorator.FgCyan
+			}
+			heading = decorator.Bold + string(colour) + heading + decorator.Normal + decorator.Bold
+		}
+		b.WriteString(heading + ": " + diagnostic.Message)
+		if opts.Color {
+			b.WriteString(decorator.Normal)
+		}
+		b.WriteByte('\n')
+
+		if diagnostic.Location.LineNumber == 0 {
+			if diagnostic.Location.File != "" {
+				b.WriteString("  in " + diagnostic.Location.File + "\n")
+			}
+			continue
+		}
+		var d decorator.Decorator
+		errs := append([]SdlError{{Location: diagnostic.Location, Message: diagnostic.Message}}, diagnostic.Related...)
+		decorate(&d, errs, opts, colour)
+		b.WriteString(d.String())
+	}
+	return b.String()
+}
+
+type jsonDiagnostic struct {
+	File      string        `json:"file,omitempty"`
+	Line      int           `json:"line,omitempty"`
+	Column    int           `json:"column,omitempty"`
+	EndLine   int           `json:"endLine,omitempty"`
+	EndColumn int           `json:"endColumn,omitempty"`
+	Severity  string        `json:"severity,omitempty"`
+	Rule      string        `json:"rule,omitempty"`
+	Message   string        `json:"message"`
+	Related   []jsonRelated `json:"related,omitempty"`
+}
+
+type jsonRelated struct {
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+// WriteDiagnosticsJSON writes each diagnostic as a JSON object on its own line, such as:
+//
+//	{"file":"a.sdl","line":1,"column":3,"endLine":1,"endColumn":4,"severity":"error","rule":"syntax","message":"Unexpected character."}
+//
+// Lines and columns start at 1, and columns are counted in Unicode code points. The end position is exclusive.
+// Fields are left out if they're empty, apart from "message". Related locations are in a "related" array.
+func WriteDiagnosticsJSON(w io.Writer, diagnostics []Diagnostic) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	for _, diagnostic := range diagnostics {
+		d := jsonDiagnostic{File: diagnostic.Location.File, Severity: diagnostic.Severity.String(), Rule: diagnostic.Rule, Message: diagnostic.Message}
+		if diagnostic.Location.LineNumber > 0 {
+			d.Line, d.Column = diagnostic.Location.LineNumber, diagnostic.Location.Loc+1
+			d.EndLine, d.EndColumn = diagnostic.Location.End.LineNumber, diagnostic.Location.End.Loc+1
+		}
+		for _, related := range diagnostic.Related {
+			d.Related = append(d.Related, jsonRelated{
+				File:    related.Location.File,
+				Line:    related.Location.LineNumber,
+				Column:  related.Location.Loc + 1,
+				Message: related.Message,
+			})
+		}
+		if err := encoder.Encode(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SARIFOptions describes the tool that found the diagnostics written by WriteDiagnosticsSARIF.
+type SARIFOptions struct {
+	// ToolName defaults to "sdlanggo".
+	ToolName       string
+	ToolVersion    string
+	InformationURI string
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool       sarifTool     `json:"tool"`
+	ColumnKind string        `json:"columnKind"`
+	Results    []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version,omitempty"`
+	InformationURI string      `json:"informationUri,omitempty"`
+	Rules          []sarifRule `json:"rules,omitempty"`
+}
+
+type sarifRule struct {
+	ID string `json:"id"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID           string          `json:"ruleId,omitempty"`
+	RuleIndex        *int            `json:"ruleIndex,omitempty"`
+	Level            string          `json:"level"`
+	Message          sarifMessage    `json:"message"`
+	Locations        []sarifLocation `json:"locations,omitempty"`
+	RelatedLocations []sarifLocation `json:"relatedLocations,omitempty"`
+}
+
+type sarifLocation struct {
+	ID               *int                  `json:"id,omitempty"`
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	Message          *sarifMessage         `json:"message,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndLine     int `json:"endLine,omitempty"`
+	EndColumn   int `json:"endColumn,omitempty"`
+}
+
+// WriteDiagnosticsSARIF writes the diagnostics as a SARIF 2.1.0 log with a single run, as used by code scanning tools.
+// Columns are counted in Unicode code points, and file names are written as relative or "file" URIs.
+func WriteDiagnosticsSARIF(w io.Writer, diagnostics []Diagnostic, opts SARIFOptions) error {
+	if opts.ToolName == "" {
+		opts.ToolName = "sdlanggo"
+	}
+
+	run := sarifRun{
+		Tool:       sarifTool{Driver: sarifDriver{Name: opts.ToolName, Version: opts.ToolVersion, InformationURI: opts.InformationURI}},
+		ColumnKind: "unicodeCodePoints",
+		Results:    []sarifResult{},
+	}
+	rules := map[string]int{}
+	for _, diagnostic := range diagnostics {
+		result := sarifResult{RuleID: diagnostic.Rule, Level: diagnostic.Severity.String(), Message: sarifMessage{diagnostic.Message}}
+		if diagnostic.Rule != "" {
+			index, exists := rules[diagnostic.Rule]
+			if !exists {
+				index = len(run.Tool.Driver.Rules)
+				rules[diagnostic.Rule] = index
+				run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{ID: diagnostic.Rule})
+			}
+			result.RuleIndex = &index
+		}
+
+		if diagnostic.Location.File != "" || diagnostic.Location.LineNumber > 0 {
+			location := sarifLocationAt(diagnostic.Location)
+			if location.PhysicalLocation.Region != nil && diagnostic.Location.End.LineNumber > 0 {
+				location.PhysicalLocation.Region.EndLine = diagnostic.Location.End.LineNumber
+				location.PhysicalLocation.Region.EndColumn = diagnostic.Location.End.Loc + 1
+			}
+			result.Locations = []sarifLocation{location}
+		}
+		for i, related := range diagnostic.Related {
+			id := i
+			location := sarifLocationAt(related.Location)
+			location.ID = &id
+			location.Message = &sarifMessage{related.Message}
+			result.RelatedLocations = append(result.RelatedLocations, location)
+		}
+		run.Results = append(run.Results, result)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	})
+}
+
+func sarifLocationAt(loc SdlDebugLocation) sarifLocation {
+	location := sarifLocation{PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: fileURI(loc.File)}}}
+	if loc.LineNumber > 0 {
+		location.PhysicalLocation.Region = &sarifRegion{StartLine: loc.LineNumber, StartColumn: loc.Loc + 1}
+	}
+	return location
+}
+
+// fileURI converts a file name into a URI reference, which is relative unless the file name is absolute.
+func fileURI(fileName string) string {
+	u := url.URL{Path: filepath.ToSlash(fileName)}
+	if filepath.IsAbs(fileName) || path.IsAbs(u.Path) {
+		u.Scheme = "file"
+		if !strings.HasPrefix(u.Path, "/") {
+			u.Path = "/" + u.Path // Windows paths such as C:/dir.
+		}
+	}
+	return u.String()
+}
@@ -0,0 +1,196 @@
+package sdlang
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const diagnosticsTestInput = "a 1\nb \"unterminated\nc 3\nd 4\n\te 123456789012\nf\n"
+
+func diagnosticsForTest() []Diagnostic {
+	p := SaxParser{Input: diagnosticsTestInput, FileName: "x.sdl", Options: ParseOptions{MaxTokenLength: 10}}
+	_, errs := p.ParseIntoAstTolerant()
+	return DiagnosticsFromErrors(errs...)
+}
+
+func TestDiagnosticsFromErrors(t *testing.T) {
+	diagnostics := diagnosticsForTest()
+	assert.Equal(t, 2, len(diagnostics))
+
+	assert.Equal(t, SeverityError, diagnostics[0].Severity)
+	assert.Equal(t, "syntax", diagnostics[0].Rule)
+	assert.Equal(t, "Unterminated string", diagnostics[0].Message)
+	assert.Equal(t, 2, diagnostics[0].Location.LineNumber)
+	assert.Equal(t, 2, diagnostics[0].Location.Loc)
+	assert.Equal(t, 3, diagnostics[0].Location.End.Loc, "errors at a single character end after it")
+	assert.Equal(t, 1, len(diagnostics[0].Related))
+
+	assert.Equal(t, "limit", diagnostics[1].Rule)
+	assert.Equal(t, 3, diagnostics[1].Location.Loc)
+	assert.Equal(t, 5, diagnostics[1].Location.End.LineNumber)
+	assert.Equal(t, 15, diagnostics[1].Location.End.Loc, "limit errors span the whole token")
+
+	p := SaxParser{Input: "a \"${b}\"\n"}
+	ast, _ := p.ParseIntoAst()
+	err := Interpolate(&ast, InterpolateOptions{})
+	_, pathErr := fs.ReadFile(fsForTest{}, "missing.sdl")
+	diagnostics = DiagnosticsFromErrors(FileErrors{err, pathErr}, errors.New("plain"), nil)
+	assert.Equal(t, 3, len(diagnostics))
+	assert.Equal(t, "interpolation", diagnostics[0].Rule)
+	assert.Equal(t, "missing.sdl", diagnostics[1].Location.File)
+	assert.Equal(t, 0, diagnostics[1].Location.LineNumber)
+	assert.Equal(t, Diagnostic{Message: "plain"}, diagnostics[2])
+
+	p = SaxParser{Input: "a\xff"}
+	_, err = p.ParseIntoAst()
+	assert.Equal(t, "encoding", DiagnosticsFromErrors(err)[0].Rule)
+}
+
+type fsForTest struct{}
+
+func (fsForTest) Open(name string) (fs.File, error) {
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+func TestRenderDiagnostics(t *testing.T) {
+	diagnostics := diagnosticsForTest()
+	diagnostics[1].Severity = SeverityWarning
+	diagnostics = append(diagnostics, Diagnostic{Severity: SeverityNote, Message: "could not read", Location: SdlDebugLocation{File: "y.sdl"}})
+
+	expected := `error[syntax]: Unterminated string
+x.sdl @ 1 | a 1
+x.sdl @ 2 | b "unterminated
+          |   │            │
+          |   v            │
+          |   Unterminated string
+          |                │
+          |                v
+          |                Expected a terminating '"' before hitting end of file/line
+x.sdl @ 3 | c 3
+
+warning[limit]: This token is longer than the maximum allowed length of 10 bytes.
+x.sdl @ 4 | d 4
+x.sdl @ 5 |  e 123456789012
+          |    │
+          |    v
+          |    This token is longer than the maximum allowed length of 10 bytes.
+x.sdl @ 6 | f
+
+note: could not read
+  in y.sdl
+`
+	assert.Equal(t, expected, RenderDiagnostics(diagnostics, RenderOptions{ContextLines: 1, Sources: map[string]string{"x.sdl": diagnosticsTestInput}}))
+
+	// Without the sources, only the lines with problems are shown.
+	plain := RenderDiagnostics(diagnostics[:1], RenderOptions{ContextLines: 1})
+	assert.Equal(t, "error[syntax]: Unterminated string\n"+(&SdlError{Location: diagnostics[0].Location, Message: diagnostics[0].Message, Related: diagnostics[0].Related}).Error(), plain)
+
+	coloured := RenderDiagnostics(diagnostics[:2], RenderOptions{Color: true})
+	assert.Contains(t, coloured, "\x1b[1m\x1b[31merror[syntax]\x1b[0m\x1b[1m: Unterminated string\x1b[0m\n")
+	assert.Contains(t, coloured, "x.sdl @ 2 | b \x1b[31m\"\x1b[0munterminated\n")
+	assert.Contains(t, coloured, "|   \x1b[31mUnterminated string\x1b[0m\n")
+	assert.Contains(t, coloured, "\x1b[36mExpected a terminating")
+	assert.Contains(t, coloured, "x.sdl @ 5 |  e \x1b[33m123456789012\x1b[0m\n")
+}
+
+func TestRenderDiagnosticsContextBetweenLines(t *testing.T) {
+	input := "1\n2\n3\n4\n5\n6\n7\n8\n9\n"
+	diagnostic := Diagnostic{
+		Message:  "first",
+		Location: SdlDebugLocation{File: "f", Line: "3", LineNumber: 3},
+		Related:  []SdlError{{Location: SdlDebugLocation{File: "f", Line: "5", LineNumber: 5}, Message: "second"}},
+	}
+	rendered := RenderDiagnostics([]Diagnostic{diagnostic}, RenderOptions{ContextLines: 2, Sources: map[string]string{"f": input}})
+
+	var lines []string
+	for _, line := range strings.Split(rendered, "\n") {
+		if strings.HasPrefix(line, "f @ ") {
+			lines = append(lines, line)
+		}
+	}
+	assert.Equal(t, []string{"f @ 1 | 1", "f @ 2 | 2", "f @ 3 | 3", "f @ 4 | 4", "f @ 5 | 5", "f @ 6 | 6", "f @ 7 | 7"}, lines)
+}
+
+func TestWriteDiagnosticsJSON(t *testing.T) {
+	var b bytes.Buffer
+	diagnostics := append(diagnosticsForTest(), Diagnostic{Severity: SeverityWarning, Message: "<no location>"})
+	assert.NoError(t, WriteDiagnosticsJSON(&b, diagnostics))
+	assert.Equal(t, `{"file":"x.sdl","line":2,"column":3,"endLine":2,"endColumn":4,"severity":"error","rule":"syntax","message":"Unterminated string","related":[{"file":"x.sdl","line":2,"column":16,"message":"Expected a terminating '\"' before hitting end of file/line"}]}
+{"file":"x.sdl","line":5,"column":4,"endLine":5,"endColumn":16,"severity":"error","rule":"limit","message":"This token is longer than the maximum allowed length of 10 bytes."}
+{"severity":"warning","message":"<no location>"}
+`, b.String())
+}
+
+func TestWriteDiagnosticsSARIF(t *testing.T) {
+	var b bytes.Buffer
+	diagnostics := append(diagnosticsForTest(),
+		Diagnostic{Severity: SeverityNote, Rule: "syntax", Message: "abs", Location: SdlDebugLocation{File: "/etc/a b.sdl", LineNumber: 1, Loc: 0}},
+		Diagnostic{Message: "nowhere"},
+	)
+	assert.NoError(t, WriteDiagnosticsSARIF(&b, diagnostics, SARIFOptions{ToolVersion: "1.0"}))
+
+	var log struct {
+		Version string
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Name    string
+					Version string
+					Rules   []struct{ ID string }
+				}
+			}
+			ColumnKind string
+			Results    []struct {
+				RuleID    string
+				RuleIndex *int
+				Level     string
+				Message   struct{ Text string }
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct{ URI string }
+						Region           *struct{ StartLine, StartColumn, EndLine, EndColumn int }
+					}
+				}
+				RelatedLocations []struct {
+					ID      int
+					Message struct{ Text string }
+				}
+			}
+		}
+	}
+	assert.NoError(t, json.Unmarshal(b.Bytes(), &log))
+	assert.Equal(t, "2.1.0", log.Version)
+	assert.Contains(t, b.String(), `"$schema": "https://json.schemastore.org/sarif-2.1.0.json"`)
+
+	run := log.Runs[0]
+	assert.Equal(t, "sdlanggo", run.Tool.Driver.Name)
+	assert.Equal(t, "1.0", run.Tool.Driver.Version)
+	assert.Equal(t, 2, len(run.Tool.Driver.Rules))
+	assert.Equal(t, "limit", run.Tool.Driver.Rules[1].ID)
+	assert.Equal(t, "unicodeCodePoints", run.ColumnKind)
+	assert.Equal(t, 4, len(run.Results))
+
+	limit := run.Results[1]
+	assert.Equal(t, "limit", limit.RuleID)
+	assert.Equal(t, 1, *limit.RuleIndex)
+	assert.Equal(t, "error", limit.Level)
+	assert.Equal(t, "x.sdl", limit.Locations[0].PhysicalLocation.ArtifactLocation.URI)
+	assert.Equal(t, struct{ StartLine, StartColumn, EndLine, EndColumn int }{5, 4, 5, 16}, *limit.Locations[0].PhysicalLocation.Region)
+	assert.Equal(t, "Expected a terminating '\"' before hitting end of file/line", run.Results[0].RelatedLocations[0].Message.Text)
+
+	assert.Equal(t, 0, *run.Results[2].RuleIndex)
+	assert.Equal(t, "note", run.Results[2].Level)
+	assert.Equal(t, "file:///etc/a%20b.sdl", run.Results[2].Locations[0].PhysicalLocation.ArtifactLocation.URI)
+	assert.Nil(t, run.Results[3].RuleIndex)
+	assert.Empty(t, run.Results[3].Locations)
+
+	b.Reset()
+	assert.NoError(t, WriteDiagnosticsSARIF(&b, nil, SARIFOptions{}))
+	assert.Contains(t, b.String(), `"results": []`)
+}
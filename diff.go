@@ -0,0 +1,459 @@
+package sdlang
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ChangeKind describes what kind of difference a Change represents.
+type ChangeKind int
+
+const (
+	ChildAdded ChangeKind = iota
+	ChildRemoved
+	ChildMoved
+	ValueAdded
+	ValueRemoved
+	ValueChanged
+	AttributeAdded
+	AttributeRemoved
+	AttributeChanged
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case ChildAdded:
+		return "child added"
+	case ChildRemoved:
+		return "child removed"
+	case ChildMoved:
+		return "child moved"
+	case ValueAdded:
+		return "value added"
+	case ValueRemoved:
+		return "value removed"
+	case ValueChanged:
+		return "value changed"
+	case AttributeAdded:
+		return "attribute added"
+	case AttributeRemoved:
+		return "attribute removed"
+	case AttributeChanged:
+		return "attribute changed"
+	}
+	return "unknown change"
+}
+
+// Change is a single structural difference between two tag trees, as produced by Diff.
+type Change struct {
+	Kind ChangeKind
+
+	// Path is the path to the tag containing the change, e.g. "servers/server[1]".
+	// Each segment is a qualified tag name followed by its index amongst siblings of the same name, unless the index is 0.
+	// The root tag has an empty path.
+	Path string
+
+	// Name is the qualified name of the child tag or attribute that changed. It is empty for value changes.
+	Name string
+
+	// OldIndex and NewIndex are the indicies of the changed child or value on either side, or -1 if it doesn't exist on that side.
+	OldIndex int
+	NewIndex int
+
+	// OldTag and NewTag point to the changed child on either side, or are nil if it doesn't exist on that side.
+	// These point into the trees passed to Diff.
+	OldTag *SdlTag
+	NewTag *SdlTag
+
+	// OldValue and NewValue are the changed value or attribute value on either side.
+	OldValue SdlValue
+	NewValue SdlValue
+
+	// OldLocation and NewLocation are where the change is on either side.
+	// If the changed item doesn't exist on one side, the location of the tag containing it is used instead.
+	OldLocation SdlDebugLocation
+	NewLocation SdlDebugLocation
+}
+
+// Diff computes the structural differences needed to turn `from` into `to`.
+// Debug locations are ignored when comparing, but are included in each Change.
+func Diff(from, to *SdlTag) []Change {
+	var changes []Change
+	diffTag(&changes, "", from, to)
+	return changes
+}
+
+func diffTag(changes *[]Change, path string, from, to *SdlTag) {
+	for i := 0; i < len(from.Values) || i < len(to.Values); i++ {
+		change := Change{Path: path, OldIndex: -1, NewIndex: -1, OldLocation: from.DebugLocation, NewLocation: to.DebugLocation}
+		if i < len(from.Values) {
+			change.OldIndex = i
+			change.OldValue = from.Values[i]
+			change.OldLocation = from.Values[i].DebugLocation
+		}
+		if i < len(to.Values) {
+			change.NewIndex = i
+			change.NewValue = to.Values[i]
+			change.NewLocation = to.Values[i].DebugLocation
+		}
+
+		if i >= len(to.Values) {
+			change.Kind = ValueRemoved
+		} else if i >= len(from.Values) {
+			change.Kind = ValueAdded
+		} else if !from.Values[i].Equal(to.Values[i]) {
+			change.Kind = ValueChanged
+		} else {
+			continue
+		}
+		*changes = append(*changes, change)
+	}
+
+	keys := from.sortedAttributeKeys()
+	for _, key := range to.sortedAttributeKeys() {
+		if _, exists := from.Attributes[key]; !exists {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		oldAttr, inOld := from.Attributes[key]
+		newAttr, inNew := to.Attributes[key]
+		change := Change{Path: path, Name: key, OldIndex: -1, NewIndex: -1, OldLocation: from.DebugLocation, NewLocation: to.DebugLocation}
+		if inOld {
+			change.OldValue = oldAttr.Value
+			change.OldLocation = oldAttr.DebugLocation
+		}
+		if inNew {
+			change.NewValue = newAttr.Value
+			change.NewLocation = newAttr.DebugLocation
+		}
+
+		if !inNew {
+			change.Kind = AttributeRemoved
+		} else if !inOld {
+			change.Kind = AttributeAdded
+		} else if !oldAttr.Value.Equal(newAttr.Value) {
+			change.Kind = AttributeChanged
+		} else {
+			continue
+		}
+		*changes = append(*changes, change)
+	}
+
+	diffChildren(changes, path, from, to)
+}
+
+type childPair struct {
+	from, to int
+}
+
+func diffChildren(changes *[]Change, path string, from, to *SdlTag) {
+	oldMatched := make([]bool, len(from.Children))
+	newMatched := make([]bool, len(to.Children))
+	var pairs []childPair
+
+	// First pair up children that are completely unchanged, so that moves can be detected.
+	byHash := map[uint64][]int{}
+	for j := range to.Children {
+		h := to.Children[j].Hash()
+		byHash[h] = append(byHash[h], j)
+	}
+	for i := range from.Children {
+		candidates := byHash[from.Children[i].Hash()]
+		for k, j := range candidates {
+			if from.Children[i].Equal(to.Children[j]) {
+				pairs = append(pairs, childPair{i, j})
+				oldMatched[i] = true
+				newMatched[j] = true
+				byHash[from.Children[i].Hash()] = append(candidates[:k:k], candidates[k+1:]...)
+				break
+			}
+		}
+	}
+
+	// Then pair up the remaining children by name, in order of appearance, as these have been modified.
+	unmatchedByName := map[string][]int{}
+	for j := range to.Children {
+		if !newMatched[j] {
+			name := to.Children[j].QualifiedName
+			unmatchedByName[name] = append(unmatchedByName[name], j)
+		}
+	}
+	for i := range from.Children {
+		if oldMatched[i] {
+			continue
+		}
+		name := from.Children[i].QualifiedName
+		if candidates := unmatchedByName[name]; len(candidates) > 0 {
+			pairs = append(pairs, childPair{i, candidates[0]})
+			oldMatched[i] = true
+			newMatched[candidates[0]] = true
+			unmatchedByName[name] = candidates[1:]
+		}
+	}
+
+	sort.Slice(pairs, func(a, b int) bool { return pairs[a].from < pairs[b].from })
+	inOrder := longestIncreasingPairs(pairs)
+
+	for i := range from.Children {
+		if !oldMatched[i] {
+			*changes = append(*changes, Change{
+				Kind:        ChildRemoved,
+				Path:        path,
+				Name:        from.Children[i].QualifiedName,
+				OldIndex:    i,
+				NewIndex:    -1,
+				OldTag:      &from.Children[i],
+				OldLocation: from.Children[i].DebugLocation,
+				NewLocation: to.DebugLocation,
+			})
+		}
+	}
+	for j := range to.Children {
+		if !newMatched[j] {
+			*changes = append(*changes, Change{
+				Kind:        ChildAdded,
+				Path:        path,
+				Name:        to.Children[j].QualifiedName,
+				OldIndex:    -1,
+				NewIndex:    j,
+				NewTag:      &to.Children[j],
+				OldLocation: from.DebugLocation,
+				NewLocation: to.Children[j].DebugLocation,
+			})
+		}
+	}
+	for k, pair := range pairs {
+		oldChild := &from.Children[pair.from]
+		newChild := &to.Children[pair.to]
+		if !inOrder[k] {
+			*changes = append(*changes, Change{
+				Kind:        ChildMoved,
+				Path:        path,
+				Name:        oldChild.QualifiedName,
+				OldIndex:    pair.from,
+				NewIndex:    pair.to,
+				OldTag:      oldChild,
+				NewTag:      newChild,
+				OldLocation: oldChild.DebugLocation,
+				NewLocation: newChild.DebugLocation,
+			})
+		}
+		diffTag(changes, joinTagPath(path, from, pair.from), oldChild, newChild)
+	}
+}
+
+// longestIncreasingPairs marks the largest set of pairs (sorted by `from`) whose `to` indicies are also increasing.
+// Any pair not marked has been moved relative to the others.
+func longestIncreasingPairs(pairs []childPair) []bool {
+	// tails[n] is the index into pairs of the smallest ending element of an increasing run of length n+1.
+	var tails []int
+	prev := make([]int, len(pairs))
+	for i, pair := range pairs {
+		n := sort.Search(len(tails), func(k int) bool { return pairs[tails[k]].to >= pair.to })
+		if n > 0 {
+			prev[i] = tails[n-1]
+		} else {
+			prev[i] = -1
+		}
+		if n == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[n] = i
+		}
+	}
+
+	inOrder := make([]bool, len(pairs))
+	if len(tails) > 0 {
+		for i := tails[len(tails)-1]; i >= 0; i = prev[i] {
+			inOrder[i] = true
+		}
+	}
+	return inOrder
+}
+
+func joinTagPath(path string, parent *SdlTag, index int) string {
+	child := &parent.Children[index]
+	occurrence := 0
+	for i := 0; i < index; i++ {
+		if parent.Children[i].QualifiedName == child.QualifiedName {
+			occurrence++
+		}
+	}
+
+	segment := child.QualifiedName
+	if occurrence > 0 {
+		segment += "[" + strconv.Itoa(occurrence) + "]"
+	}
+	if path == "" {
+		return segment
+	}
+	return path + "/" + segment
+}
+
+// String renders this change as a single human-readable line.
+func (c Change) String() string {
+	var b strings.Builder
+	if c.Path == "" {
+		b.WriteString("/")
+	} else {
+		b.WriteString(c.Path)
+	}
+	b.WriteString(": ")
+
+	switch c.Kind {
+	case ChildAdded:
+		fmt.Fprintf(&b, "added child '%s' at index %d", c.Name, c.NewIndex)
+	case ChildRemoved:
+		fmt.Fprintf(&b, "removed child '%s' from index %d", c.Name, c.OldIndex)
+	case ChildMoved:
+		fmt.Fprintf(&b, "moved child '%s' from index %d to %d", c.Name, c.OldIndex, c.NewIndex)
+	case ValueAdded:
+		fmt.Fprintf(&b, "added value #%d %s", c.NewIndex, c.NewValue.literal())
+	case ValueRemoved:
+		fmt.Fprintf(&b, "removed value #%d %s", c.OldIndex, c.OldValue.literal())
+	case ValueChanged:
+		fmt.Fprintf(&b, "changed value #%d from %s to %s", c.OldIndex, c.OldValue.literal(), c.NewValue.literal())
+	case AttributeAdded:
+		fmt.Fprintf(&b, "added attribute %s=%s", c.Name, c.NewValue.literal())
+	case AttributeRemoved:
+		fmt.Fprintf(&b, "removed attribute %s=%s", c.Name, c.OldValue.literal())
+	case AttributeChanged:
+		fmt.Fprintf(&b, "changed attribute %s from %s to %s", c.Name, c.OldValue.literal(), c.NewValue.literal())
+	}
+
+	fmt.Fprintf(&b, " (%s -> %s)", c.OldLocation.position(), c.NewLocation.position())
+	return b.String()
+}
+
+// FormatChanges renders the given changes as human-readable text, one change per line.
+func FormatChanges(changes []Change) string {
+	var b strings.Builder
+	for _, change := range changes {
+		b.WriteString(change.String())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+func (l SdlDebugLocation) position() string {
+	if l.LineNumber == 0 {
+		return "?"
+	}
+	return fmt.Sprintf("%s @ %d:%d", l.File, l.LineNumber, l.Loc+1)
+}
+
+// literal renders this value as it would be written in SDLang.
+func (v SdlValue) literal() string {
+	switch v.tag {
+	case tNull:
+		return "null"
+	case tString:
+		return quoteString(v.vString)
+	case tInt:
+		return strconv.FormatInt(v.vInt, 10)
+	case tFloat:
+		text := strconv.FormatFloat(v.vFloat, 'f', -1, 64)
+		if !math.IsInf(v.vFloat, 0) && !math.IsNaN(v.vFloat) && !strings.ContainsRune(text, '.') {
+			text += ".0"
+		}
+		return text
+	case tDateTime:
+		return formatDateTime(v.vDateTime)
+	case tTimeSpan:
+		return formatTimeSpan(v.vTimeSpan)
+	case tBool:
+		return strconv.FormatBool(v.vBool)
+	case tBinary:
+		return "[" + base64.StdEncoding.EncodeToString(v.vBinary) + "]"
+	}
+	return "?"
+}
+
+// quoteString quotes `value` as a double-quoted string literal, using escapes that the parser decodes back into `value`.
+// Invalid UTF-8 can't be represented, so each invalid byte becomes U+FFFD.
+func quoteString(value string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range value {
+		switch r {
+		case '\n':
+			b.WriteString("\\n")
+		case '\t':
+			b.WriteString("\\t")
+		case '\r':
+			b.WriteString("\\r")
+		case '\f':
+			b.WriteString("\\f")
+		case '\b':
+			b.WriteString("\\b")
+		case 0:
+			b.WriteString("\\0")
+		case '"':
+			b.WriteString("\\\"")
+		case '\\':
+			b.WriteString("\\\\")
+		default:
+			if r < 0x20 || r == 0x7F || r == 0x2028 || r == 0x2029 {
+				fmt.Fprintf(&b, "\\u%04X", r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+func formatDateTime(value time.Time) string {
+	text := fmt.Sprintf("%04d/%02d/%02d", value.Year(), value.Month(), value.Day())
+	name, offset := value.Zone()
+	if value.Hour() == 0 && value.Minute() == 0 && value.Second() == 0 && value.Nanosecond() == 0 && offset == 0 {
+		return text
+	}
+	text += fmt.Sprintf(" %02d:%02d:%02d", value.Hour(), value.Minute(), value.Second())
+	if ms := value.Nanosecond() / int(time.Millisecond); ms != 0 {
+		text += fmt.Sprintf(".%03d", ms)
+	}
+	if offset != 0 || (value.Location() != time.UTC && name != "UTC") {
+		sign := '+'
+		if offset < 0 {
+			sign, offset = '-', -offset
+		}
+		text += fmt.Sprintf("-GMT%c%02d:%02d", sign, offset/3600, offset/60%60)
+	}
+	return text
+}
+
+func formatTimeSpan(value time.Duration) string {
+	text := ""
+	if value < 0 {
+		text = "-"
+		value = -value
+	}
+
+	days := value / (24 * time.Hour)
+	value -= days * 24 * time.Hour
+	hours := value / time.Hour
+	value -= hours * time.Hour
+	minutes := value / time.Minute
+	value -= minutes * time.Minute
+	seconds := value / time.Second
+	value -= seconds * time.Second
+	millis := value / time.Millisecond
+
+	if days > 0 {
+		text += fmt.Sprintf("%dd:", days)
+	}
+	text += fmt.Sprintf("%02d:%02d:%02d", hours, minutes, seconds)
+	if millis > 0 {
+		text += fmt.Sprintf(".%03d", millis)
+	}
+	return text
+}
@@ -0,0 +1,82 @@
+package sdlang
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffNoChanges(t *testing.T) {
+	a := parseForTest(t, "a.sdl", "server \"a\" port=80 {\n\tlisten 1\n}")
+	b := parseForTest(t, "b.sdl", "\n\nserver \"a\" port=80 {\n listen 1\n}")
+	assert.Empty(t, Diff(&a, &b))
+}
+
+func TestDiffValuesAndAttributes(t *testing.T) {
+	a := parseForTest(t, "a.sdl", "server \"a\" \"b\" port=80 host=\"x\"")
+	b := parseForTest(t, "b.sdl", "server \"a\" \"c\" 1 port=81 tls=true")
+
+	changes := Diff(&a, &b)
+	assert.Equal(t, 5, len(changes))
+
+	assert.Equal(t, ValueChanged, changes[0].Kind)
+	assert.Equal(t, "server", changes[0].Path)
+	assert.Equal(t, 1, changes[0].OldIndex)
+	assert.Equal(t, "a.sdl", changes[0].OldLocation.File)
+	assert.Equal(t, "b.sdl", changes[0].NewLocation.File)
+
+	assert.Equal(t, ValueAdded, changes[1].Kind)
+	assert.Equal(t, 2, changes[1].NewIndex)
+	assert.Equal(t, -1, changes[1].OldIndex)
+
+	assert.Equal(t, AttributeRemoved, changes[2].Kind)
+	assert.Equal(t, "host", changes[2].Name)
+	assert.Equal(t, AttributeChanged, changes[3].Kind)
+	assert.Equal(t, "port", changes[3].Name)
+	assert.Equal(t, AttributeAdded, changes[4].Kind)
+	assert.Equal(t, "tls", changes[4].Name)
+
+	assert.Equal(t, `server: changed value #1 from "b" to "c" (a.sdl @ 1:12 -> b.sdl @ 1:12)`, changes[0].String())
+}
+
+func TestDiffChildren(t *testing.T) {
+	a := parseForTest(t, "a.sdl", "a 1\nb 2\nc 3\nd 4\nb 5")
+	b := parseForTest(t, "b.sdl", "d 4\na 1\nb 6\nc 3\ne 7")
+
+	changes := Diff(&a, &b)
+	kinds := map[ChangeKind]int{}
+	for _, change := range changes {
+		kinds[change.Kind]++
+	}
+	assert.Equal(t, 1, kinds[ChildRemoved])
+	assert.Equal(t, 1, kinds[ChildAdded])
+	assert.Equal(t, 1, kinds[ChildMoved])
+	assert.Equal(t, 1, kinds[ValueChanged])
+
+	for _, change := range changes {
+		switch change.Kind {
+		case ChildMoved:
+			assert.Equal(t, "d", change.Name)
+			assert.Equal(t, 3, change.OldIndex)
+			assert.Equal(t, 0, change.NewIndex)
+		case ChildAdded:
+			assert.Equal(t, "e", change.Name)
+			assert.Equal(t, "e", change.NewTag.Name)
+		case ChildRemoved:
+			assert.Equal(t, "b", change.Name)
+		case ValueChanged:
+			assert.Equal(t, "b", change.Path)
+		}
+	}
+}
+
+func TestDiffNested(t *testing.T) {
+	a := parseForTest(t, "a.sdl", "servers {\n\tserver {\n\t\tport 1\n\t}\n\tserver {\n\t\tport 2\n\t}\n}")
+	b := parseForTest(t, "b.sdl", "servers {\n\tserver {\n\t\tport 1\n\t}\n\tserver {\n\t\tport 3\n\t}\n}")
+
+	changes := Diff(&a, &b)
+	assert.Equal(t, 1, len(changes))
+	assert.Equal(t, "servers/server[1]/port", changes[0].Path)
+	assert.Equal(t, 6, changes[0].OldLocation.LineNumber)
+	assert.Equal(t, "servers/server[1]/port: changed value #0 from 2 to 3 (a.sdl @ 6:8 -> b.sdl @ 6:8)\n", FormatChanges(changes))
+}
@@ -0,0 +1,186 @@
+package sdlang
+
+import "fmt"
+
+// CBOR and MessagePack both represent a tag as a map with the following keys, where keys with an empty value are left out:
+//
+//	"namespace":  the tag's namespace
+//	"name":       the tag's name, which is always present
+//	"values":     an array of the tag's values
+//	"attributes": a map from each attribute's qualified name to its value
+//	"children":   an array of the tag's children, each represented in the same way
+//
+// Null, strings, integers, floats, bools, and binary values use the format's own types.
+// Datetimes and timespans use the tags or extension types described by each format's encoder.
+const (
+	treeKeyNamespace  = "namespace"
+	treeKeyName       = "name"
+	treeKeyValues     = "values"
+	treeKeyAttributes = "attributes"
+	treeKeyChildren   = "children"
+)
+
+// treeEncoder writes the parts of a tag tree in a particular format. See encodeTree.
+type treeEncoder interface {
+	writeMapHeader(n int)
+	writeArrayHeader(n int)
+	writeString(s string)
+	writeValue(v SdlValue)
+}
+
+func encodeTree(e treeEncoder, tag *SdlTag) {
+	fields := 1
+	for _, nonEmpty := range []bool{tag.Namespace != "", len(tag.Values) > 0, len(tag.Attributes) > 0, len(tag.Children) > 0} {
+		if nonEmpty {
+			fields++
+		}
+	}
+	e.writeMapHeader(fields)
+
+	if tag.Namespace != "" {
+		e.writeString(treeKeyNamespace)
+		e.writeString(tag.Namespace)
+	}
+	e.writeString(treeKeyName)
+	e.writeString(tag.Name)
+
+	if len(tag.Values) > 0 {
+		e.writeString(treeKeyValues)
+		e.writeArrayHeader(len(tag.Values))
+		for _, value := range tag.Values {
+			e.writeValue(value)
+		}
+	}
+	if len(tag.Attributes) > 0 {
+		e.writeString(treeKeyAttributes)
+		e.writeMapHeader(len(tag.Attributes))
+		for _, key := range tag.sortedAttributeKeys() {
+			e.writeString(key)
+			e.writeValue(tag.Attributes[key].Value)
+		}
+	}
+	if len(tag.Children) > 0 {
+		e.writeString(treeKeyChildren)
+		e.writeArrayHeader(len(tag.Children))
+		for i := range tag.Children {
+			encodeTree(e, &tag.Children[i])
+		}
+	}
+}
+
+// treeDecoder reads the parts of a tag tree in a particular format. See decodeTree.
+// The first problem is kept by the decoder, after which every read returns a zero value.
+type treeDecoder interface {
+	readMapHeader() int
+	readArrayHeader() int
+	readString() string
+	readValue() SdlValue
+	fail(msg string)
+	failed() bool
+}
+
+// treeDecoding tracks the limits that apply to a whole tree.
+type treeDecoding struct {
+	d      treeDecoder
+	limits ParseOptions
+	tags   int
+}
+
+// treeFrame is a tag that's partway through being decoded.
+type treeFrame struct {
+	tag      SdlTag
+	seen     map[string]bool
+	hasName  bool
+	fields   int // How many of the tag's fields are left to read.
+	children int // How many children are left to read, while reading the "children" field.
+}
+
+func (t *treeDecoding) startTag() treeFrame {
+	return treeFrame{seen: map[string]bool{}, fields: t.d.readMapHeader()}
+}
+
+// decodeTree reads the root tag and all of its descendants. It doesn't recurse, so arbitrarily deep trees can be decoded.
+func (t *treeDecoding) decodeTree() SdlTag {
+	d := t.d
+	stack := []treeFrame{t.startTag()}
+	for !d.failed() {
+		top := &stack[len(stack)-1]
+		if top.children > 0 {
+			top.children--
+			t.tags++
+			if t.limits.MaxTags > 0 && t.tags > t.limits.MaxTags {
+				d.fail(fmt.Sprintf("there are more than the maximum allowed %d tags", t.limits.MaxTags))
+			} else if t.limits.MaxDepth > 0 && len(stack) > t.limits.MaxDepth {
+				d.fail(fmt.Sprintf("tags are nested more deeply than the maximum allowed %d", t.limits.MaxDepth))
+			} else {
+				stack = append(stack, t.startTag())
+			}
+			continue
+		} else if top.fields > 0 {
+			top.fields--
+			t.decodeField(top)
+			continue
+		}
+
+		if !top.hasName {
+			d.fail("tag has no name")
+			break
+		}
+		tag := top.tag
+		tag.QualifiedName = qualifiedName(tag.Namespace, tag.Name)
+		stack = stack[:len(stack)-1]
+		if len(stack) == 0 {
+			return tag
+		}
+		parent := &stack[len(stack)-1].tag
+		parent.Children = append(parent.Children, tag)
+	}
+	return SdlTag{}
+}
+
+// decodeField reads a single field of the tag in `f`. Children are only counted, so that decodeTree can read them.
+func (t *treeDecoding) decodeField(f *treeFrame) {
+	d := t.d
+	tag := &f.tag
+	key := d.readString()
+	if f.seen[key] {
+		d.fail(fmt.Sprintf("duplicate key %q in tag", key))
+	}
+	f.seen[key] = true
+
+	switch key {
+	case treeKeyNamespace:
+		tag.Namespace = d.readString()
+	case treeKeyName:
+		tag.Name = d.readString()
+		f.hasName = true
+	case treeKeyValues:
+		values := d.readArrayHeader()
+		if t.limits.MaxValues > 0 && values > t.limits.MaxValues {
+			d.fail(fmt.Sprintf("a tag has more than the maximum allowed %d values", t.limits.MaxValues))
+		}
+		for j := 0; j < values && !d.failed(); j++ {
+			tag.Values = append(tag.Values, d.readValue())
+		}
+	case treeKeyAttributes:
+		attributes := d.readMapHeader()
+		if t.limits.MaxAttributes > 0 && attributes > t.limits.MaxAttributes {
+			d.fail(fmt.Sprintf("a tag has more than the maximum allowed %d attributes", t.limits.MaxAttributes))
+		}
+		for j := 0; j < attributes && !d.failed(); j++ {
+			key := d.readString()
+			namespace, name := splitQualifiedName(key)
+			if tag.Attributes == nil {
+				tag.Attributes = map[string]SdlAttribute{}
+			}
+			if _, exists := tag.Attributes[key]; exists {
+				d.fail("duplicate attribute " + key)
+			}
+			tag.Attributes[key] = NewAttribute(namespace, name, d.readValue())
+		}
+	case treeKeyChildren:
+		f.children = d.readArrayHeader()
+	default:
+		d.fail(fmt.Sprintf("unknown key %q in tag", key))
+	}
+}
@@ -0,0 +1,145 @@
+package sdlang
+
+import (
+	"math"
+	"runtime/debug"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var treeFormats = []struct {
+	name   string
+	encode func(tag *SdlTag) []byte
+	decode func(data []byte, limits ParseOptions) (SdlTag, error)
+}{
+	{"CBOR", EncodeCBOR, DecodeCBOR},
+	{"MessagePack", EncodeMessagePack, DecodeMessagePack},
+}
+
+func TestTreeRoundTrip(t *testing.T) {
+	expected := parseForTest(t, "", binaryTestInput)
+	exact := NewTag("", "exact")
+	exact.AddValue(
+		DateTime(time.Date(2021, 3, 4, 5, 6, 7, 123456789, time.FixedZone("", -(3*60*60+15*60)))),
+		DateTime(time.Date(1900, 1, 1, 0, 0, 0, 1, time.UTC)),
+		DateTime(time.Date(2500, 1, 1, 0, 0, 0, 0, time.UTC)),
+		TimeSpan(-time.Nanosecond),
+		TimeSpan(math.MaxInt64),
+		Int(math.MinInt64),
+		Float(math.Inf(1)),
+		Binary([]byte{}),
+		String(""),
+	)
+	expected.AddChild(exact)
+
+	for _, format := range treeFormats {
+		data := format.encode(&expected)
+		ast, err := format.decode(data, DefaultParseOptions())
+		assert.NoError(t, err, format.name)
+		assert.True(t, expected.Equal(ast), format.name+"\n"+FormatChanges(Diff(&expected, &ast)))
+		assert.Equal(t, data, format.encode(&ast), "%s encoding must be deterministic", format.name)
+
+		tag := ast.Children[1]
+		assert.Equal(t, "ns", tag.Namespace)
+		assert.Equal(t, "ns:tag", tag.QualifiedName)
+		assert.Equal(t, "ns", tag.Attributes["ns:attr"].Namespace)
+		assert.Equal(t, "attr", tag.Attributes["ns:attr"].Name)
+
+		span, _ := ast.Children[0].Values[8].TimeSpan()
+		assert.Equal(t, -(24*time.Hour + 2*time.Hour + 3*time.Minute + 4*time.Second + 500*time.Millisecond), span, format.name)
+		blob, _ := ast.Children[0].Values[11].Binary()
+		assert.Equal(t, []byte{1, 2, 3}, blob, format.name)
+	}
+}
+
+func TestTreeDecodingErrors(t *testing.T) {
+	valid := parseForTest(t, "", binaryTestInput)
+	withoutName := NewTag("", "")
+	withoutName.AddValue(Int(1))
+
+	for _, format := range treeFormats {
+		data := format.encode(&valid)
+		for i := 0; i < len(data); i++ {
+			_, err := format.decode(data[:i], ParseOptions{})
+			assert.Error(t, err, "%s truncated to %d bytes", format.name, i)
+		}
+		_, err := format.decode(append(append([]byte{}, data...), data...), ParseOptions{})
+		assert.Contains(t, err.Error(), "unexpected data after the root tag", format.name)
+
+		limits := []struct {
+			opts    ParseOptions
+			message string
+		}{
+			{ParseOptions{MaxDepth: 2}, "tags are nested more deeply than the maximum allowed 2"},
+			{ParseOptions{MaxTags: 4}, "there are more than the maximum allowed 4 tags"},
+			{ParseOptions{MaxValues: 11}, "a tag has more than the maximum allowed 11 values"},
+			{ParseOptions{MaxAttributes: 1}, "a tag has more than the maximum allowed 1 attributes"},
+			{ParseOptions{MaxTokenLength: 9}, "length is more than the maximum allowed 9 bytes"},
+		}
+		for _, limit := range limits {
+			_, err = format.decode(data, limit.opts)
+			if assert.Error(t, err, "%s: %s", format.name, limit.message) {
+				assert.Contains(t, err.Error(), limit.message)
+			}
+		}
+		_, err = format.decode(data, ParseOptions{MaxDepth: 3, MaxTags: 5, MaxValues: 12, MaxAttributes: 2, MaxTokenLength: 29})
+		assert.NoError(t, err, format.name)
+	}
+
+	// Maps that aren't tags.
+	tests := []struct {
+		cbor, msgpack []byte
+		message       string
+	}{
+		{[]byte{0xa0}, []byte{0x80}, "tag has no name"},
+		{[]byte{0xa1, 0x61, 'x', 0x60}, []byte{0x81, 0xa1, 'x', 0xa0}, `unknown key "x" in tag`},
+		{[]byte{0xa2, 0x64, 'n', 'a', 'm', 'e', 0x60, 0x64, 'n', 'a', 'm', 'e', 0x60},
+			[]byte{0x82, 0xa4, 'n', 'a', 'm', 'e', 0xa0, 0xa4, 'n', 'a', 'm', 'e', 0xa0}, `duplicate key "name" in tag`},
+		{[]byte{0xa1, 0x64, 'n', 'a', 'm', 'e', 0x01}, []byte{0x81, 0xa4, 'n', 'a', 'm', 'e', 0x01}, "expected a"},
+		{[]byte{0x80}, []byte{0x90}, "expected a map"},
+	}
+	for _, test := range tests {
+		_, err := DecodeCBOR(test.cbor, ParseOptions{})
+		if assert.Error(t, err, test.message) {
+			assert.Contains(t, err.Error(), test.message)
+		}
+		_, err = DecodeMessagePack(test.msgpack, ParseOptions{})
+		if assert.Error(t, err, test.message) {
+			assert.Contains(t, err.Error(), test.message)
+		}
+	}
+}
+
+func TestTreeDecodingDoesNotRecurse(t *testing.T) {
+	// Each level is {"name": "a", "children": [...]}, written directly so that encoding doesn't recurse either.
+	deep := func(e treeEncoder, depth int) {
+		for i := 0; i < depth; i++ {
+			e.writeMapHeader(2)
+			e.writeString(treeKeyName)
+			e.writeString("a")
+			e.writeString(treeKeyChildren)
+			e.writeArrayHeader(1)
+		}
+		e.writeMapHeader(1)
+		e.writeString(treeKeyName)
+		e.writeString("a")
+	}
+	cbor, msgpack := cborEncoder{}, msgpackEncoder{}
+	deep(&cbor, 100000)
+	deep(&msgpack, 100000)
+	data := map[string][]byte{"CBOR": cbor.out, "MessagePack": msgpack.out}
+
+	// Decoding must work without limits, even with a stack far too small to recurse this deeply.
+	defer debug.SetMaxStack(debug.SetMaxStack(1 << 20))
+	for _, format := range treeFormats {
+		root, err := format.decode(data[format.name], ParseOptions{})
+		assert.NoError(t, err, format.name)
+		depth := 0
+		for tag := &root; len(tag.Children) > 0; tag = &tag.Children[0] {
+			depth++
+		}
+		assert.Equal(t, 100000, depth, format.name)
+	}
+}
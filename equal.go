@@ -0,0 +1,259 @@
+package sdlang
+
+import (
+	"bytes"
+	"hash/fnv"
+	"io"
+	"math"
+	"sort"
+)
+
+// EqualOptions changes how tags and values are compared by the `EqualWithOptions` functions.
+// Debug locations are never compared, except to find the order of attributes for CompareAttributeOrder.
+type EqualOptions struct {
+	// IgnoreChildOrder makes children compare equal as long as both tags contain the same children in any order.
+	IgnoreChildOrder bool
+
+	// CompareAttributeOrder makes attributes only compare equal if they were also written in the same order.
+	// By default, attributes are matched by their qualified name regardless of order, as SdlTag keeps them in a map.
+	// The order is taken from each attribute's `DebugLocation.Offset`, so attributes that weren't parsed, which all
+	// have an offset of 0, are ordered by their qualified name.
+	CompareAttributeOrder bool
+
+	// FloatTolerance is the largest absolute difference allowed between two floats for them to compare equal.
+	FloatTolerance float64
+}
+
+// Equal determines whether this value has the same type and content as `other`.
+func (v SdlValue) Equal(other SdlValue) bool {
+	return v.EqualWithOptions(other, EqualOptions{})
+}
+
+// EqualWithOptions is the same as Equal, except the comparison can be configured with `opts`.
+func (v SdlValue) EqualWithOptions(other SdlValue, opts EqualOptions) bool {
+	if v.tag != other.tag {
+		return false
+	}
+
+	switch v.tag {
+	case tNull:
+		return true
+	case tString:
+		return v.vString == other.vString
+	case tInt:
+		return v.vInt == other.vInt
+	case tFloat:
+		if math.IsNaN(v.vFloat) || math.IsNaN(other.vFloat) {
+			return math.IsNaN(v.vFloat) && math.IsNaN(other.vFloat)
+		}
+		return v.vFloat == other.vFloat || math.Abs(v.vFloat-other.vFloat) <= opts.FloatTolerance
+	case tDateTime:
+		return v.vDateTime.Equal(other.vDateTime)
+	case tTimeSpan:
+		return v.vTimeSpan == other.vTimeSpan
+	case tBool:
+		return v.vBool == other.vBool
+	case tBinary:
+		return bytes.Equal(v.vBinary, other.vBinary)
+	}
+	return false
+}
+
+// Equal determines whether this attribute has the same qualified name and value as `other`.
+func (a SdlAttribute) Equal(other SdlAttribute) bool {
+	return a.EqualWithOptions(other, EqualOptions{})
+}
+
+// EqualWithOptions is the same as Equal, except the comparison can be configured with `opts`.
+func (a SdlAttribute) EqualWithOptions(other SdlAttribute, opts EqualOptions) bool {
+	return a.QualifiedName == other.QualifiedName && a.Value.EqualWithOptions(other.Value, opts)
+}
+
+// Equal determines whether this tag has the same name, values, attributes, and children as `other`.
+func (t SdlTag) Equal(other SdlTag) bool {
+	return t.EqualWithOptions(other, EqualOptions{})
+}
+
+// EqualWithOptions is the same as Equal, except the comparison can be configured with `opts`.
+func (t SdlTag) EqualWithOptions(other SdlTag, opts EqualOptions) bool {
+	if t.QualifiedName != other.QualifiedName ||
+		len(t.Values) != len(other.Values) ||
+		len(t.Attributes) != len(other.Attributes) ||
+		len(t.Children) != len(other.Children) {
+		return false
+	}
+
+	for i := range t.Values {
+		if !t.Values[i].EqualWithOptions(other.Values[i], opts) {
+			return false
+		}
+	}
+
+	for key, attr := range t.Attributes {
+		otherAttr, exists := other.Attributes[key]
+		if !exists || !attr.EqualWithOptions(otherAttr, opts) {
+			return false
+		}
+	}
+	if opts.CompareAttributeOrder {
+		keys, otherKeys := t.attributeKeysInSourceOrder(), other.attributeKeysInSourceOrder()
+		for i := range keys {
+			if keys[i] != otherKeys[i] {
+				return false
+			}
+		}
+	}
+
+	if !opts.IgnoreChildOrder {
+		for i := range t.Children {
+			if !t.Children[i].EqualWithOptions(other.Children[i], opts) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return matchChildren(t.Children, other.Children, opts)
+}
+
+// matchChildren determines whether every child in `a` can be paired with a different, equal child in `b`.
+// Pairing each child with the first equal one isn't enough, as FloatTolerance makes equality intransitive:
+// with a tolerance of 0.05, pairing 1.0 with 1.04 leaves 1.05 and 0.99, whereas 1.0 and 0.99 can pair as well
+// as 1.05 and 1.04. Instead, each child looks for a partner by moving earlier children onto other partners.
+func matchChildren(a, b []SdlTag, opts EqualOptions) bool {
+	equal := make([]int8, len(a)*len(b)) // 0 if not compared yet, 1 if equal, -1 if not.
+	isEqual := func(i, j int) bool {
+		if equal[i*len(b)+j] == 0 {
+			equal[i*len(b)+j] = -1
+			if a[i].EqualWithOptions(b[j], opts) {
+				equal[i*len(b)+j] = 1
+			}
+		}
+		return equal[i*len(b)+j] > 0
+	}
+
+	partner := make([]int, len(b)) // The index in `a` paired with each child of `b`, or -1.
+	for j := range partner {
+		partner[j] = -1
+	}
+	var pair func(i int, visited []bool) bool
+	pair = func(i int, visited []bool) bool {
+		for j := range b {
+			if visited[j] || !isEqual(i, j) {
+				continue
+			}
+			visited[j] = true
+			if partner[j] < 0 || pair(partner[j], visited) {
+				partner[j] = i
+				return true
+			}
+		}
+		return false
+	}
+	for i := range a {
+		if !pair(i, make([]bool, len(b))) {
+			return false
+		}
+	}
+	return true
+}
+
+// Hash produces a stable hash of this value's type and content, suitable for use as a cache key.
+// Values that are Equal produce the same hash.
+func (v SdlValue) Hash() uint64 {
+	h := fnv.New64a()
+	v.writeHash(h)
+	return h.Sum64()
+}
+
+// Hash produces a stable hash of this tag's name, values, attributes, and children, suitable for use as a cache key.
+// Tags that are Equal produce the same hash. Debug locations do not affect the hash.
+func (t SdlTag) Hash() uint64 {
+	h := fnv.New64a()
+	t.writeHash(h)
+	return h.Sum64()
+}
+
+func writeHashUint(h io.Writer, value uint64) {
+	var buf [8]byte
+	for i := range buf {
+		buf[i] = byte(value >> (8 * i))
+	}
+	h.Write(buf[:])
+}
+
+func writeHashString(h io.Writer, value string) {
+	writeHashUint(h, uint64(len(value)))
+	h.Write([]byte(value))
+}
+
+func (v SdlValue) writeHash(h io.Writer) {
+	writeHashUint(h, uint64(v.tag))
+
+	switch v.tag {
+	case tString:
+		writeHashString(h, v.vString)
+	case tInt:
+		writeHashUint(h, uint64(v.vInt))
+	case tFloat:
+		f := v.vFloat
+		if f == 0 {
+			f = 0 // Normalises -0, as it compares equal to 0.
+		} else if math.IsNaN(f) {
+			f = math.NaN()
+		}
+		writeHashUint(h, math.Float64bits(f))
+	case tDateTime:
+		writeHashUint(h, uint64(v.vDateTime.Unix()))
+		writeHashUint(h, uint64(v.vDateTime.Nanosecond()))
+	case tTimeSpan:
+		writeHashUint(h, uint64(v.vTimeSpan))
+	case tBool:
+		if v.vBool {
+			writeHashUint(h, 1)
+		} else {
+			writeHashUint(h, 0)
+		}
+	case tBinary:
+		writeHashUint(h, uint64(len(v.vBinary)))
+		h.Write(v.vBinary)
+	}
+}
+
+func (t SdlTag) writeHash(h io.Writer) {
+	writeHashString(h, t.QualifiedName)
+
+	writeHashUint(h, uint64(len(t.Values)))
+	for _, value := range t.Values {
+		value.writeHash(h)
+	}
+
+	writeHashUint(h, uint64(len(t.Attributes)))
+	for _, key := range t.sortedAttributeKeys() {
+		writeHashString(h, key)
+		t.Attributes[key].Value.writeHash(h)
+	}
+
+	writeHashUint(h, uint64(len(t.Children)))
+	for _, child := range t.Children {
+		child.writeHash(h)
+	}
+}
+
+func (t SdlTag) sortedAttributeKeys() []string {
+	keys := make([]string, 0, len(t.Attributes))
+	for key := range t.Attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// attributeKeysInSourceOrder is the qualified name of each attribute in the order they were written.
+func (t SdlTag) attributeKeysInSourceOrder() []string {
+	keys := t.sortedAttributeKeys()
+	sort.SliceStable(keys, func(i, j int) bool {
+		return t.Attributes[keys[i]].DebugLocation.Offset < t.Attributes[keys[j]].DebugLocation.Offset
+	})
+	return keys
+}
@@ -0,0 +1,81 @@
+package sdlang
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValueEqual(t *testing.T) {
+	assert.True(t, Null().Equal(Null()))
+	assert.True(t, String("a").Equal(String("a")))
+	assert.False(t, String("a").Equal(String("b")))
+	assert.False(t, Int(1).Equal(Float(1)))
+	assert.True(t, Binary([]byte{1, 2}).Equal(Binary([]byte{1, 2})))
+	assert.False(t, Binary([]byte{1, 2}).Equal(Binary([]byte{1})))
+	assert.True(t, Float(math.NaN()).Equal(Float(math.NaN())))
+
+	utc := time.Date(2005, 12, 5, 14, 12, 23, 0, time.UTC)
+	assert.True(t, DateTime(utc).Equal(DateTime(utc.In(time.FixedZone("X", 3600)))))
+
+	assert.False(t, Float(1.0).Equal(Float(1.0001)))
+	assert.True(t, Float(1.0).EqualWithOptions(Float(1.0001), EqualOptions{FloatTolerance: 0.001}))
+}
+
+func TestTagEqual(t *testing.T) {
+	p := SaxParser{Input: "a 1 b=2 {\n\tc\n\td\n}"}
+	first, err := p.ParseIntoAst()
+	assert.NoError(t, err)
+
+	p = SaxParser{Input: "# Different debug locations\n\n  a 1 b=2 {\n c\n d\n }"}
+	second, err := p.ParseIntoAst()
+	assert.NoError(t, err)
+	assert.True(t, first.Equal(second))
+	assert.Equal(t, first.Hash(), second.Hash())
+
+	p = SaxParser{Input: "a 1 b=2 {\n\td\n\tc\n}"}
+	reordered, err := p.ParseIntoAst()
+	assert.NoError(t, err)
+	assert.False(t, first.Equal(reordered))
+	assert.NotEqual(t, first.Hash(), reordered.Hash())
+	assert.True(t, first.EqualWithOptions(reordered, EqualOptions{IgnoreChildOrder: true}))
+
+	p = SaxParser{Input: "a 1 b=3 {\n\tc\n\td\n}"}
+	changed, err := p.ParseIntoAst()
+	assert.NoError(t, err)
+	assert.False(t, first.Equal(changed))
+	assert.NotEqual(t, first.Hash(), changed.Hash())
+}
+
+func TestTagEqualAttributeOrder(t *testing.T) {
+	first := parseForTest(t, "", "a x=1 y=2\n")
+	second := parseForTest(t, "", "a y=2 x=1\n")
+	assert.True(t, first.Equal(second))
+	assert.False(t, first.EqualWithOptions(second, EqualOptions{CompareAttributeOrder: true}))
+	assert.True(t, first.EqualWithOptions(parseForTest(t, "", "  a x=1 y=2\n"), EqualOptions{CompareAttributeOrder: true}))
+
+	built, other := NewTag("", "a"), NewTag("", "a")
+	built.SetAttr("y", Int(2)).SetAttr("x", Int(1))
+	other.SetAttr("x", Int(1)).SetAttr("y", Int(2))
+	assert.True(t, built.EqualWithOptions(other, EqualOptions{CompareAttributeOrder: true}), "tags that weren't parsed order attributes by name")
+}
+
+func TestTagEqualChildOrderWithTolerance(t *testing.T) {
+	// Pairing the first child with the first child it's close enough to would leave 1.05 and 0.99 unpaired.
+	first := parseForTest(t, "", "a {\n\tb 1.0\n\tb 1.05\n}\n")
+	second := parseForTest(t, "", "a {\n\tb 1.04\n\tb 0.99\n}\n")
+	opts := EqualOptions{IgnoreChildOrder: true, FloatTolerance: 0.05}
+	assert.True(t, first.EqualWithOptions(second, opts))
+	assert.True(t, second.EqualWithOptions(first, opts))
+
+	third := parseForTest(t, "", "a {\n\tb 1.04\n\tb 1.2\n}\n")
+	assert.False(t, first.EqualWithOptions(third, opts))
+}
+
+func TestValueHash(t *testing.T) {
+	assert.Equal(t, Float(0).Hash(), Float(math.Copysign(0, -1)).Hash())
+	assert.NotEqual(t, Int(1).Hash(), Float(1).Hash())
+	assert.NotEqual(t, String("").Hash(), Null().Hash())
+}
@@ -0,0 +1,177 @@
+package sdlang
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/BradleyChatha/decorator"
+)
+
+// SdlError is an error that happened at a specific location within an SDLang document.
+// Every error produced while parsing is an *SdlError, and its Error function renders it as a user-friendly message.
+type SdlError struct {
+	// Location is where the error happened. Errors about a whole token, such as an invalid integer, are a span.
+	Location SdlDebugLocation
+
+	// Message describes the error.
+	Message string
+
+	// Rule identifies the kind of error, so tools can group or filter them. The parser uses "syntax", "limit" (see ParseOptions),
+	// and "encoding", includes use "include", interpolation uses "interpolation", and NamespaceRegistry uses "namespace".
+	// It's empty for errors made with NewError.
+	Rule string
+
+	// Related contains additional locations that help explain the error, such as where an unterminated string began.
+	Related []SdlError
+}
+
+func (e *SdlError) Error() string {
+	var d decorator.Decorator
+	decorate(&d, append([]SdlError{{Location: e.Location, Message: e.Message}}, e.Related...), RenderOptions{}, "")
+	return d.String()
+}
+
+const (
+	ruleSyntax        = "syntax"
+	ruleLimit         = "limit"
+	ruleEncoding      = "encoding"
+	ruleInclude       = "include"
+	ruleInterpolation = "interpolation"
+)
+
+func (l SdlDebugLocation) newRuleError(rule, msg string) error {
+	return &SdlError{Location: l, Message: msg, Rule: rule}
+}
+
+// decorate adds the line of each error to `d`, with the error's message pointing at its location.
+// If `colour` is set then the first error is highlighted with it, and the rest are treated as related information.
+func decorate(d *decorator.Decorator, errs []SdlError, opts RenderOptions, colour decorator.LineColourEnum) {
+	lines, comments := 0, 0
+	var prev SdlDebugLocation
+	var line string
+
+	// showContext adds the lines from `first` to `last` of `file`, apart from any that were just added.
+	var shownFile string
+	shownLine := 0
+	showContext := func(file string, first, last int) {
+		source, exists := opts.Sources[file]
+		if !exists {
+			return
+		}
+		sourceLines := strings.Split(source, "\n")
+		if file == shownFile && first <= shownLine {
+			first = shownLine + 1
+		}
+		if first < 1 {
+			first = 1
+		}
+		for n := first; n <= last && n <= len(sourceLines); n++ {
+			text := strings.ReplaceAll(strings.TrimSuffix(sourceLines[n-1], "\r"), "\t", " ")
+			d.AddLine(validLine(text), decorator.LineMetadata{FileName: file, LineNumber: n})
+			lines++
+			shownFile, shownLine = file, n
+		}
+	}
+
+	for i, err := range errs {
+		loc := err.Location
+		if i == 0 || loc.File != prev.File || loc.LineNumber != prev.LineNumber {
+			if i > 0 {
+				last := prev.LineNumber + opts.ContextLines
+				if loc.File == prev.File && loc.LineNumber > prev.LineNumber && last >= loc.LineNumber {
+					last = loc.LineNumber - 1
+				}
+				showContext(prev.File, prev.LineNumber+1, last)
+			}
+			showContext(loc.File, loc.LineNumber-opts.ContextLines, loc.LineNumber-1)
+
+			// The decorator refuses lines containing tabs, and can't point past the end of a line.
+			line = validLine(strings.ReplaceAll(loc.Line, "\t", " "))
+			if n := utf8.RuneCountInString(line); loc.Loc >= n {
+				line += strings.Repeat(" ", loc.Loc-n+1)
+			}
+			d.AddLine(line, decorator.LineMetadata{FileName: loc.File, LineNumber: loc.LineNumber})
+			lines, comments = lines+1, 0
+			shownFile, shownLine = loc.File, loc.LineNumber
+		}
+		d.AddBottomComment(lines-1, displayWidth(line, loc.Loc), err.Message)
+		comments++
+
+		if colour != "" {
+			if i > 0 {
+				colour = decorator.FgCyan
+			}
+			from, to := runeOffset(line, loc.Loc), len(line)
+			if loc.End.LineNumber == loc.LineNumber && loc.End.Loc > loc.Loc {
+				to = runeOffset(line, loc.End.Loc)
+			} else if loc.End.LineNumber <= loc.LineNumber && from < len(line) {
+				_, size := utf8.DecodeRuneInString(line[from:])
+				to = from + size
+			}
+			if from < to {
+				d.ColourLine(lines-1, decorator.LineColour{From: from, To: to, Colour: colour})
+			}
+			d.ColourBottomComment(lines-1, comments-1, decorator.LineColour{From: 0, To: len(err.Message), Colour: colour})
+		}
+		prev = loc
+	}
+	if len(errs) > 0 {
+		showContext(prev.File, prev.LineNumber+1, prev.LineNumber+opts.ContextLines)
+	}
+}
+
+// validLine replaces each invalid byte in `line` with U+FFFD. Unlike strings.ToValidUTF8, this keeps the number
+// of runes the same, so columns still point at the same characters.
+func validLine(line string) string {
+	if utf8.ValidString(line) {
+		return line
+	}
+	var b strings.Builder
+	for _, r := range line {
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// runeOffset is the byte offset of the rune at index `runes` within `line`.
+func runeOffset(line string, runes int) int {
+	for i := range line {
+		if runes == 0 {
+			return i
+		}
+		runes--
+	}
+	return len(line)
+}
+
+// wideRanges are the ranges of runes that take up two columns in a terminal, such as CJK ideographs and emoji.
+var wideRanges = [][2]rune{
+	{0x1100, 0x115F}, {0x2E80, 0x303E}, {0x3041, 0x33FF}, {0x3400, 0x4DBF}, {0x4E00, 0x9FFF},
+	{0xA000, 0xA4CF}, {0xAC00, 0xD7A3}, {0xF900, 0xFAFF}, {0xFE30, 0xFE4F}, {0xFF00, 0xFF60},
+	{0xFFE0, 0xFFE6}, {0x1F300, 0x1F64F}, {0x1F900, 0x1F9FF}, {0x20000, 0x3FFFD},
+}
+
+// displayWidth is how many terminal columns the first `runes` runes of `line` take up,
+// so that the decorator points at the right character even when wide or combining characters come before it.
+func displayWidth(line string, runes int) int {
+	width := 0
+	for _, r := range line {
+		if runes == 0 {
+			break
+		}
+		runes--
+
+		if unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf) {
+			continue
+		}
+		width++
+		for _, wide := range wideRanges {
+			if r >= wide[0] && r <= wide[1] {
+				width++
+				break
+			}
+		}
+	}
+	return width
+}
@@ -0,0 +1,46 @@
+package sdlang
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSdlError(t *testing.T) {
+	p := SaxParser{Input: "\ta \"unterminated\nb", FileName: "test.sdl"}
+	_, err := p.ParseIntoAst()
+
+	var sdlErr *SdlError
+	assert.True(t, errors.As(err, &sdlErr))
+	assert.Equal(t, "Unterminated string", sdlErr.Message)
+	assert.Equal(t, "test.sdl", sdlErr.Location.File)
+	assert.Equal(t, 1, sdlErr.Location.LineNumber)
+	assert.Equal(t, 3, sdlErr.Location.Loc)
+	assert.Equal(t, 1, len(sdlErr.Related))
+
+	// Tabs are replaced so the line can still be rendered.
+	assert.Contains(t, err.Error(), "test.sdl @ 1 |  a \"unterminated")
+	assert.Contains(t, err.Error(), "Unterminated string")
+	assert.Contains(t, err.Error(), "Expected a terminating")
+}
+
+func TestSdlErrorPastEndOfLine(t *testing.T) {
+	err := (&SdlError{Location: SdlDebugLocation{Line: "abc", Loc: 5, LineNumber: 1}, Message: "here"}).Error()
+	assert.Contains(t, err, "abc   \n")
+	assert.Contains(t, err, "here")
+}
+
+func TestSdlErrorWideCharacters(t *testing.T) {
+	p := SaxParser{Input: "名前 @", FileName: "test.sdl"}
+	p.Next()
+	err := p.Next()
+
+	var sdlErr *SdlError
+	assert.True(t, errors.As(err, &sdlErr))
+	assert.Equal(t, 3, sdlErr.Location.Loc)
+
+	// Each ideograph takes two columns, so the marker sits under the '@' in the fifth column.
+	assert.Contains(t, err.Error(), "test.sdl @ 1 | 名前 @\n")
+	assert.Contains(t, err.Error(), "             |      │\n")
+}
@@ -0,0 +1,122 @@
+package sdlang
+
+import "strings"
+
+// FormatOptions configures SaxParser.Format.
+type FormatOptions struct {
+	// Indent is written once per level of nesting. Defaults to a tab.
+	Indent string
+}
+
+// Format reformats the input so that each tag is on its own line, indented by how deeply it is nested,
+// with a single space between each token. Comments, line continuations, and the text of each literal are kept as-is,
+// and runs of blank lines are collapsed into one. Semicolons are replaced with new lines.
+// The input must parse without errors, otherwise the error is returned.
+func (p SaxParser) Format(opts FormatOptions) (string, error) {
+	if _, err := p.ParseIntoAst(); err != nil {
+		return "", err
+	}
+	if opts.Indent == "" {
+		opts.Indent = "\t"
+	}
+
+	f := formatter{opts: opts}
+	p.ReportComments = true
+	p.Options = ParseOptions{}
+	prevEnd := p.cursor
+	for {
+		err := p.Next()
+		if err != nil {
+			return "", err
+		}
+		raw := p.Input[p.tokenStart:p.cursor]
+		gap := p.Input[prevEnd:p.tokenStart]
+		prevEnd = p.cursor
+
+		// A continuation that's directly followed by the end of the line doesn't do anything, so it's dropped.
+		if len(f.pieces) > 0 && !p.IsNewLine() && !p.IsEof() && (strings.Contains(gap, "\\\n") || strings.Contains(gap, "\\\r\n")) {
+			f.writeLine(" \\")
+			f.continued = true
+		}
+
+		switch {
+		case p.IsEof():
+			f.writeLine("")
+			return f.b.String(), nil
+		case p.IsNewLine():
+			if len(f.pieces) > 0 {
+				f.writeLine("")
+			} else if raw != ";" && f.b.Len() > 0 {
+				f.pendingBlank = true
+			}
+		case p.IsOpenTag():
+			f.add(raw)
+			f.depth++
+			f.lineOpens = true
+		case p.IsCloseTag():
+			f.depth--
+			f.pendingBlank = false
+			f.add(raw)
+		case p.IsTagName() && raw == "":
+			// Anonymous tags don't have any text of their own.
+		case p.IsComment():
+			f.add(strings.TrimRight(raw, " \t\r"))
+		case p.IsAttributeName():
+			f.add(raw)
+			f.glue = true
+		default:
+			f.add(raw)
+		}
+	}
+}
+
+type formatter struct {
+	opts         FormatOptions
+	b            strings.Builder
+	pieces       []string
+	depth        int
+	lineDepth    int
+	glue         bool // Whether the next piece is joined onto the previous one, such as an attribute's value.
+	continued    bool // Whether the current line continues the previous one.
+	pendingBlank bool
+	lineOpens    bool // Whether the current line has an opening brace.
+	afterOpen    bool // Whether the last line written had an opening brace.
+}
+
+func (f *formatter) add(piece string) {
+	if len(f.pieces) == 0 {
+		if !f.continued {
+			f.lineDepth = f.depth
+		}
+		f.pieces = append(f.pieces, piece)
+	} else if f.glue {
+		f.pieces[len(f.pieces)-1] += piece
+	} else {
+		f.pieces = append(f.pieces, piece)
+	}
+	f.glue = false
+}
+
+func (f *formatter) writeLine(suffix string) {
+	if len(f.pieces) == 0 {
+		return
+	}
+	if f.pendingBlank && !f.afterOpen && !f.continued {
+		f.b.WriteByte('\n')
+	}
+
+	depth := f.lineDepth
+	if f.continued {
+		depth++
+	}
+	f.b.WriteString(strings.Repeat(f.opts.Indent, depth))
+	f.b.WriteString(strings.Join(f.pieces, " "))
+	f.b.WriteString(suffix)
+	f.b.WriteByte('\n')
+
+	f.afterOpen = f.lineOpens
+	f.lineOpens = false
+	f.pieces = f.pieces[:0]
+	f.pendingBlank = false
+	f.continued = false
+}
@@ -0,0 +1,48 @@
+package sdlang
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormat(t *testing.T) {
+	p := SaxParser{Input: "# header\r\n\n\n   a 1   2 key=\"v\"  {   // open\n\n\"anon\" ; b   true\n\n\n  c 3 \\\n   4\n\n       }\n\n\nd 2005/12/05 14:12 /* x */"}
+	formatted, err := p.Format(FormatOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, `# header
+
+a 1 2 key="v" { // open
+	"anon"
+	b true
+
+	c 3 \
+		4
+}
+
+d 2005/12/05 14:12 /* x */
+`, formatted)
+
+	p = SaxParser{Input: formatted}
+	again, err := p.Format(FormatOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, formatted, again)
+}
+
+func TestFormatIndent(t *testing.T) {
+	p := SaxParser{Input: "a {\nb {\nc `raw\n  text`\n}\n}\n"}
+	formatted, err := p.Format(FormatOptions{Indent: "  "})
+	assert.NoError(t, err)
+	assert.Equal(t, "a {\n  b {\n    c `raw\n  text`\n  }\n}\n", formatted)
+}
+
+func TestFormatErrors(t *testing.T) {
+	p := SaxParser{Input: "a {\n"}
+	_, err := p.Format(FormatOptions{})
+	assert.Error(t, err)
+
+	p = SaxParser{Input: ""}
+	formatted, err := p.Format(FormatOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "", formatted)
+}
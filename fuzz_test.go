@@ -0,0 +1,280 @@
+package sdlang
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+var fuzzSeeds = []string{
+	"",
+	"\n",
+	"a",
+	"}",
+	"a {\n}\n}",
+	"true\n",
+	"a 1 2L 3.5 4.5F -6 \"str\" `raw` [AQID] true off null\n",
+	"ns:a ns:b=1 c=\"x\" {\n\t\"anon\"\n\td 2005/12/05 14:12:23.345\n}\n",
+	"t 12:34:56 -1d:02:03:04.500 00:00:0",
+	"t 1111/11/11 11:22:3",
+	"a \"esc \\n\\t\\\"\\\\ \\\n continued\"\n",
+	"# comment\n// comment\r\n-- comment\na \\\n 1\n",
+	"a \r",
+	"a \"\\u00e9 \\uD83D\\uDE00 \\0\\f\\b\\'\" '\\u0041'\n",
+}
+
+func checkFuzzError(t *testing.T, input string, err error) {
+	if err == nil {
+		return
+	}
+	var sdlErr *SdlError
+	if !errors.As(err, &sdlErr) {
+		t.Fatalf("error for %q is not an *SdlError: %v", input, err)
+	}
+	if sdlErr.Location.LineNumber < 1 {
+		t.Fatalf("error for %q has no line number: %v", input, err)
+	}
+	if sdlErr.Rule == "" {
+		t.Fatalf("error for %q has no rule: %v", input, err)
+	}
+	_ = err.Error()
+	_ = RenderDiagnostics(DiagnosticsFromErrors(err), RenderOptions{Color: true, ContextLines: 2, Sources: map[string]string{"": input}})
+}
+
+func FuzzSaxParser(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed, false)
+		f.Add(seed, true)
+	}
+	f.Fuzz(func(t *testing.T, input string, reportComments bool) {
+		p := SaxParser{Input: input, ReportComments: reportComments}
+		// Anonymous tags don't consume any input, so there can be up to two tokens per byte.
+		for i := 0; i <= 2*len(input)+1; i++ {
+			err := p.Next()
+			if err != nil {
+				checkFuzzError(t, input, err)
+				return
+			}
+			if p.IsEof() {
+				return
+			}
+			if p.IsBinary() || p.IsBool() || p.IsChar() || p.IsDate() || p.IsDateTime() || p.IsDouble() || p.IsFloat() ||
+				p.IsInteger() || p.IsLong() || p.IsNull() || p.IsString() || p.IsTimeSpan() {
+				_, err = p.Value()
+				checkFuzzError(t, input, err)
+			}
+		}
+		t.Fatalf("parser did not reach the end of %q", input)
+	})
+}
+
+func FuzzLexer(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, input string) {
+		l := Lexer{Input: input}
+		tokens, errs := l.Tokens()
+		end := 0
+		for _, token := range tokens {
+			if token.Start != end || token.End <= token.Start || token.Raw != input[token.Start:token.End] {
+				t.Fatalf("token %+v of %q doesn't follow on from the previous token", token, input)
+			}
+			end = token.End
+		}
+		if end != len(input) {
+			t.Fatalf("tokens of %q don't cover the entire input", input)
+		}
+		for _, err := range errs {
+			checkFuzzError(t, input, err)
+		}
+
+		p := SaxParser{Input: input}
+		if _, err := p.ParseIntoAst(); err == nil && len(errs) > 0 {
+			t.Fatalf("lexing %q failed, but it parses: %v", input, errs[0])
+		}
+	})
+}
+
+func FuzzLint(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed)
+	}
+	l, err := NewLinter(LintConfig{Namespaces: []string{"a"}, Rules: map[string]LintRuleConfig{"naming-convention": {}, "unused-namespace": {}}})
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Fuzz(func(t *testing.T, input string) {
+		p := SaxParser{Input: input}
+		if _, err := p.ParseIntoAst(); err != nil {
+			return
+		}
+		fixed, _ := l.Fix("", input)
+		p = SaxParser{Input: fixed}
+		if _, err := p.ParseIntoAst(); err != nil {
+			t.Fatalf("fixing %q produced %q, which doesn't parse: %v", input, fixed, err)
+		}
+	})
+}
+
+func FuzzParseIntoAst(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, input string) {
+		p := SaxParser{Input: input, Options: DefaultParseOptions()}
+		ast, err := p.ParseIntoAst()
+		tolerantAst, errs := p.ParseIntoAstTolerant()
+		for _, tolerantErr := range errs {
+			checkFuzzError(t, input, tolerantErr)
+		}
+
+		if err != nil {
+			checkFuzzError(t, input, err)
+			if len(errs) == 0 || errs[0].Error() != err.Error() {
+				t.Fatalf("tolerant parsing of %q did not find the same first error", input)
+			}
+			return
+		}
+		if len(errs) > 0 || !tolerantAst.Equal(ast) {
+			t.Fatalf("tolerant parsing of %q does not match normal parsing", input)
+		}
+		if !ast.Equal(ast.Clone()) {
+			t.Fatalf("clone of %q is not equal to the original", input)
+		}
+		if doc, err := p.ParseIntoCompact(); err != nil || !reflect.DeepEqual(ast, doc.Tag()) {
+			t.Fatalf("compact document of %q does not match normal parsing: %v", input, err)
+		}
+
+		data := EncodeBinary(&ast, BinaryOptions{DebugLocations: true})
+		decoded, err := DecodeBinary(data, DefaultParseOptions())
+		if err != nil || !ast.Equal(decoded) {
+			t.Fatalf("binary encoding of %q did not round trip: %v", input, err)
+		}
+		text, err := BinaryToText(data, DefaultParseOptions())
+		if err != nil {
+			t.Fatalf("binary encoding of %q could not be written as text: %v", input, err)
+		}
+		p = SaxParser{Input: text}
+		textAst, err := p.ParseIntoAst()
+		if err != nil || !ast.Equal(textAst) {
+			t.Fatalf("text %q converted from %q does not parse the same: %v", text, input, err)
+		}
+
+		for _, format := range treeFormats {
+			decoded, err := format.decode(format.encode(&ast), DefaultParseOptions())
+			if err != nil || !ast.Equal(decoded) {
+				t.Fatalf("%s encoding of %q did not round trip: %v", format.name, input, err)
+			}
+		}
+	})
+}
+
+func FuzzDecodeBinary(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		p := SaxParser{Input: seed}
+		for _, opts := range []BinaryOptions{{}, {DebugLocations: true}} {
+			if data, err := p.ParseIntoBinary(opts); err == nil {
+				f.Add(data)
+			}
+		}
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		ast, err := DecodeBinary(data, DefaultParseOptions())
+		if err != nil {
+			return
+		}
+		_, _ = BinaryToText(data, DefaultParseOptions())
+
+		// Whatever was decoded must encode into something that decodes the same, and encoding must then be stable.
+		opts := BinaryOptions{DebugLocations: data[len(binaryMagic)+1]&binaryFlagLocations != 0}
+		encoded := EncodeBinary(&ast, opts)
+		again, err := DecodeBinary(encoded, DefaultParseOptions())
+		if err != nil || !ast.Equal(again) {
+			t.Fatalf("re-encoding %q did not round trip: %v", data, err)
+		}
+		if !bytes.Equal(encoded, EncodeBinary(&again, opts)) {
+			t.Fatalf("re-encoding %q is not stable", data)
+		}
+	})
+}
+
+func fuzzTreeFormat(f *testing.F, name string) {
+	for _, format := range treeFormats {
+		if format.name != name {
+			continue
+		}
+		for _, seed := range fuzzSeeds {
+			p := SaxParser{Input: seed}
+			if ast, err := p.ParseIntoAst(); err == nil {
+				f.Add(format.encode(&ast))
+			}
+		}
+		f.Fuzz(func(t *testing.T, data []byte) {
+			ast, err := format.decode(data, DefaultParseOptions())
+			if err != nil {
+				return
+			}
+			// Whatever was decoded must encode into something that decodes the same, and encoding must then be stable.
+			encoded := format.encode(&ast)
+			again, err := format.decode(encoded, DefaultParseOptions())
+			if err != nil || !ast.Equal(again) {
+				t.Fatalf("re-encoding %x did not round trip: %v", data, err)
+			}
+			if !bytes.Equal(encoded, format.encode(&again)) {
+				t.Fatalf("re-encoding %x is not stable", data)
+			}
+		})
+	}
+}
+
+func FuzzDecodeCBOR(f *testing.F) {
+	fuzzTreeFormat(f, "CBOR")
+}
+
+func FuzzDecodeMessagePack(f *testing.F) {
+	fuzzTreeFormat(f, "MessagePack")
+}
+
+func FuzzQuoteString(f *testing.F) {
+	for _, seed := range []string{"", "plain", "\x00\x01\x1f\x7f", "\b\f\n\r\t\"\\'", "é😀\u2028", "\xff"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, value string) {
+		quoted := quoteString(value)
+		p := SaxParser{Input: "t " + quoted}
+		p.Next()
+		if err := p.Next(); err != nil || !p.IsString() {
+			t.Fatalf("quoted form %s of %q did not parse: %v", quoted, value, err)
+		}
+		// Converting to runes replaces each invalid byte with U+FFFD, in the same way as quoteString.
+		if expected := string([]rune(value)); p.Text() != expected {
+			t.Fatalf("quoted form %s of %q parsed as %q", quoted, value, p.Text())
+		}
+	})
+}
+
+func FuzzFormat(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, input string) {
+		p := SaxParser{Input: input}
+		formatted, err := p.Format(FormatOptions{})
+		if err != nil {
+			return
+		}
+		ast, _ := p.ParseIntoAst()
+
+		p = SaxParser{Input: formatted}
+		formattedAst, err := p.ParseIntoAst()
+		if err != nil || !ast.Equal(formattedAst) {
+			t.Fatalf("formatting %q as %q changed its meaning: %v", input, formatted, err)
+		}
+		again, err := p.Format(FormatOptions{})
+		if err != nil || again != formatted {
+			t.Fatalf("formatting %q is not stable: %q became %q", input, formatted, again)
+		}
+	})
+}
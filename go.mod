@@ -1,12 +1,15 @@
 module github.com/SdlangInitiative/sdlanggo
 
-go 1.17
+go 1.18
+
+require (
+	github.com/BradleyChatha/decorator v0.1.1
+	github.com/stretchr/testify v1.7.0
+)
 
 require (
-	github.com/BradleyChatha/decorator v0.1.1 // indirect
 	github.com/davecgh/go-spew v1.1.0 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/stretchr/objx v0.1.0 // indirect
-	github.com/stretchr/testify v1.7.0 // indirect
 	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c // indirect
 )
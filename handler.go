@@ -0,0 +1,265 @@
+package sdlang
+
+import "strconv"
+
+// SaxHandler receives structural events from SaxParser.Parse.
+// Returning an error from any function stops parsing, and the error is returned from Parse.
+type SaxHandler interface {
+	// StartTag is called when a tag begins. `namespace` is empty if the tag doesn't have one.
+	// Anonymous tags are given the name "content". SaxParser.Location can be used to find where the tag is.
+	StartTag(namespace, name string) error
+
+	// Value is called for each value of the current tag.
+	Value(value SdlValue) error
+
+	// Attribute is called for each attribute of the current tag.
+	Attribute(attr SdlAttribute) error
+
+	// EndTag is called when the current tag ends, either at the end of its line or at its closing brace.
+	// Tags are always ended in the reverse order they were started in.
+	EndTag() error
+
+	// Comment is called for each comment, with the text following the comment marker.
+	Comment(text string) error
+}
+
+// Parse parses the entire input, calling `h` for each tag, value, attribute, and comment.
+// Tags are nested in the same way as ParseIntoAst, so each StartTag is paired with a later EndTag.
+func (s *SaxParser) Parse(h SaxHandler) error {
+	errs := s.parse(h, false)
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// ParseTolerant is the same as Parse, except that it recovers from syntax errors rather than stopping at the first one.
+// Parsing carries on from the next line or closing brace, so `h` sees every tag that could be parsed,
+// and each StartTag is still paired with an EndTag. Every error found is returned, in the order they were found.
+// Errors returned by `h`, and errors caused by Options, still stop parsing immediately and are the last error returned.
+func (s *SaxParser) ParseTolerant(h SaxHandler) []error {
+	return s.parse(h, true)
+}
+
+func (s *SaxParser) parse(h SaxHandler, tolerant bool) []error {
+	reportComments := s.ReportComments
+	s.ReportComments = true
+	defer func() { s.ReportComments = reportComments }()
+
+	var errs []error
+	// syntaxError records `err`, returning true if parsing should stop.
+	// Only errors from the parser itself can be recovered from, and not errors returned by `h`.
+	syntaxError := func(err error) bool {
+		errs = append(errs, err)
+		_, isSyntax := err.(*SdlError)
+		return !tolerant || !isSyntax
+	}
+
+	// Each open brace is true if it belongs to a tag, or false for a stray brace that was recovered from.
+	var blocks []bool
+	tags, attributes, values := 0, 0, 0
+	startTag := func(namespace, name string) error {
+		tags++
+		attributes, values = 0, 0
+		if s.Options.MaxTags > 0 && tags > s.Options.MaxTags {
+			return s.tokenError(ruleLimit, "This document has more than the maximum allowed "+strconv.Itoa(s.Options.MaxTags)+" tags.")
+		} else if s.Options.MaxDepth > 0 && len(blocks)+1 > s.Options.MaxDepth {
+			return s.tokenError(ruleLimit, "This tag is nested deeper than the maximum allowed depth of "+strconv.Itoa(s.Options.MaxDepth)+".")
+		}
+		s.tagEnd = s.nameEnd
+		return h.StartTag(namespace, name)
+	}
+
+	prevWasNewLine := true
+	// endLine ends the tag on the current line, if there is one, so that parsing can recover from the next line.
+	endLine := func() error {
+		if prevWasNewLine {
+			return nil
+		}
+		prevWasNewLine = true
+		return h.EndTag()
+	}
+
+	for {
+		err := s.Next()
+		if err != nil {
+			if syntaxError(err) {
+				return errs
+			} else if err = endLine(); err != nil {
+				return append(errs, err)
+			}
+			s.skipLine(s.cursor)
+			continue
+		}
+
+		if s.IsEof() {
+			err = endLine()
+			if err != nil {
+				return append(errs, err)
+			}
+			if len(blocks) > 0 {
+				if syntaxError(s.NewError(0, "Expected a '}' before the end of file.")) {
+					return errs
+				}
+				for i := len(blocks) - 1; i >= 0; i-- {
+					if !blocks[i] {
+						continue
+					} else if err = h.EndTag(); err != nil {
+						return append(errs, err)
+					}
+				}
+			}
+			return errs
+		} else if s.IsComment() {
+			err = h.Comment(s.Text())
+		} else if s.IsTagName() {
+			if !prevWasNewLine {
+				if syntaxError(s.NewError(0, "(probably a bug) Tag names can only appear at the start of new lines.")) {
+					return errs
+				}
+				err = endLine()
+				s.skipLine(s.cursor)
+			} else {
+				err = startTag(s.AdditionalText(), s.Text())
+				prevWasNewLine = false
+			}
+		} else if s.IsAttributeName() {
+			attributes++
+			if s.Options.MaxAttributes > 0 && attributes > s.Options.MaxAttributes {
+				return append(errs, s.tokenError(ruleLimit, "This tag has more than the maximum allowed "+strconv.Itoa(s.Options.MaxAttributes)+" attributes."))
+			}
+			attr := NewAttribute(s.AdditionalText(), s.Text(), SdlValue{})
+			attr.DebugLocation = s.TokenLocation()
+			err = s.Next()
+			if err == nil {
+				attr.Value, err = s.Value()
+				s.tagEnd = s.cursor
+			}
+			if err != nil {
+				if syntaxError(err) {
+					return errs
+				}
+				err = endLine()
+				s.skipLine(s.cursor)
+			} else {
+				err = h.Attribute(attr)
+				prevWasNewLine = false
+			}
+		} else if s.IsNewLine() {
+			err = endLine()
+		} else if s.IsOpenTag() {
+			if prevWasNewLine {
+				if syntaxError(s.NewError(0, "Opening braces have to be on the same line as a tag.")) {
+					return errs
+				}
+				// Treat the brace as if it doesn't exist, but keep track of it so that its closing brace is ignored too.
+				blocks = append(blocks, false)
+			} else {
+				blocks = append(blocks, true)
+			}
+
+			prevWasNewLine = true
+			err = s.nextAfterBrace(h)
+			from := s.cursor
+			if err == nil && !s.IsNewLine() {
+				err = s.NewError(0, "Expected a new line following opening brace.")
+				from = s.tokenStart
+			}
+			if err != nil {
+				if syntaxError(err) {
+					return errs
+				}
+				s.skipLine(from)
+				err = nil
+			}
+		} else if s.IsCloseTag() {
+			if !prevWasNewLine {
+				if syntaxError(s.NewError(0, "Closing braces must be on their own line.")) {
+					return errs
+				} else if err = endLine(); err != nil {
+					return append(errs, err)
+				}
+			}
+			if len(blocks) == 0 {
+				if syntaxError(s.NewError(0, "Found a closing brace without a matching opening brace.")) {
+					return errs
+				}
+				s.skipLine(s.cursor)
+				continue
+			}
+
+			isTag := blocks[len(blocks)-1]
+			blocks = blocks[:len(blocks)-1]
+			if isTag {
+				s.tagEnd = s.cursor
+			}
+			err = s.nextAfterBrace(h)
+			from := s.cursor
+			if err == nil && !s.IsNewLine() && !s.IsEof() {
+				err = s.NewError(0, "Expected a new line or end of file following closing brace.")
+				from = s.tokenStart
+			}
+			if err != nil {
+				if syntaxError(err) {
+					return errs
+				}
+				s.skipLine(from)
+			}
+			err = nil
+			if isTag {
+				err = h.EndTag()
+			}
+			prevWasNewLine = true
+		} else {
+			if prevWasNewLine {
+				// Keywords such as `true` at the start of a line are values of an anonymous tag, rather than tag names.
+				err = startTag("", "content")
+				if err != nil {
+					return append(errs, err)
+				}
+				prevWasNewLine = false
+			}
+			values++
+			if s.Options.MaxValues > 0 && values > s.Options.MaxValues {
+				return append(errs, s.tokenError(ruleLimit, "This tag has more than the maximum allowed "+strconv.Itoa(s.Options.MaxValues)+" values."))
+			}
+			var value SdlValue
+			value, err = s.Value()
+			s.tagEnd = s.cursor
+			if err != nil {
+				if syntaxError(err) {
+					return errs
+				}
+				err = endLine()
+				s.skipLine(s.cursor)
+			} else {
+				err = h.Value(value)
+			}
+		}
+
+		if err != nil {
+			return append(errs, err)
+		}
+	}
+}
+
+// nextAfterBrace parses the token following a brace, passing over a comment if there is one.
+func (s *SaxParser) nextAfterBrace(h SaxHandler) error {
+	err := s.Next()
+	if err != nil || !s.IsComment() {
+		return err
+	}
+	err = h.Comment(s.Text())
+	if err != nil {
+		return err
+	}
+	return s.Next()
+}
+
+// skipLine moves the cursor from `from` to the next new line or closing brace, which is where ParseTolerant recovers from.
+func (s *SaxParser) skipLine(from int) {
+	s.cursor = from
+	for !s.eof() && s.peek(0) != '\n' && s.peek(0) != '}' {
+		s.advance(1)
+	}
+}
@@ -0,0 +1,154 @@
+package sdlang
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingHandler struct {
+	events []string
+}
+
+func (r *recordingHandler) StartTag(namespace, name string) error {
+	r.events = append(r.events, "start "+qualifiedName(namespace, name))
+	return nil
+}
+func (r *recordingHandler) Value(value SdlValue) error {
+	r.events = append(r.events, "value "+value.literal())
+	return nil
+}
+func (r *recordingHandler) Attribute(attr SdlAttribute) error {
+	r.events = append(r.events, "attr "+attr.QualifiedName+"="+attr.Value.literal())
+	return nil
+}
+func (r *recordingHandler) EndTag() error {
+	r.events = append(r.events, "end")
+	return nil
+}
+func (r *recordingHandler) Comment(text string) error {
+	r.events = append(r.events, "comment"+text)
+	return nil
+}
+
+func TestParseHandler(t *testing.T) {
+	p := SaxParser{Input: `# header
+ns:a 1 "two" 3.5 ns:b=true c=[AQID] { // open
+	"anon"
+	d 2005/12/05 00:00:01 -- trailing
+} # close
+e null`}
+
+	var r recordingHandler
+	assert.NoError(t, p.Parse(&r))
+	assert.False(t, p.ReportComments)
+	assert.Equal(t, strings.Join([]string{
+		"comment header",
+		"start ns:a",
+		"value 1",
+		`value "two"`,
+		"value 3.5",
+		"attr ns:b=true",
+		"attr c=[AQID]",
+		"comment open",
+		"start content",
+		`value "anon"`,
+		"end",
+		"start d",
+		"value 2005/12/05 00:00:01",
+		"comment trailing",
+		"end",
+		"comment close",
+		"end",
+		"start e",
+		"value null",
+		"end",
+	}, "\n"), strings.Join(r.events, "\n"))
+}
+
+type failingHandler struct {
+	recordingHandler
+}
+
+func (f *failingHandler) Value(value SdlValue) error {
+	return errors.New("stop")
+}
+
+func TestParseHandlerErrors(t *testing.T) {
+	p := SaxParser{Input: "a 1\n"}
+	assert.EqualError(t, p.Parse(&failingHandler{}), "stop")
+
+	for _, code := range []string{
+		"}\n",
+		"a {\n",
+		"a {\n}\n}\n",
+		"a b=\n",
+		"a [!!!]\n",
+		"a 99999999999999999999\n",
+	} {
+		p = SaxParser{Input: code}
+		assert.Error(t, p.Parse(&recordingHandler{}), code)
+	}
+}
+
+func TestParseTolerant(t *testing.T) {
+	p := SaxParser{Input: `a 1 @ 2
+b "ok" {
+	c x
+	d 3
+	e }
+f
+{
+	g
+}
+} h
+i [!!!]`}
+
+	var r recordingHandler
+	errs := p.ParseTolerant(&r)
+	assert.Equal(t, strings.Join([]string{
+		"start a",
+		"value 1",
+		"end",
+		"start b",
+		`value "ok"`,
+		"start c",
+		"end",
+		"start d",
+		"value 3",
+		"end",
+		"start e",
+		"end",
+		"end",
+		"start f",
+		"end",
+		"start g",
+		"end",
+		"start i",
+		"end",
+	}, "\n"), strings.Join(r.events, "\n"))
+
+	var messages []string
+	for _, err := range errs {
+		var sdlErr *SdlError
+		assert.True(t, errors.As(err, &sdlErr))
+		messages = append(messages, sdlErr.Message)
+	}
+	assert.Equal(t, []string{
+		"Unexpected character.",
+		"Expected '=' following attribute name",
+		"Closing braces must be on their own line.",
+		"Opening braces have to be on the same line as a tag.",
+		"Found a closing brace without a matching opening brace.",
+		"Invalid base64 in binary literal.",
+	}, messages)
+}
+
+func TestParseTolerantStopsOnHandlerErrors(t *testing.T) {
+	p := SaxParser{Input: "a @\nb 1\nc 2\n"}
+	errs := p.ParseTolerant(&failingHandler{})
+	assert.Equal(t, 2, len(errs))
+	assert.EqualError(t, errs[1], "stop")
+}
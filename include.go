@@ -0,0 +1,115 @@
+package sdlang
+
+import (
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// IncludeOptions configures ParseIntoAstWithIncludes.
+type IncludeOptions struct {
+	// TagName is the qualified name of the tag that includes another file, e.g. `include "common.sdl"`.
+	// Defaults to "include".
+	TagName string
+
+	// FS is the file system that included files are read from.
+	// Paths are resolved relative to the directory of the including file's `SaxParser.FileName`.
+	FS fs.FS
+}
+
+// ParseIntoAstWithIncludes is the same as ParseIntoAst, except every include tag is replaced by the children of the file it includes.
+// Included files may include other files. The debug locations of included tags refer to the file they were included from.
+// An error is returned if a file ends up including itself. Included files are parsed with the same `Options` as `p`,
+// except that `MaxTags` limits the tags in every file put together, so that including the same files repeatedly can't
+// produce an arbitrarily large tree.
+func (p SaxParser) ParseIntoAstWithIncludes(opts IncludeOptions) (SdlTag, error) {
+	root, _, err := p.parseWithIncludes(opts)
+	return root, err
+}
+
+// parseWithIncludes is ParseIntoAstWithIncludes, but also returns the name of every file it tried to read,
+// starting with `p.FileName`.
+func (p SaxParser) parseWithIncludes(opts IncludeOptions) (SdlTag, []string, error) {
+	if opts.TagName == "" {
+		opts.TagName = "include"
+	}
+
+	r := includeResolver{opts: opts, limits: p.Options, files: []string{path.Clean(p.FileName)}}
+	root, err := p.ParseIntoAst()
+	if err != nil {
+		return SdlTag{}, r.files, err
+	}
+
+	err = r.resolve(&root, []string{r.files[0]})
+	if err != nil {
+		return SdlTag{}, r.files, err
+	}
+	return root, r.files, nil
+}
+
+type includeResolver struct {
+	opts   IncludeOptions
+	limits ParseOptions
+	files  []string
+	tags   int // How many tags have been seen so far across every file, including the include tags themselves.
+}
+
+func (r *includeResolver) resolve(tag *SdlTag, stack []string) error {
+	var children []SdlTag
+	for i := range tag.Children {
+		child := &tag.Children[i]
+		r.tags++
+		if r.limits.MaxTags > 0 && r.tags > r.limits.MaxTags {
+			return child.DebugLocation.newRuleError(ruleLimit, "This document and the files it includes have more than the maximum allowed "+strconv.Itoa(r.limits.MaxTags)+" tags.")
+		}
+		if child.QualifiedName != r.opts.TagName {
+			err := r.resolve(child, stack)
+			if err != nil {
+				return err
+			}
+			children = append(children, *child)
+			continue
+		}
+
+		if len(child.Values) != 1 || !child.Values[0].IsString() || len(child.Children) > 0 {
+			return child.DebugLocation.newRuleError(ruleInclude, "Include tags must have exactly one string value and no children.")
+		}
+
+		included, _ := child.Values[0].String()
+		if path.IsAbs(included) {
+			return child.DebugLocation.newRuleError(ruleInclude, "Included files must use a relative path.")
+		}
+		fileName := path.Join(path.Dir(stack[len(stack)-1]), included)
+
+		for _, file := range stack {
+			if file == fileName {
+				return child.DebugLocation.newRuleError(ruleInclude, "Include cycle: "+strings.Join(append(stack[:len(stack):len(stack)], fileName), " -> "))
+			}
+		}
+
+		if r.opts.FS == nil {
+			return child.DebugLocation.newRuleError(ruleInclude, "Cannot include files as no file system was provided.")
+		}
+		r.files = append(r.files, fileName)
+		input, err := fs.ReadFile(r.opts.FS, fileName)
+		if err != nil {
+			return child.DebugLocation.newRuleError(ruleInclude, "Could not read included file: "+err.Error())
+		}
+
+		parser := SaxParser{Input: string(input), FileName: fileName, Options: r.limits}
+		includedRoot, err := parser.ParseIntoAst()
+		if err != nil {
+			return err
+		}
+
+		err = r.resolve(&includedRoot, append(stack[:len(stack):len(stack)], fileName))
+		if err != nil {
+			return err
+		}
+		children = append(children, includedRoot.Children...)
+	}
+
+	tag.Children = children
+	return nil
+}
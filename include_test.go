@@ -0,0 +1,91 @@
+package sdlang
+
+import (
+	"fmt"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInclude(t *testing.T) {
+	fsys := fstest.MapFS{
+		"conf/main.sdl":           {Data: []byte("name \"main\"\ninclude \"common.sdl\"\nservers {\n\tinclude \"servers/all.sdl\"\n}\n")},
+		"conf/common.sdl":         {Data: []byte("timeout 30\nretries 3\n")},
+		"conf/servers/all.sdl":    {Data: []byte("server \"a\"\ninclude \"more.sdl\"\n")},
+		"conf/servers/more.sdl":   {Data: []byte("server \"b\"\n")},
+		"conf/cycle/a.sdl":        {Data: []byte("include \"b.sdl\"\n")},
+		"conf/cycle/b.sdl":        {Data: []byte("\ninclude \"a.sdl\"\n")},
+		"conf/bad/no-value.sdl":   {Data: []byte("include\n")},
+		"conf/bad/missing.sdl":    {Data: []byte("include \"nope.sdl\"\n")},
+		"conf/bad/syntax.sdl":     {Data: []byte("include \"syntax-err.sdl\"\n")},
+		"conf/bad/syntax-err.sdl": {Data: []byte("a \"unterminated\n")},
+	}
+
+	p := SaxParser{Input: string(fsys["conf/main.sdl"].Data), FileName: "conf/main.sdl"}
+	ast, err := p.ParseIntoAstWithIncludes(IncludeOptions{FS: fsys})
+	assert.NoError(t, err)
+
+	expected := parseForTest(t, "", "name \"main\"\ntimeout 30\nretries 3\nservers {\n\tserver \"a\"\n\tserver \"b\"\n}\n")
+	assert.True(t, expected.Equal(ast), FormatChanges(Diff(&expected, &ast)))
+	assert.Equal(t, "conf/main.sdl", ast.Children[0].DebugLocation.File)
+	assert.Equal(t, "conf/common.sdl", ast.Children[1].DebugLocation.File)
+	assert.Equal(t, "conf/servers/more.sdl", ast.Children[3].Children[1].DebugLocation.File)
+
+	for _, file := range []string{"conf/cycle/a.sdl", "conf/bad/no-value.sdl", "conf/bad/missing.sdl", "conf/bad/syntax.sdl"} {
+		p = SaxParser{Input: string(fsys[file].Data), FileName: file}
+		_, err = p.ParseIntoAstWithIncludes(IncludeOptions{FS: fsys})
+		assert.Error(t, err, file)
+	}
+
+	p = SaxParser{Input: string(fsys["conf/cycle/a.sdl"].Data), FileName: "conf/cycle/a.sdl"}
+	_, err = p.ParseIntoAstWithIncludes(IncludeOptions{FS: fsys})
+	assert.Contains(t, err.Error(), "conf/cycle/a.sdl -> conf/cycle/b.sdl -> conf/cycle/a.sdl")
+	assert.Contains(t, err.Error(), "conf/cycle/b.sdl @ 2")
+}
+
+func TestIncludeCustomTagName(t *testing.T) {
+	fsys := fstest.MapFS{"other.sdl": {Data: []byte("b 2\n")}}
+
+	p := SaxParser{Input: "a 1\nimport \"other.sdl\"\ninclude \"other.sdl\"\n"}
+	ast, err := p.ParseIntoAstWithIncludes(IncludeOptions{TagName: "import", FS: fsys})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(ast.Children))
+	assert.Equal(t, "b", ast.Children[1].Name)
+	assert.Equal(t, "include", ast.Children[2].Name)
+}
+
+func TestIncludeLimits(t *testing.T) {
+	fsys := fstest.MapFS{"other.sdl": {Data: []byte("b 1 2 3\n")}}
+
+	p := SaxParser{Input: "a 1\ninclude \"other.sdl\"\n", Options: ParseOptions{MaxValues: 2}}
+	_, err := p.ParseIntoAstWithIncludes(IncludeOptions{FS: fsys})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "other.sdl")
+	}
+
+	_, files, err := p.parseWithIncludes(IncludeOptions{FS: fsys})
+	assert.Error(t, err)
+	assert.Equal(t, []string{".", "other.sdl"}, files)
+}
+
+func TestIncludeTagsShareOneBudget(t *testing.T) {
+	// Each file doubles the amount of tags, so the files put together are far larger than any one of them.
+	fsys := fstest.MapFS{}
+	for i := 0; i < 20; i++ {
+		fsys[fmt.Sprintf("%d.sdl", i)] = &fstest.MapFile{Data: []byte(fmt.Sprintf("include \"%d.sdl\"\ninclude \"%d.sdl\"\n", i+1, i+1))}
+	}
+	fsys["20.sdl"] = &fstest.MapFile{Data: []byte("a\n")}
+
+	p := SaxParser{Input: "include \"0.sdl\"\n", Options: ParseOptions{MaxTags: 1000}}
+	_, files, err := p.parseWithIncludes(IncludeOptions{FS: fsys})
+	if assert.Error(t, err) {
+		assert.Equal(t, ruleLimit, err.(*SdlError).Rule)
+	}
+	assert.Less(t, len(files), 1000)
+
+	p = SaxParser{Input: "include \"15.sdl\"\n", Options: ParseOptions{MaxTags: 1000}}
+	ast, err := p.ParseIntoAstWithIncludes(IncludeOptions{FS: fsys})
+	assert.NoError(t, err)
+	assert.Equal(t, 32, len(ast.Children))
+}
@@ -0,0 +1,243 @@
+package sdlang
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// InterpolateOptions configures Interpolate.
+type InterpolateOptions struct {
+	// Variables are looked up first when expanding a reference.
+	Variables map[string]string
+
+	// DocumentReferences allows references to other parts of the document, which start with a '/'.
+	// `${/servers/host}` expands to the first value of the tag `host` inside of the tag `servers`;
+	// `${/servers/host[1]}` uses the second `host` tag instead; and `${/servers@port}` expands to the `port` attribute of `servers`.
+	DocumentReferences bool
+
+	// Environment allows references to environment variables, which are looked up after Variables.
+	Environment bool
+
+	// LookupEnv is used to look up environment variables. Defaults to os.LookupEnv.
+	LookupEnv func(name string) (string, bool)
+}
+
+// Interpolate expands `${name}` references in every string value and string attribute of `root`.
+// `${name:-default}` expands to `default` if `name` is undefined or empty, and `$${` produces a literal `${`.
+// Referenced values are not expanded again, except for document references which are expanded before being used.
+// An error is returned for undefined references and reference cycles, pointing at the offending value.
+// If an error is returned, `root` is left unmodified.
+func Interpolate(root *SdlTag, opts InterpolateOptions) error {
+	if opts.LookupEnv == nil {
+		opts.LookupEnv = os.LookupEnv
+	}
+
+	in := interpolator{
+		root:      root,
+		opts:      opts,
+		resolving: map[valueKey]bool{},
+		resolved:  map[valueKey]string{},
+	}
+	var apply []func()
+	err := in.collect(root, &apply)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range apply {
+		f()
+	}
+	return nil
+}
+
+type interpolator struct {
+	root      *SdlTag
+	opts      InterpolateOptions
+	resolving map[valueKey]bool
+	resolved  map[valueKey]string
+}
+
+// valueKey identifies a value within the document: the value at `index` of `tag`, or the value of its attribute
+// `attribute` if that isn't empty.
+type valueKey struct {
+	tag       *SdlTag
+	index     int
+	attribute string
+}
+
+func (in *interpolator) collect(root *SdlTag, apply *[]func()) error {
+	var err error
+	Walk(root, VisitorFuncs{
+		OnValue: func(path *WalkPath, index int, value *SdlValue) WalkAction {
+			if !value.IsString() {
+				return WalkContinue
+			}
+			var text string
+			text, err = in.expandAt(valueKey{tag: path.Tag(), index: index}, *value)
+			if err != nil {
+				return WalkStop
+			}
+			*apply = append(*apply, func() { value.vString = text })
+			return WalkContinue
+		},
+		OnAttribute: func(path *WalkPath, attr *SdlAttribute) WalkAction {
+			if !attr.Value.IsString() {
+				return WalkContinue
+			}
+			var text string
+			text, err = in.expandAt(valueKey{tag: path.Tag(), attribute: attr.QualifiedName}, attr.Value)
+			if err != nil {
+				return WalkStop
+			}
+			key := attr.QualifiedName
+			attributes := path.Tag().Attributes
+			*apply = append(*apply, func() {
+				attr := attributes[key]
+				attr.Value.vString = text
+				attributes[key] = attr
+			})
+			return WalkContinue
+		},
+	})
+	return err
+}
+
+// expandAt expands the string `value`, which is found at `key`, caching the result.
+func (in *interpolator) expandAt(key valueKey, value SdlValue) (string, error) {
+	if text, done := in.resolved[key]; done {
+		return text, nil
+	}
+	if in.resolving[key] {
+		return "", value.DebugLocation.newRuleError(ruleInterpolation, "This value references itself through an interpolation cycle.")
+	}
+
+	in.resolving[key] = true
+	text, err := in.expand(value.vString, value.DebugLocation)
+	delete(in.resolving, key)
+	if err != nil {
+		return "", err
+	}
+	in.resolved[key] = text
+	return text, nil
+}
+
+func (in *interpolator) expand(text string, loc SdlDebugLocation) (string, error) {
+	if !strings.Contains(text, "${") {
+		return text, nil
+	}
+
+	var b strings.Builder
+	for {
+		start := strings.Index(text, "${")
+		if start < 0 {
+			b.WriteString(text)
+			return b.String(), nil
+		}
+
+		if start > 0 && text[start-1] == '$' {
+			b.WriteString(text[:start-1])
+			b.WriteString("${")
+			text = text[start+2:]
+			continue
+		}
+
+		end := strings.IndexByte(text[start:], '}')
+		if end < 0 {
+			return "", loc.newRuleError(ruleInterpolation, "Unterminated '${' in interpolated string.")
+		}
+		end += start
+
+		b.WriteString(text[:start])
+		expanded, err := in.lookup(text[start+2:end], loc)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(expanded)
+		text = text[end+1:]
+	}
+}
+
+func (in *interpolator) lookup(reference string, loc SdlDebugLocation) (string, error) {
+	name := reference
+	def, hasDefault := "", false
+	if i := strings.Index(reference, ":-"); i >= 0 {
+		name, def, hasDefault = reference[:i], reference[i+2:], true
+	}
+	if name == "" {
+		return "", loc.newRuleError(ruleInterpolation, "Empty reference in interpolated string.")
+	}
+
+	var text string
+	found := false
+	if strings.HasPrefix(name, "/") && in.opts.DocumentReferences {
+		key, value, exists, err := in.findReference(name, loc)
+		if err != nil {
+			return "", err
+		}
+		if exists && value.IsString() {
+			text, err = in.expandAt(key, value)
+			if err != nil {
+				return "", err
+			}
+			found = true
+		} else if exists {
+			text = value.literal()
+			found = true
+		}
+	} else if text, found = in.opts.Variables[name]; !found && in.opts.Environment {
+		text, found = in.opts.LookupEnv(name)
+	}
+
+	if (!found || text == "") && hasDefault {
+		return def, nil
+	} else if !found {
+		return "", loc.newRuleError(ruleInterpolation, "Undefined reference '"+name+"' in interpolated string.")
+	}
+	return text, nil
+}
+
+// findReference finds the value referenced by a document reference such as "/a/b[1]@attr".
+func (in *interpolator) findReference(reference string, loc SdlDebugLocation) (valueKey, SdlValue, bool, error) {
+	attrName := ""
+	if i := strings.LastIndexByte(reference, '@'); i >= 0 {
+		reference, attrName = reference[:i], reference[i+1:]
+	}
+
+	tag := in.root
+	for _, segment := range strings.Split(strings.Trim(reference, "/"), "/") {
+		occurrence := 0
+		if i := strings.IndexByte(segment, '['); i >= 0 && strings.HasSuffix(segment, "]") {
+			n, err := strconv.Atoi(segment[i+1 : len(segment)-1])
+			if err != nil || n < 0 {
+				return valueKey{}, SdlValue{}, false, loc.newRuleError(ruleInterpolation, "Invalid index in document reference '"+segment+"'.")
+			}
+			segment, occurrence = segment[:i], n
+		}
+
+		index := -1
+		for i := range tag.Children {
+			if tag.Children[i].QualifiedName != segment {
+				continue
+			}
+			if occurrence == 0 {
+				index = i
+				break
+			}
+			occurrence--
+		}
+		if index < 0 {
+			return valueKey{}, SdlValue{}, false, nil
+		}
+		tag = &tag.Children[index]
+	}
+
+	if attrName != "" {
+		attr, exists := tag.Attributes[attrName]
+		return valueKey{tag: tag, attribute: attrName}, attr.Value, exists, nil
+	}
+	if len(tag.Values) == 0 {
+		return valueKey{}, SdlValue{}, false, nil
+	}
+	return valueKey{tag: tag}, tag.Values[0], true, nil
+}
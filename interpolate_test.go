@@ -0,0 +1,89 @@
+package sdlang
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInterpolate(t *testing.T) {
+	ast := parseForTest(t, "", `host "example.com"
+servers {
+	server "a" port=8080
+	server "b" port=9090
+}
+url "https://${/host}:${/servers/server@port}/${path:-index.html}"
+second "${/servers/server[1]}:${/servers/server[1]@port}"
+mixed "${name} in ${HOME}" escaped="$${name}" n=1
+chained "${/url}"
+`)
+
+	env := map[string]string{"HOME": "/home/me"}
+	err := Interpolate(&ast, InterpolateOptions{
+		Variables:          map[string]string{"name": "me"},
+		DocumentReferences: true,
+		Environment:        true,
+		LookupEnv: func(name string) (string, bool) {
+			value, exists := env[name]
+			return value, exists
+		},
+	})
+	assert.NoError(t, err)
+
+	expected := parseForTest(t, "", `host "example.com"
+servers {
+	server "a" port=8080
+	server "b" port=9090
+}
+url "https://example.com:8080/index.html"
+second "b:9090"
+mixed "me in /home/me" escaped="${name}" n=1
+chained "https://example.com:8080/index.html"
+`)
+	assert.True(t, expected.Equal(ast), FormatChanges(Diff(&expected, &ast)))
+}
+
+func TestInterpolateErrors(t *testing.T) {
+	for _, code := range []string{
+		"a \"${undefined}\"\n",
+		"a \"${unterminated\"\n",
+		"a \"${}\"\n",
+		"a \"${/b}\"\nb \"${/a}\"\n",
+		"a \"${/a}\"\n",
+		"a \"${/b[x]}\"\n",
+	} {
+		ast := parseForTest(t, "test.sdl", code)
+		before := ast.Clone()
+		err := Interpolate(&ast, InterpolateOptions{DocumentReferences: true})
+		assert.Error(t, err, code)
+		assert.Contains(t, err.Error(), "test.sdl @ ", code)
+		assert.True(t, before.Equal(ast), code)
+	}
+
+	// Document references are only allowed when enabled.
+	ast := parseForTest(t, "", "a 1\nb \"${/a}\"\n")
+	assert.Error(t, Interpolate(&ast, InterpolateOptions{}))
+}
+
+func TestInterpolateDefaults(t *testing.T) {
+	ast := parseForTest(t, "", "a \"${empty:-x}${missing:-}${/nope:-y}\"\n")
+	err := Interpolate(&ast, InterpolateOptions{Variables: map[string]string{"empty": ""}, DocumentReferences: true})
+	assert.NoError(t, err)
+	s, _ := ast.Children[0].Values[0].String()
+	assert.Equal(t, "xy", s)
+}
+
+func BenchmarkInterpolateWideDocument(b *testing.B) {
+	input := "host \"example.com\"\n" + strings.Repeat("server \"${/host}\" name=\"a\"\n", 10000)
+	ast, err := SaxParser{Input: input}.ParseIntoAst()
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < b.N; i++ {
+		tag := ast.Clone()
+		if err := Interpolate(&tag, InterpolateOptions{DocumentReferences: true}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,314 @@
+package sdlang
+
+import (
+	"unicode/utf8"
+)
+
+// TokenKind describes what kind of token a Token is.
+type TokenKind int
+
+const (
+	TokenEOF TokenKind = iota
+
+	// TokenInvalid covers input that couldn't be lexed, and is returned alongside an error.
+	TokenInvalid
+
+	// TokenWhitespace is a run of spaces and tabs, along with a byte order mark at the start of the input.
+	TokenWhitespace
+	TokenNewLine
+	TokenSemicolon
+
+	// TokenContinuation is a '\' followed by a new line, which continues a tag onto the next line.
+	TokenContinuation
+	TokenComment
+	TokenIdentifier
+	TokenColon
+	TokenEquals
+	TokenOpenBrace
+	TokenCloseBrace
+
+	TokenString
+	TokenCharacter
+	TokenInteger
+	TokenLong
+	TokenFloat
+	TokenDouble
+	TokenDecimal
+	TokenBool
+	TokenNull
+	TokenDate
+	TokenDateTime
+	TokenTimeSpan
+	TokenBinary
+)
+
+var tokenKindNames = []string{
+	"eof", "invalid", "whitespace", "new line", "semicolon", "continuation", "comment", "identifier", "colon", "equals",
+	"open brace", "close brace", "string", "character", "integer", "long", "float", "double", "decimal", "bool", "null",
+	"date", "datetime", "timespan", "binary",
+}
+
+func (k TokenKind) String() string {
+	if k < 0 || int(k) >= len(tokenKindNames) {
+		return "unknown"
+	}
+	return tokenKindNames[k]
+}
+
+// IsLiteral determines whether this is the kind of a literal value, such as TokenString or TokenNull.
+func (k TokenKind) IsLiteral() bool {
+	return k >= TokenString && k <= TokenBinary
+}
+
+// IsTrivia determines whether this is the kind of a token that has no meaning to the parser:
+// whitespace, comments, and line continuations.
+func (k TokenKind) IsTrivia() bool {
+	return k == TokenWhitespace || k == TokenComment || k == TokenContinuation
+}
+
+// Token is a single token produced by Lexer.
+type Token struct {
+	Kind TokenKind
+
+	// Start and End are the byte offsets of the token within the input. End is exclusive.
+	Start int
+	End   int
+
+	// Raw is the token's text exactly as it appears in the input.
+	Raw string
+
+	// Decoded is the value of a literal. For identifiers and comments, it's a string holding the name, or the text
+	// following the comment marker (without the closing "*/" of a block comment). It's null for every other token.
+	Decoded SdlValue
+}
+
+// Lexer splits a document into tokens, including the whitespace, comments, and new lines that SaxParser skips over.
+// Joining the Raw text of every token reproduces the input exactly.
+//
+// Unlike SaxParser, the lexer doesn't decide what a token means based on the tokens around it. So a name is always an
+// identifier rather than a tag or attribute name, namespaces and '=' are separate tokens, and anonymous tags have
+// no token of their own.
+type Lexer struct {
+	// Input is the input to split into tokens.
+	Input string
+
+	// FileName is used for debug messages.
+	FileName string
+
+	p       SaxParser
+	started bool
+	prev    TokenKind
+	name    bool      // Whether the next token is the name following a namespace.
+	locator SaxParser // Used by Location, separately from `p` so that finding a location doesn't disturb lexing.
+}
+
+// Next produces the next token. At the end of the input, a token of kind TokenEOF is returned.
+//
+// If the input can't be lexed, an error is returned alongside a TokenInvalid token covering the problem.
+// Lexing can continue after an error by calling Next again, which is useful for tools such as syntax highlighters.
+func (l *Lexer) Next() (Token, error) {
+	if !l.started {
+		l.started = true
+		l.p = SaxParser{Input: l.Input, FileName: l.FileName}
+	}
+	p := &l.p
+	p.tokenStart = p.cursor
+
+	kind, err := l.next()
+	// The name following a namespace may be a keyword, so "ns:true" is a name rather than a bool.
+	l.name = err == nil && kind == TokenColon && l.prev == TokenIdentifier
+	l.prev = kind
+	if err == nil && !utf8.ValidString(p.Input[p.tokenStart:p.cursor]) {
+		sdlErr := p.errorAt(p.tokenStart+invalidUTF8(p.Input[p.tokenStart:p.cursor]), "Invalid UTF-8. SDLang documents must be encoded as UTF-8.")
+		sdlErr.Rule = ruleEncoding
+		err = sdlErr
+	}
+	if err != nil {
+		// Always move forward, so that lexing can continue.
+		if p.cursor <= p.tokenStart {
+			_, size := p.peekRune()
+			p.cursor = p.tokenStart + size
+		}
+		return l.token(TokenInvalid, Null()), err
+	}
+
+	decoded := Null()
+	switch {
+	case kind == TokenIdentifier || kind == TokenComment:
+		decoded = String(p.text)
+	case kind.IsLiteral():
+		decoded, err = p.Value()
+		if err != nil {
+			return l.token(TokenInvalid, Null()), err
+		}
+	}
+	return l.token(kind, decoded), nil
+}
+
+// Tokens produces every remaining token, not including the final TokenEOF, along with every error that was found.
+func (l *Lexer) Tokens() ([]Token, []error) {
+	var tokens []Token
+	var errs []error
+	for {
+		token, err := l.Next()
+		if err != nil {
+			errs = append(errs, err)
+		} else if token.Kind == TokenEOF {
+			return tokens, errs
+		}
+		tokens = append(tokens, token)
+	}
+}
+
+// Location is the span of `token` within the input. It's quickest when tokens are located in the order they appear in.
+func (l *Lexer) Location(token Token) SdlDebugLocation {
+	if l.locator.Input != l.Input || l.locator.FileName != l.FileName {
+		l.locator = SaxParser{Input: l.Input, FileName: l.FileName}
+	}
+	return l.locator.spanAt(token.Start, token.End)
+}
+
+func (l *Lexer) token(kind TokenKind, decoded SdlValue) Token {
+	p := &l.p
+	decoded.DebugLocation = SdlDebugLocation{}
+	return Token{Kind: kind, Start: p.tokenStart, End: p.cursor, Raw: p.Input[p.tokenStart:p.cursor], Decoded: decoded}
+}
+
+func (l *Lexer) next() (TokenKind, error) {
+	p := &l.p
+	if p.eof() {
+		return TokenEOF, nil
+	}
+
+	ch := p.peek(0)
+	switch {
+	case p.cursor == 0 && len(p.Input) >= len(byteOrderMark) && p.Input[:len(byteOrderMark)] == byteOrderMark:
+		p.advance(len(byteOrderMark))
+		p.eatWhite()
+		return TokenWhitespace, nil
+	case ch == ' ' || ch == '\t':
+		p.eatWhite()
+		return TokenWhitespace, nil
+	case ch == '\n':
+		p.advance(1)
+		return TokenNewLine, nil
+	case ch == '\r':
+		if p.peek(1) != '\n' {
+			return TokenInvalid, p.errorAt(p.cursor, "Stray \\r without a \\n following it.")
+		}
+		p.advance(2)
+		return TokenNewLine, nil
+	case ch == ';':
+		p.advance(1)
+		return TokenSemicolon, nil
+	case ch == '\\' && p.peek(1) == '\n':
+		p.advance(2)
+		return TokenContinuation, nil
+	case ch == '\\' && p.peek(1) == '\r' && p.peek(2) == '\n':
+		p.advance(3)
+		return TokenContinuation, nil
+	case (ch == '/' && p.peek(1) == '/') || (ch == '-' && p.peek(1) == '-') || ch == '#':
+		if ch == '#' {
+			p.advance(1)
+		} else {
+			p.advance(2)
+		}
+		start := p.cursor
+		for !p.eof() && p.peek(0) != '\n' && !(p.peek(0) == '\r' && p.peek(1) == '\n') {
+			p.advance(1)
+		}
+		p.text = p.Input[start:p.cursor]
+		return TokenComment, nil
+	case ch == '/' && p.peek(1) == '*':
+		p.advance(2)
+		start := p.cursor
+		for !p.eof() && !(p.peek(0) == '*' && p.peek(1) == '/') {
+			p.advance(1)
+		}
+		if p.eof() {
+			err := p.errorAt(p.tokenStart, "Unterminated block comment")
+			err.Related = append(err.Related, *p.errorAt(p.cursor, "Expected a terminating '*/' before hitting end of file"))
+			return TokenInvalid, err
+		}
+		p.text = p.Input[start:p.cursor]
+		p.advance(2)
+		return TokenComment, nil
+	case ch == ':':
+		p.advance(1)
+		return TokenColon, nil
+	case ch == '=':
+		p.advance(1)
+		return TokenEquals, nil
+	case ch == '{':
+		p.advance(1)
+		return TokenOpenBrace, nil
+	case ch == '}':
+		p.advance(1)
+		return TokenCloseBrace, nil
+	}
+
+	if r, _ := p.peekRune(); l.name && isIdentifierStart(r) {
+		p.skipIdentifier()
+		p.text = p.Input[p.tokenStart:p.cursor]
+		return TokenIdentifier, nil
+	} else if isIdentifierStart(r) {
+		p.skipIdentifier()
+		switch p.Input[p.tokenStart:p.cursor] {
+		case "true", "on":
+			p.t, p.boolean = boolean, true
+			return TokenBool, nil
+		case "false", "off":
+			p.t, p.boolean = boolean, false
+			return TokenBool, nil
+		case "null":
+			p.t = null
+			return TokenNull, nil
+		}
+		p.text = p.Input[p.tokenStart:p.cursor]
+		return TokenIdentifier, nil
+	}
+
+	var err error
+	switch {
+	case ch == '"':
+		err = p.nextDoubleQuotedString()
+	case ch == '`':
+		err = p.nextBacktickString()
+	case ch == '\'':
+		err = p.nextCharacter()
+	case ch == '[':
+		err = p.nextBinary()
+	case isDigit(ch) || ch == '-':
+		err = p.nextNumeric()
+	default:
+		return TokenInvalid, p.errorAt(p.cursor, "Unexpected character.")
+	}
+	if err != nil {
+		return TokenInvalid, err
+	}
+
+	switch p.t {
+	case string_:
+		return TokenString, nil
+	case character:
+		return TokenCharacter, nil
+	case integer:
+		return TokenInteger, nil
+	case long:
+		return TokenLong, nil
+	case float:
+		return TokenFloat, nil
+	case double:
+		return TokenDouble, nil
+	case decimal:
+		return TokenDecimal, nil
+	case date:
+		return TokenDate, nil
+	case dateTime:
+		return TokenDateTime, nil
+	case timeSpan:
+		return TokenTimeSpan, nil
+	default:
+		return TokenBinary, nil
+	}
+}
@@ -0,0 +1,126 @@
+package sdlang
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func lexForTest(t *testing.T, input string) []Token {
+	l := Lexer{Input: input}
+	tokens, errs := l.Tokens()
+	assert.Empty(t, errs)
+	return tokens
+}
+
+func TestLexer(t *testing.T) {
+	input := "ns:tag 1 \"a\\tb\" key=true {\t// comment\r\n\tchild [aGk=] \\\n 2021/01/02 12:00:00 /* block */ 1.5f 12:30:00; 'c' off null\n}"
+	tokens := lexForTest(t, input)
+
+	var kinds []TokenKind
+	var raw strings.Builder
+	for _, token := range tokens {
+		kinds = append(kinds, token.Kind)
+		raw.WriteString(token.Raw)
+		assert.Equal(t, input[token.Start:token.End], token.Raw)
+	}
+	assert.Equal(t, input, raw.String(), "the raw text of every token reproduces the input")
+	assert.Equal(t, []TokenKind{
+		TokenIdentifier, TokenColon, TokenIdentifier, TokenWhitespace, TokenInteger, TokenWhitespace, TokenString,
+		TokenWhitespace, TokenIdentifier, TokenEquals, TokenBool, TokenWhitespace, TokenOpenBrace, TokenWhitespace,
+		TokenComment, TokenNewLine,
+		TokenWhitespace, TokenIdentifier, TokenWhitespace, TokenBinary, TokenWhitespace, TokenContinuation,
+		TokenWhitespace, TokenDateTime, TokenWhitespace, TokenComment, TokenWhitespace, TokenFloat, TokenWhitespace,
+		TokenTimeSpan, TokenSemicolon, TokenWhitespace, TokenCharacter, TokenWhitespace, TokenBool, TokenWhitespace,
+		TokenNull, TokenNewLine,
+		TokenCloseBrace,
+	}, kinds)
+
+	s, _ := tokens[0].Decoded.String()
+	assert.Equal(t, "ns", s)
+	s, _ = tokens[6].Decoded.String()
+	assert.Equal(t, "a\tb", s)
+	assert.Equal(t, "\"a\\tb\"", tokens[6].Raw)
+	s, _ = tokens[14].Decoded.String()
+	assert.Equal(t, " comment", s)
+	assert.Equal(t, "\r\n", tokens[15].Raw)
+	b, _ := tokens[19].Decoded.Binary()
+	assert.Equal(t, []byte("hi"), b)
+	dt, _ := tokens[23].Decoded.DateTime()
+	assert.Equal(t, time.Date(2021, 1, 2, 12, 0, 0, 0, time.UTC), dt)
+	s, _ = tokens[25].Decoded.String()
+	assert.Equal(t, " block ", s)
+	ts, _ := tokens[29].Decoded.TimeSpan()
+	assert.Equal(t, 12*time.Hour+30*time.Minute, ts)
+	v, _ := tokens[34].Decoded.Bool()
+	assert.False(t, v)
+	assert.True(t, tokens[36].Decoded.IsNull())
+	assert.True(t, tokens[3].Decoded.IsNull(), "trivia has no decoded value")
+
+	assert.True(t, TokenDate.IsLiteral())
+	assert.False(t, TokenIdentifier.IsLiteral())
+	assert.True(t, TokenContinuation.IsTrivia())
+	assert.False(t, TokenNewLine.IsTrivia())
+	assert.Equal(t, "open brace", TokenOpenBrace.String())
+}
+
+func TestLexerAnonymousTag(t *testing.T) {
+	// Unlike SaxParser, the lexer doesn't produce a "content" name for anonymous tags.
+	tokens := lexForTest(t, "\"a\" \"b\"\n")
+	assert.Equal(t, 4, len(tokens))
+	assert.Equal(t, TokenString, tokens[0].Kind)
+}
+
+func TestLexerNamespacedNames(t *testing.T) {
+	// Keywords are names after a namespace, but other tokens aren't.
+	tokens := lexForTest(t, "ns:true ns:-1")
+	var kinds []TokenKind
+	for _, token := range tokens {
+		kinds = append(kinds, token.Kind)
+	}
+	assert.Equal(t, []TokenKind{
+		TokenIdentifier, TokenColon, TokenIdentifier, TokenWhitespace, TokenIdentifier, TokenColon, TokenInteger,
+	}, kinds)
+	assert.Equal(t, String("true"), tokens[2].Decoded)
+}
+
+func TestLexerByteOrderMark(t *testing.T) {
+	tokens := lexForTest(t, byteOrderMark+" a")
+	assert.Equal(t, 2, len(tokens))
+	assert.Equal(t, TokenWhitespace, tokens[0].Kind)
+	assert.Equal(t, byteOrderMark+" ", tokens[0].Raw)
+}
+
+func TestLexerErrors(t *testing.T) {
+	l := Lexer{Input: "a \"unterminated\nb 1.2.3 99999999999999999999 \"\xff\" c", FileName: "test.sdl"}
+	tokens, errs := l.Tokens()
+	assert.Equal(t, 5, len(errs))
+	assert.Equal(t, "Unterminated string", errs[0].(*SdlError).Message)
+	assert.Equal(t, "There are multiple decimal places in this number.", errs[1].(*SdlError).Message)
+	assert.Equal(t, "Unexpected character.", errs[2].(*SdlError).Message)
+	assert.Equal(t, "Invalid integer.", errs[3].(*SdlError).Message)
+	assert.Equal(t, ruleEncoding, errs[4].(*SdlError).Rule)
+
+	var raw strings.Builder
+	var invalid []string
+	for _, token := range tokens {
+		raw.WriteString(token.Raw)
+		if token.Kind == TokenInvalid {
+			invalid = append(invalid, token.Raw)
+		}
+	}
+	assert.Equal(t, l.Input, raw.String(), "lexing continues after errors")
+	assert.Equal(t, []string{"\"unterminated", "1.2", ".", "99999999999999999999", "\"\xff\""}, invalid)
+	assert.Equal(t, TokenIdentifier, tokens[len(tokens)-1].Kind)
+
+	loc := l.Location(tokens[len(tokens)-1])
+	assert.Equal(t, "test.sdl", loc.File)
+	assert.Equal(t, 2, loc.LineNumber)
+	assert.Equal(t, 33, loc.Loc)
+
+	token, err := l.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, TokenEOF, token.Kind)
+}
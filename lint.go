@@ -0,0 +1,420 @@
+package sdlang
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// LintRule checks documents for one kind of style or correctness problem.
+type LintRule struct {
+	// Name identifies the rule in configs, suppression comments, and Diagnostic.Rule, such as "deprecated-boolean".
+	Name string
+
+	// Description is a short, human-readable summary of what the rule checks.
+	Description string
+
+	// Severity is the severity of the rule's diagnostics, unless the config gives it a different one.
+	Severity Severity
+
+	// Disabled rules only run when they're listed in the config.
+	Disabled bool
+
+	// CheckOptions is called by NewLinter to validate the rule's options. It may be nil.
+	CheckOptions func(options *SdlTag) error
+
+	// Check reports each problem it finds with LintContext.Report.
+	Check func(ctx *LintContext)
+}
+
+// LintContext is given to LintRule.Check, and holds everything that's known about the document being linted.
+type LintContext struct {
+	FileName string
+	Source   string
+	Root     *SdlTag
+
+	// Tokens is every token in Source, including whitespace and comments.
+	Tokens []Token
+
+	// Options is the rule's tag within the config, which is an empty tag if the rule isn't listed in the config.
+	Options *SdlTag
+
+	// Config is the config of the Linter.
+	Config *LintConfig
+
+	rule        *LintRule
+	severity    Severity
+	diagnostics []Diagnostic
+	lexer       Lexer
+}
+
+// Report reports a problem at `loc`. `fix` is nil if the problem can't be fixed automatically.
+func (c *LintContext) Report(loc SdlDebugLocation, message string, fix *Fix) {
+	if loc.File == "" {
+		loc.File = c.FileName
+	}
+	c.diagnostics = append(c.diagnostics, Diagnostic{Severity: c.severity, Rule: c.rule.Name, Message: message, Location: loc, Fix: fix})
+}
+
+// Location is the span between the byte offsets `start` and `end` within Source.
+func (c *LintContext) Location(start, end int) SdlDebugLocation {
+	return c.lexer.Location(Token{Start: start, End: end})
+}
+
+// TextEdit replaces the text between the byte offsets Start and End with NewText.
+type TextEdit struct {
+	Start   int
+	End     int
+	NewText string
+}
+
+// Fix is an automatic fix for a Diagnostic.
+type Fix struct {
+	// Description is a short summary of the fix, such as "Replace 'on' with 'true'".
+	Description string
+
+	// Edits are applied together, and must not overlap.
+	Edits []TextEdit
+}
+
+// ApplyFixes applies the fix of each diagnostic to `source`, returning the fixed source and how many fixes were applied.
+// Fixes that overlap a fix that has already been applied are skipped, so linting the result may find more to fix.
+func ApplyFixes(source string, diagnostics []Diagnostic) (string, int) {
+	var fixes []*Fix
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Fix != nil && len(diagnostic.Fix.Edits) > 0 {
+			fixes = append(fixes, diagnostic.Fix)
+		}
+	}
+	sort.SliceStable(fixes, func(i, j int) bool {
+		return fixes[i].Edits[0].Start < fixes[j].Edits[0].Start
+	})
+
+	var edits []TextEdit
+	applied := 0
+	for _, fix := range fixes {
+		overlaps := false
+		for _, edit := range fix.Edits {
+			if edit.Start < 0 || edit.End < edit.Start || edit.End > len(source) {
+				overlaps = true
+			}
+			for _, other := range edits {
+				if edit.Start < other.End && other.Start < edit.End || edit.Start == other.Start {
+					overlaps = true
+				}
+			}
+		}
+		if !overlaps {
+			edits = append(edits, fix.Edits...)
+			applied++
+		}
+	}
+	sort.Slice(edits, func(i, j int) bool {
+		return edits[i].Start < edits[j].Start
+	})
+
+	var b strings.Builder
+	at := 0
+	for _, edit := range edits {
+		b.WriteString(source[at:edit.Start])
+		b.WriteString(edit.NewText)
+		at = edit.End
+	}
+	b.WriteString(source[at:])
+	return b.String(), applied
+}
+
+// LintConfig configures a Linter. It's usually loaded from an SDLang file with LoadLintConfig, such as:
+//
+//	namespaces "app" "db"
+//	rules {
+//		deprecated-boolean "error"
+//		trailing-whitespace "off"
+//		naming-convention tags="kebab-case"
+//		max-depth max=4
+//	}
+//
+// Each tag within "rules" names a rule. Its first value is the rule's severity, which is "error", "warning", "note",
+// or "off" to disable the rule. The severity can be left out, in which case the rule's own severity is used.
+// The rule's attributes and children are its options.
+type LintConfig struct {
+	// Rules configures each rule by name. Rules that aren't listed use their defaults.
+	Rules map[string]LintRuleConfig
+
+	// Namespaces are the namespaces that documents are allowed to use.
+	Namespaces []string
+}
+
+// LintRuleConfig configures a single rule.
+type LintRuleConfig struct {
+	// Off disables the rule.
+	Off bool
+
+	// Severity overrides the rule's severity if it isn't nil.
+	Severity *Severity
+
+	// Options is the rule's tag within the config.
+	Options SdlTag
+}
+
+// LoadLintConfig reads and parses the config in the given file. See LintConfig for its format.
+func LoadLintConfig(fileName string) (LintConfig, error) {
+	input, err := os.ReadFile(fileName)
+	if err != nil {
+		return LintConfig{}, err
+	}
+	p := SaxParser{Input: string(input), FileName: fileName, Options: DefaultParseOptions()}
+	root, err := p.ParseIntoAst()
+	if err != nil {
+		return LintConfig{}, err
+	}
+	return ParseLintConfig(root)
+}
+
+// ParseLintConfig converts the root tag of a config into a LintConfig. See LintConfig for its format.
+func ParseLintConfig(root SdlTag) (LintConfig, error) {
+	config := LintConfig{Rules: map[string]LintRuleConfig{}}
+	for _, tag := range root.Children {
+		switch tag.QualifiedName {
+		case "namespaces":
+			for _, value := range tag.Values {
+				namespace, err := value.String()
+				if err != nil {
+					return LintConfig{}, value.DebugLocation.NewError("Namespaces must be strings.")
+				}
+				config.Namespaces = append(config.Namespaces, namespace)
+			}
+		case "rules":
+			for _, rule := range tag.Children {
+				ruleConfig := LintRuleConfig{Options: rule}
+				if len(rule.Values) > 0 {
+					level, _ := rule.Values[0].String()
+					if level == "off" {
+						ruleConfig.Off = true
+					} else if severity, ok := parseSeverity(level); ok {
+						ruleConfig.Severity = &severity
+					} else {
+						return LintConfig{}, rule.Values[0].DebugLocation.NewError("Expected the severity of the rule to be \"error\", \"warning\", \"note\", or \"off\".")
+					}
+				}
+				config.Rules[rule.QualifiedName] = ruleConfig
+			}
+		default:
+			return LintConfig{}, tag.DebugLocation.NewError("Unknown tag '" + tag.QualifiedName + "'. Expected 'rules' or 'namespaces'.")
+		}
+	}
+	return config, nil
+}
+
+func parseSeverity(level string) (Severity, bool) {
+	for _, severity := range []Severity{SeverityError, SeverityWarning, SeverityNote} {
+		if severity.String() == level {
+			return severity, true
+		}
+	}
+	return 0, false
+}
+
+// Linter checks documents with a set of rules.
+type Linter struct {
+	config LintConfig
+	rules  []*LintRule
+}
+
+// NewLinter creates a linter that runs the built-in rules (see LintRules), along with any `extra` rules,
+// configured by `config`. An error is returned if the config names a rule that doesn't exist, or has invalid options.
+func NewLinter(config LintConfig, extra ...LintRule) (*Linter, error) {
+	l := &Linter{config: config}
+	known := map[string]bool{}
+	for _, rule := range append(LintRules(), extra...) {
+		rule := rule
+		known[rule.Name] = true
+		ruleConfig, configured := config.Rules[rule.Name]
+		if ruleConfig.Off || (rule.Disabled && !configured) {
+			continue
+		}
+		if rule.CheckOptions != nil {
+			if err := rule.CheckOptions(&ruleConfig.Options); err != nil {
+				return nil, err
+			}
+		}
+		l.rules = append(l.rules, &rule)
+	}
+
+	var names []string
+	for name := range config.Rules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !known[name] {
+			return nil, config.Rules[name].Options.DebugLocation.NewError("Unknown rule '" + name + "'.")
+		}
+	}
+	return l, nil
+}
+
+// Lint checks `source`, returning a diagnostic for each problem in order of where it is. If the source can't be parsed,
+// the syntax errors are returned instead.
+//
+// Diagnostics can be suppressed with comments. "sdl-lint-disable-line" suppresses diagnostics on the same line as the
+// comment, "sdl-lint-disable-next-line" suppresses them on the following line, and "sdl-lint-disable" suppresses them
+// until the end of the file. Each of these can be followed by the names of the rules to suppress, otherwise every rule
+// is suppressed. "sdl-lint-enable" stops suppressing the rules it names, or every rule if it names none. For example:
+//
+//	legacy on # sdl-lint-disable-line deprecated-boolean
+func (l *Linter) Lint(fileName, source string) []Diagnostic {
+	p := SaxParser{Input: source, FileName: fileName}
+	root, err := p.ParseIntoAst()
+	if err != nil {
+		return DiagnosticsFromErrors(err)
+	}
+	lexer := Lexer{Input: source, FileName: fileName}
+	tokens, errs := lexer.Tokens()
+	if len(errs) > 0 {
+		return DiagnosticsFromErrors(errs...)
+	}
+
+	var diagnostics []Diagnostic
+	for _, rule := range l.rules {
+		ctx := LintContext{
+			FileName: fileName,
+			Source:   source,
+			Root:     &root,
+			Tokens:   tokens,
+			Config:   &l.config,
+			rule:     rule,
+			severity: rule.Severity,
+			lexer:    lexer,
+		}
+		ruleConfig := l.config.Rules[rule.Name]
+		ctx.Options = &ruleConfig.Options
+		if ruleConfig.Severity != nil {
+			ctx.severity = *ruleConfig.Severity
+		}
+		rule.Check(&ctx)
+		diagnostics = append(diagnostics, ctx.diagnostics...)
+	}
+
+	suppressions := findSuppressions(&lexer, tokens)
+	kept := diagnostics[:0]
+	for _, diagnostic := range diagnostics {
+		if !suppressions.suppresses(diagnostic) {
+			kept = append(kept, diagnostic)
+		}
+	}
+	sort.SliceStable(kept, func(i, j int) bool {
+		return kept[i].Location.Offset < kept[j].Location.Offset
+	})
+	return kept
+}
+
+// Fix lints `source` and applies every fix, repeating until there's nothing left to fix.
+// It returns the fixed source, along with the diagnostics that remain.
+func (l *Linter) Fix(fileName, source string) (string, []Diagnostic) {
+	// Each pass applies at least one fix, so this only stops early if fixes keep undoing each other.
+	for pass := 0; pass < 10; pass++ {
+		fixed, applied := ApplyFixes(source, l.Lint(fileName, source))
+		if applied == 0 {
+			break
+		}
+		source = fixed
+	}
+	return source, l.Lint(fileName, source)
+}
+
+// suppression suppresses the given rules, or every rule besides `except` if there are none, from line `from` to line
+// `to` inclusive.
+type suppression struct {
+	from, to int
+	rules    []string
+	except   []string
+}
+
+type suppressions []suppression
+
+func findSuppressions(lexer *Lexer, tokens []Token) suppressions {
+	var found suppressions
+	open := map[string]int{} // Rule -> index of the suppression started by an "sdl-lint-disable" comment, where "" is every rule.
+	closeAt := func(rule string, line int) (suppression, bool) {
+		i, exists := open[rule]
+		if !exists {
+			return suppression{}, false
+		}
+		found[i].to = line
+		delete(open, rule)
+		return found[i], true
+	}
+	for _, token := range tokens {
+		if token.Kind != TokenComment {
+			continue
+		}
+		text, _ := token.Decoded.String()
+		fields := strings.FieldsFunc(text, func(r rune) bool {
+			return r == ' ' || r == '\t' || r == ','
+		})
+		if len(fields) == 0 {
+			continue
+		}
+		line := lexer.Location(token).LineNumber
+		rules := fields[1:]
+		switch fields[0] {
+		case "sdl-lint-disable-line":
+			found = append(found, suppression{from: line, to: line, rules: rules})
+		case "sdl-lint-disable-next-line":
+			found = append(found, suppression{from: line + 1, to: line + 1, rules: rules})
+		case "sdl-lint-disable":
+			if len(rules) == 0 {
+				rules = []string{""}
+			}
+			for _, rule := range rules {
+				if _, exists := open[rule]; exists {
+					continue
+				}
+				disabled := suppression{from: line, to: -1}
+				if rule != "" {
+					disabled.rules = []string{rule}
+				}
+				open[rule] = len(found)
+				found = append(found, disabled)
+			}
+		case "sdl-lint-enable":
+			if len(rules) == 0 {
+				for rule := range open {
+					closeAt(rule, line)
+				}
+				continue
+			}
+			for _, rule := range rules {
+				closeAt(rule, line)
+			}
+			// Enabling a rule while every rule is suppressed carries on suppressing the others.
+			if all, exists := closeAt("", line); exists {
+				open[""] = len(found)
+				found = append(found, suppression{from: line, to: -1, except: append(all.except[:len(all.except):len(all.except)], rules...)})
+			}
+		}
+	}
+	return found
+}
+
+func (s suppressions) suppresses(diagnostic Diagnostic) bool {
+	line := diagnostic.Location.LineNumber
+	if line == 0 {
+		line = 1 // Problems with the whole file are treated as being at the start of it.
+	}
+	for _, suppression := range s {
+		if line < suppression.from || (suppression.to >= 0 && line > suppression.to) {
+			continue
+		}
+		if containsString(suppression.rules, diagnostic.Rule) || (len(suppression.rules) == 0 && !containsString(suppression.except, diagnostic.Rule)) {
+			return true
+		}
+	}
+	return false
+}
+
+func lintOptionError(options *SdlTag, msg string, args ...interface{}) error {
+	return options.DebugLocation.NewError(fmt.Sprintf(msg, args...))
+}
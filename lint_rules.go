@@ -0,0 +1,413 @@
+package sdlang
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// LintRules returns the built-in lint rules:
+//
+//   - duplicate-tag reports a tag that is exactly the same as an earlier sibling, which can be fixed by removing it.
+//     Anonymous tags are ignored, as they're often used for lists. Its `unique` option lists the names of tags that
+//     should only appear once among their siblings, such as `unique "port"`.
+//   - deprecated-boolean reports the "on" and "off" booleans, which can be fixed by using "true" and "false" instead.
+//   - mixed-quotes reports strings that don't use the same quotes as the rest of the document, which can be fixed by
+//     converting them where possible. Its `style` option is "double" or "backtick" to require a particular style.
+//   - unknown-namespace reports namespaces that aren't listed in LintConfig.Namespaces or declared by the document
+//     (see NamespaceDeclarationPrefix), along with malformed declarations. It does nothing if no namespaces are known.
+//   - unused-namespace reports namespaces that are listed or declared, but aren't used. It's disabled by default.
+//   - naming-convention reports names that don't follow a convention: "snake_case", "kebab-case", "camelCase", or
+//     "PascalCase". The `tags`, `attributes`, and `namespaces` options choose the convention for each kind of name,
+//     with tags and attributes defaulting to "snake_case". It's disabled by default.
+//   - trailing-whitespace reports whitespace at the end of a line, which can be fixed by removing it.
+//   - max-depth reports tags that are nested more deeply than its `max` option, which defaults to 8.
+func LintRules() []LintRule {
+	return []LintRule{
+		{
+			Name:         "duplicate-tag",
+			Description:  "Tags that are repeated among their siblings.",
+			Severity:     SeverityWarning,
+			CheckOptions: checkDuplicateTagOptions,
+			Check:        checkDuplicateTags,
+		},
+		{
+			Name:        "deprecated-boolean",
+			Description: "The deprecated \"on\" and \"off\" booleans.",
+			Severity:    SeverityWarning,
+			Check:       checkDeprecatedBooleans,
+		},
+		{
+			Name:         "mixed-quotes",
+			Description:  "Strings that use a different style of quotes to the rest of the document.",
+			Severity:     SeverityWarning,
+			CheckOptions: checkMixedQuotesOptions,
+			Check:        checkMixedQuotes,
+		},
+		{
+			Name:        "unknown-namespace",
+			Description: "Namespaces that aren't listed in the config or declared by the document.",
+			Severity:    SeverityError,
+			Check:       checkUnknownNamespaces,
+		},
+		{
+			Name:        "unused-namespace",
+			Description: "Namespaces that are listed in the config or declared by the document, but aren't used.",
+			Severity:    SeverityWarning,
+			Disabled:    true,
+			Check:       checkUnusedNamespaces,
+		},
+		{
+			Name:         "naming-convention",
+			Description:  "Names that don't follow a naming convention.",
+			Severity:     SeverityWarning,
+			Disabled:     true,
+			CheckOptions: checkNamingConventionOptions,
+			Check:        checkNamingConvention,
+		},
+		{
+			Name:        "trailing-whitespace",
+			Description: "Whitespace at the end of a line.",
+			Severity:    SeverityWarning,
+			Check:       checkTrailingWhitespace,
+		},
+		{
+			Name:         "max-depth",
+			Description:  "Tags that are nested too deeply.",
+			Severity:     SeverityWarning,
+			CheckOptions: checkMaxDepthOptions,
+			Check:        checkMaxDepth,
+		},
+	}
+}
+
+// nameLocation is the span of the tag's qualified name, or of the entire tag if it's anonymous.
+func (c *LintContext) nameLocation(tag *SdlTag) SdlDebugLocation {
+	if c.isAnonymous(tag) {
+		return c.Location(tag.DebugLocation.Offset, tag.DebugLocation.End.Offset)
+	}
+	return c.Location(tag.DebugLocation.Offset, tag.DebugLocation.Offset+len(tag.QualifiedName))
+}
+
+// isAnonymous determines whether the tag was written without a name, and so was given the name "content".
+func (c *LintContext) isAnonymous(tag *SdlTag) bool {
+	return tag.QualifiedName == "content" && !strings.HasPrefix(c.Source[tag.DebugLocation.Offset:], "content")
+}
+
+// forEachTag calls `f` with every tag in the document, apart from the root.
+func (c *LintContext) forEachTag(f func(path *WalkPath, tag *SdlTag) WalkAction) {
+	Walk(c.Root, VisitorFuncs{OnEnterTag: func(path *WalkPath, tag *SdlTag) WalkAction {
+		if path.Depth() == 0 {
+			return WalkContinue
+		}
+		return f(path, tag)
+	}})
+}
+
+func checkDuplicateTagOptions(options *SdlTag) error {
+	var err error
+	options.ForEachChild(func(child *SdlTag) {
+		if child.QualifiedName != "unique" {
+			err = lintOptionError(child, "Unknown option '%s'. Expected 'unique'.", child.QualifiedName)
+		}
+		for _, value := range child.Values {
+			if !value.IsString() && err == nil {
+				err = value.DebugLocation.NewError("The names of unique tags must be strings.")
+			}
+		}
+	})
+	return err
+}
+
+func checkDuplicateTags(ctx *LintContext) {
+	unique := map[string]bool{}
+	ctx.Options.ForEachChildByName("unique", func(child *SdlTag) {
+		for _, value := range child.Values {
+			name, _ := value.String()
+			unique[name] = true
+		}
+	})
+
+	Walk(ctx.Root, VisitorFuncs{OnEnterTag: func(path *WalkPath, tag *SdlTag) WalkAction {
+		first := map[string]*SdlTag{}
+		// Siblings are grouped by their hash, so that only tags that might be equal are compared.
+		similar := map[uint64][]*SdlTag{}
+		for i := range tag.Children {
+			child := &tag.Children[i]
+			if ctx.isAnonymous(child) {
+				continue
+			}
+			key := child.Hash()
+			for _, earlier := range similar[key] {
+				if earlier.Equal(*child) {
+					ctx.Report(
+						ctx.nameLocation(child),
+						fmt.Sprintf("This tag is the same as the one on line %d.", earlier.DebugLocation.LineNumber),
+						ctx.removeLinesFix(child.DebugLocation, "Remove the duplicate tag"),
+					)
+					child = nil
+					break
+				}
+			}
+			if child == nil {
+				continue
+			}
+			similar[key] = append(similar[key], child)
+			if earlier, exists := first[child.QualifiedName]; exists && unique[child.QualifiedName] {
+				ctx.Report(
+					ctx.nameLocation(child),
+					fmt.Sprintf("There should only be one '%s' tag, but there's already one on line %d.", child.QualifiedName, earlier.DebugLocation.LineNumber),
+					nil,
+				)
+			} else if !exists {
+				first[child.QualifiedName] = child
+			}
+		}
+		return WalkContinue
+	}})
+}
+
+// removeLinesFix removes the lines that `loc` spans, if nothing else is on them.
+func (c *LintContext) removeLinesFix(loc SdlDebugLocation, description string) *Fix {
+	start, end := loc.Offset, loc.End.Offset
+	for start > 0 && (c.Source[start-1] == ' ' || c.Source[start-1] == '\t') {
+		start--
+	}
+	for end < len(c.Source) && (c.Source[end] == ' ' || c.Source[end] == '\t') {
+		end++
+	}
+	if start > 0 && c.Source[start-1] != '\n' {
+		return nil
+	}
+	if strings.HasPrefix(c.Source[end:], "\r\n") {
+		end += 2
+	} else if strings.HasPrefix(c.Source[end:], "\n") {
+		end++
+	} else if end != len(c.Source) {
+		return nil
+	}
+	return &Fix{Description: description, Edits: []TextEdit{{Start: start, End: end}}}
+}
+
+func checkDeprecatedBooleans(ctx *LintContext) {
+	for _, token := range ctx.Tokens {
+		if token.Kind != TokenBool || (token.Raw != "on" && token.Raw != "off") {
+			continue
+		}
+		replacement := "true"
+		if token.Raw == "off" {
+			replacement = "false"
+		}
+		ctx.Report(
+			ctx.Location(token.Start, token.End),
+			fmt.Sprintf("'%s' is deprecated. Use '%s' instead.", token.Raw, replacement),
+			&Fix{Description: fmt.Sprintf("Replace '%s' with '%s'", token.Raw, replacement), Edits: []TextEdit{{token.Start, token.End, replacement}}},
+		)
+	}
+}
+
+func checkMixedQuotesOptions(options *SdlTag) error {
+	if attr, exists := options.Attributes["style"]; exists {
+		if style, _ := attr.Value.String(); style != "double" && style != "backtick" {
+			return attr.Value.DebugLocation.NewError("Expected the style to be \"double\" or \"backtick\".")
+		}
+	}
+	return nil
+}
+
+func checkMixedQuotes(ctx *LintContext) {
+	style := ""
+	if attr, exists := ctx.Options.Attributes["style"]; exists {
+		style, _ = attr.Value.String()
+	}
+	for _, token := range ctx.Tokens {
+		if token.Kind != TokenString {
+			continue
+		}
+		tokenStyle := "double"
+		if token.Raw[0] == '`' {
+			tokenStyle = "backtick"
+		}
+		if style == "" {
+			style = tokenStyle
+		}
+		if tokenStyle == style {
+			continue
+		}
+
+		value, _ := token.Decoded.String()
+		var fix *Fix
+		if style == "double" {
+			fix = &Fix{Description: "Use double quotes", Edits: []TextEdit{{token.Start, token.End, quoteString(value)}}}
+		} else if !strings.ContainsAny(value, "`\r") {
+			fix = &Fix{Description: "Use backticks", Edits: []TextEdit{{token.Start, token.End, "`" + value + "`"}}}
+		}
+		quotes := "double quotes"
+		if tokenStyle == "backtick" {
+			quotes = "backticks"
+		}
+		ctx.Report(ctx.Location(token.Start, token.End), "This string uses "+quotes+", unlike the rest of the document.", fix)
+	}
+}
+
+// knownNamespaces returns the namespaces listed in the config, along with those declared by the document itself
+// (see NamespaceDeclarationPrefix). A malformed declaration is reported by unknown-namespace.
+func knownNamespaces(ctx *LintContext) (*NamespaceRegistry, error) {
+	var registry NamespaceRegistry
+	err := registry.DeclareFromDocument(ctx.Root)
+	for _, namespace := range ctx.Config.Namespaces {
+		_ = registry.Declare(namespace, "")
+	}
+	return &registry, err
+}
+
+func checkUnknownNamespaces(ctx *LintContext) {
+	registry, err := knownNamespaces(ctx)
+	if sdlErr, ok := err.(*SdlError); ok {
+		ctx.Report(sdlErr.Location, sdlErr.Message, nil)
+	}
+	if len(registry.Namespaces()) == 0 {
+		return
+	}
+	check := func(namespace string, loc SdlDebugLocation) {
+		if _, exists := registry.Lookup(namespace); namespace != "" && !exists {
+			ctx.Report(ctx.Location(loc.Offset, loc.Offset+len(namespace)), "Unknown namespace '"+namespace+"'.", nil)
+		}
+	}
+	Walk(ctx.Root, VisitorFuncs{
+		OnEnterTag: func(path *WalkPath, tag *SdlTag) WalkAction {
+			if tag.Namespace != NamespaceDeclarationPrefix {
+				check(tag.Namespace, tag.DebugLocation)
+			} else if path.Depth() > 1 {
+				ctx.Report(ctx.nameLocation(tag), "Namespace declarations must be top-level tags.", nil)
+			}
+			return WalkContinue
+		},
+		OnAttribute: func(path *WalkPath, attr *SdlAttribute) WalkAction {
+			check(attr.Namespace, attr.DebugLocation)
+			return WalkContinue
+		},
+	})
+}
+
+func checkUnusedNamespaces(ctx *LintContext) {
+	used := map[string]bool{}
+	Walk(ctx.Root, VisitorFuncs{
+		OnEnterTag: func(path *WalkPath, tag *SdlTag) WalkAction {
+			used[tag.Namespace] = true
+			return WalkContinue
+		},
+		OnAttribute: func(path *WalkPath, attr *SdlAttribute) WalkAction {
+			used[attr.Namespace] = true
+			return WalkContinue
+		},
+	})
+	registry, _ := knownNamespaces(ctx)
+	for _, ns := range registry.Namespaces() {
+		if !used[ns.Prefix] {
+			ctx.Report(ns.DebugLocation, "The namespace '"+ns.Prefix+"' isn't used.", nil)
+		}
+	}
+}
+
+var namingConventions = map[string]*regexp.Regexp{
+	"snake_case": regexp.MustCompile(`^[a-z][a-z0-9]*(_[a-z0-9]+)*$`),
+	"kebab-case": regexp.MustCompile(`^[a-z][a-z0-9]*(-[a-z0-9]+)*$`),
+	"camelCase":  regexp.MustCompile(`^[a-z][a-zA-Z0-9]*$`),
+	"PascalCase": regexp.MustCompile(`^[A-Z][a-zA-Z0-9]*$`),
+}
+
+func checkNamingConventionOptions(options *SdlTag) error {
+	for name, attr := range options.Attributes {
+		if name != "tags" && name != "attributes" && name != "namespaces" {
+			return lintOptionError(options, "Unknown option '%s'. Expected 'tags', 'attributes', or 'namespaces'.", name)
+		}
+		if convention, _ := attr.Value.String(); namingConventions[convention] == nil {
+			return attr.Value.DebugLocation.NewError("Expected the naming convention to be \"snake_case\", \"kebab-case\", \"camelCase\", or \"PascalCase\".")
+		}
+	}
+	return nil
+}
+
+// namingConvention is the convention chosen by the option `name`, or `defaultConvention` if it isn't given.
+func namingConvention(options *SdlTag, name, defaultConvention string) string {
+	if attr, exists := options.Attributes[name]; exists {
+		convention, _ := attr.Value.String()
+		return convention
+	}
+	return defaultConvention
+}
+
+func checkNamingConvention(ctx *LintContext) {
+	tags := namingConvention(ctx.Options, "tags", "snake_case")
+	attributes := namingConvention(ctx.Options, "attributes", "snake_case")
+	namespaces := namingConvention(ctx.Options, "namespaces", "")
+
+	check := func(kind, namespace, name, convention string, loc SdlDebugLocation) {
+		if namespace != "" && namespaces != "" && !namingConventions[namespaces].MatchString(namespace) {
+			ctx.Report(ctx.Location(loc.Offset, loc.Offset+len(namespace)), fmt.Sprintf("The namespace '%s' should be %s.", namespace, namespaces), nil)
+		}
+		if !namingConventions[convention].MatchString(name) {
+			start := loc.Offset
+			if namespace != "" {
+				start += len(namespace) + 1
+			}
+			ctx.Report(ctx.Location(start, start+len(name)), fmt.Sprintf("The %s name '%s' should be %s.", kind, name, convention), nil)
+		}
+	}
+	ctx.forEachTag(func(path *WalkPath, tag *SdlTag) WalkAction {
+		if !ctx.isAnonymous(tag) {
+			check("tag", tag.Namespace, tag.Name, tags, tag.DebugLocation)
+		}
+		return WalkContinue
+	})
+	Walk(ctx.Root, VisitorFuncs{OnAttribute: func(path *WalkPath, attr *SdlAttribute) WalkAction {
+		check("attribute", attr.Namespace, attr.Name, attributes, attr.DebugLocation)
+		return WalkContinue
+	}})
+}
+
+func checkTrailingWhitespace(ctx *LintContext) {
+	for i, token := range ctx.Tokens {
+		atLineEnd := i+1 == len(ctx.Tokens) || ctx.Tokens[i+1].Kind == TokenNewLine
+		if !atLineEnd {
+			continue
+		}
+		start := token.End
+		if token.Kind == TokenWhitespace {
+			start = token.Start
+			if start == 0 && strings.HasPrefix(token.Raw, byteOrderMark) {
+				start += len(byteOrderMark) // The lexer includes the byte order mark in the first whitespace, but it isn't trailing whitespace.
+			}
+		} else if token.Kind == TokenComment && !strings.HasPrefix(token.Raw, "/*") {
+			start = token.Start + len(strings.TrimRight(token.Raw, " \t"))
+		}
+		if start < token.End {
+			ctx.Report(ctx.Location(start, token.End), "Trailing whitespace.", &Fix{Description: "Remove the trailing whitespace", Edits: []TextEdit{{Start: start, End: token.End}}})
+		}
+	}
+}
+
+func checkMaxDepthOptions(options *SdlTag) error {
+	if attr, exists := options.Attributes["max"]; exists {
+		if max, err := attr.Value.Int(); err != nil || max < 1 {
+			return attr.Value.DebugLocation.NewError("Expected the maximum depth to be a positive integer.")
+		}
+	}
+	return nil
+}
+
+func checkMaxDepth(ctx *LintContext) {
+	max := 8
+	if attr, exists := ctx.Options.Attributes["max"]; exists {
+		value, _ := attr.Value.Int()
+		max = int(value)
+	}
+	ctx.forEachTag(func(path *WalkPath, tag *SdlTag) WalkAction {
+		if path.Depth() <= max {
+			return WalkContinue
+		}
+		ctx.Report(ctx.nameLocation(tag), fmt.Sprintf("This tag is nested %d tags deep, which is more than the maximum of %d.", path.Depth(), max), nil)
+		return WalkSkipChildren
+	})
+}
@@ -0,0 +1,101 @@
+package sdlang
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLintDuplicateTag(t *testing.T) {
+	source := "port 80\nport 80\nport 81\nhost \"a\"; host \"a\"\n\"list\"\n\"list\"\nserver {\n\tname \"a\"\n}\nserver {\n\tname \"a\"\n}\n"
+	diagnostics := lintForTest(t, "rules {\n\tduplicate-tag {\n\t\tunique \"port\"\n\t}\n}\n", source)
+	assert.Equal(t, []string{"duplicate-tag@2:1", "duplicate-tag@3:1", "duplicate-tag@4:11", "duplicate-tag@10:1"}, lintRules(diagnostics))
+	assert.Equal(t, "This tag is the same as the one on line 1.", diagnostics[0].Message)
+	assert.Equal(t, "There should only be one 'port' tag, but there's already one on line 1.", diagnostics[1].Message)
+	assert.Nil(t, diagnostics[1].Fix)
+	assert.Nil(t, diagnostics[2].Fix, "tags that share a line with something else can't be removed")
+
+	fixed, _ := ApplyFixes(source, diagnostics)
+	assert.Equal(t, "port 80\nport 81\nhost \"a\"; host \"a\"\n\"list\"\n\"list\"\nserver {\n\tname \"a\"\n}\n", fixed)
+
+	_, err := NewLinter(LintConfig{Rules: map[string]LintRuleConfig{"duplicate-tag": {Options: parseForTest(t, "", "unique 1\n")}}})
+	assert.Error(t, err)
+}
+
+func TestLintMixedQuotes(t *testing.T) {
+	source := "a \"x\" `y` `with\nnewline` \"tick`\"\n"
+	diagnostics := lintForTest(t, "", source)
+	assert.Equal(t, []string{"mixed-quotes@1:7", "mixed-quotes@1:11"}, lintRules(diagnostics))
+	assert.Equal(t, "This string uses backticks, unlike the rest of the document.", diagnostics[0].Message)
+	fixed, _ := ApplyFixes(source, diagnostics)
+	assert.Equal(t, "a \"x\" \"y\" \"with\\nnewline\" \"tick`\"\n", fixed)
+
+	diagnostics = lintForTest(t, "rules {\n\tmixed-quotes style=\"backtick\"\n}\n", source)
+	assert.Equal(t, []string{"mixed-quotes@1:3", "mixed-quotes@2:10"}, lintRules(diagnostics))
+	assert.NotNil(t, diagnostics[0].Fix)
+	assert.Nil(t, diagnostics[1].Fix, "strings containing backticks can't use them as quotes")
+
+	_, err := NewLinter(LintConfig{Rules: map[string]LintRuleConfig{"mixed-quotes": {Options: parseForTest(t, "", "rule style=\"single\"\n").Children[0]}}})
+	assert.Contains(t, err.Error(), "Expected the style")
+}
+
+func TestLintNamespaces(t *testing.T) {
+	source := "app:a db:key=1\nother:b\nc\n"
+	assert.Empty(t, lintForTest(t, "", source), "namespaces aren't checked unless they're listed")
+
+	diagnostics := lintForTest(t, "namespaces \"app\" \"unused\"\n", source)
+	assert.Equal(t, []string{"unknown-namespace@1:7", "unknown-namespace@2:1"}, lintRules(diagnostics))
+	assert.Equal(t, "Unknown namespace 'db'.", diagnostics[0].Message)
+	assert.Equal(t, 8, diagnostics[0].Location.End.Loc)
+
+	diagnostics = lintForTest(t, "namespaces \"app\" \"db\" \"other\" \"unused\"\nrules {\n\tunused-namespace\n}\n", source)
+	assert.Equal(t, 1, len(diagnostics))
+	assert.Equal(t, "The namespace 'unused' isn't used.", diagnostics[0].Message)
+	assert.Equal(t, SdlDebugLocation{File: "test.sdl"}, diagnostics[0].Location)
+
+	source = "xmlns:app \"urn:app\"\nxmlns:unused\napp:a db:key=1 {\n\txmlns:nested\n}\n"
+	diagnostics = lintForTest(t, "rules {\n\tunused-namespace\n}\n", source)
+	assert.Equal(t, []string{"unused-namespace@2:1", "unknown-namespace@3:7", "unknown-namespace@4:2"}, lintRules(diagnostics))
+	assert.Equal(t, "Namespace declarations must be top-level tags.", diagnostics[2].Message)
+
+	diagnostics = lintForTest(t, "", "xmlns:app 1\n")
+	assert.Equal(t, []string{"unknown-namespace@1:1"}, lintRules(diagnostics))
+}
+
+func TestLintNamingConvention(t *testing.T) {
+	source := "snake_case camelCase=1 {\n\tPascalCase\n\tMy_ns:kebab-case\n\t\"anonymous\"\n}\n"
+	assert.Empty(t, lintForTest(t, "", source), "naming conventions are disabled by default")
+
+	diagnostics := lintForTest(t, "rules {\n\tnaming-convention\n}\n", source)
+	assert.Equal(t, []string{"naming-convention@1:12", "naming-convention@2:2", "naming-convention@3:8"}, lintRules(diagnostics))
+	assert.Equal(t, "The attribute name 'camelCase' should be snake_case.", diagnostics[0].Message)
+
+	diagnostics = lintForTest(t, "rules {\n\tnaming-convention tags=\"kebab-case\" attributes=\"camelCase\" namespaces=\"snake_case\"\n}\n", source)
+	assert.Equal(t, []string{"naming-convention@1:1", "naming-convention@2:2", "naming-convention@3:2"}, lintRules(diagnostics))
+	assert.Equal(t, "The namespace 'My_ns' should be snake_case.", diagnostics[2].Message)
+
+	diagnostics = lintForTest(t, "rules {\n\tnaming-convention tags=\"PascalCase\"\n}\n", "Anonymous {\n\t1\n}\n")
+	assert.Empty(t, diagnostics, "anonymous tags don't have a name to check")
+
+	_, err := NewLinter(LintConfig{Rules: map[string]LintRuleConfig{"naming-convention": {Options: parseForTest(t, "", "rule tags=\"UPPER\"\n").Children[0]}}})
+	assert.Contains(t, err.Error(), "Expected the naming convention")
+	_, err = NewLinter(LintConfig{Rules: map[string]LintRuleConfig{"naming-convention": {Options: parseForTest(t, "", "rule values=\"snake_case\"\n").Children[0]}}})
+	assert.Contains(t, err.Error(), "Unknown option 'values'")
+}
+
+func TestLintTrailingWhitespace(t *testing.T) {
+	source := "a 1 \t\n  \nb `x  \ny` # comment  \nc /* block */ \\\n  2 \r\nd"
+	diagnostics := lintForTest(t, "", source)
+	assert.Equal(t, []string{"trailing-whitespace@1:4", "trailing-whitespace@2:1", "trailing-whitespace@4:13", "trailing-whitespace@6:4"}, lintRules(diagnostics))
+	fixed, _ := ApplyFixes(source, diagnostics)
+	assert.Equal(t, "a 1\n\nb `x  \ny` # comment\nc /* block */ \\\n  2\r\nd", fixed)
+}
+
+func TestLintMaxDepth(t *testing.T) {
+	source := "a {\n\tb {\n\t\tc {\n\t\t\td\n\t\t}\n\t\t\"e\"\n\t}\n}\n"
+	assert.Empty(t, lintForTest(t, "", source))
+	diagnostics := lintForTest(t, "rules {\n\tmax-depth max=2\n}\n", source)
+	assert.Equal(t, []string{"max-depth@3:3", "max-depth@6:3"}, lintRules(diagnostics))
+	assert.Equal(t, "This tag is nested 3 tags deep, which is more than the maximum of 2.", diagnostics[0].Message)
+	assert.Equal(t, 5, diagnostics[1].Location.End.Loc, "anonymous tags are reported in full")
+}
@@ -0,0 +1,163 @@
+package sdlang
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func lintForTest(t *testing.T, config, source string) []Diagnostic {
+	t.Helper()
+	lintConfig, err := ParseLintConfig(parseForTest(t, "lint.sdl", config))
+	assert.NoError(t, err)
+	l, err := NewLinter(lintConfig)
+	if !assert.NoError(t, err) {
+		return nil
+	}
+	return l.Lint("test.sdl", source)
+}
+
+// lintRules returns the rule of each diagnostic, followed by its line and column, such as "trailing-whitespace@1:4".
+func lintRules(diagnostics []Diagnostic) []string {
+	rules := []string{}
+	for _, d := range diagnostics {
+		rules = append(rules, d.Rule+"@"+d.Location.position()[len(d.Location.File)+3:])
+	}
+	return rules
+}
+
+func TestParseLintConfig(t *testing.T) {
+	config, err := ParseLintConfig(parseForTest(t, "lint.sdl", "namespaces \"a\" \"b\"\nrules {\n\tmax-depth \"error\" max=2\n\ttrailing-whitespace \"off\"\n\tduplicate-tag\n}\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, config.Namespaces)
+	assert.Equal(t, 3, len(config.Rules))
+	assert.Equal(t, SeverityError, *config.Rules["max-depth"].Severity)
+	max, _ := config.Rules["max-depth"].Options.Attributes["max"].Value.Int()
+	assert.Equal(t, int64(2), max)
+	assert.True(t, config.Rules["trailing-whitespace"].Off)
+	assert.Nil(t, config.Rules["duplicate-tag"].Severity)
+	assert.False(t, config.Rules["duplicate-tag"].Off)
+
+	_, err = ParseLintConfig(parseForTest(t, "lint.sdl", "rules {\n\tmax-depth \"fatal\"\n}\n"))
+	assert.Contains(t, err.Error(), "lint.sdl @ 2")
+	assert.Contains(t, err.Error(), "Expected the severity of the rule")
+	_, err = ParseLintConfig(parseForTest(t, "lint.sdl", "namespaces 1\n"))
+	assert.Contains(t, err.Error(), "Namespaces must be strings.")
+	_, err = ParseLintConfig(parseForTest(t, "lint.sdl", "rule\n"))
+	assert.Contains(t, err.Error(), "Unknown tag 'rule'.")
+
+	fileName := filepath.Join(t.TempDir(), "lint.sdl")
+	assert.NoError(t, os.WriteFile(fileName, []byte("rules {\n\tmax-depth max=3\n}\n"), 0o644))
+	config, err = LoadLintConfig(fileName)
+	assert.NoError(t, err)
+	assert.Contains(t, config.Rules, "max-depth")
+	_, err = LoadLintConfig(filepath.Join(t.TempDir(), "missing.sdl"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestNewLinter(t *testing.T) {
+	config, _ := ParseLintConfig(parseForTest(t, "lint.sdl", "rules {\n\tno-such-rule\n}\n"))
+	_, err := NewLinter(config)
+	assert.Contains(t, err.Error(), "Unknown rule 'no-such-rule'.")
+
+	config, _ = ParseLintConfig(parseForTest(t, "lint.sdl", "rules {\n\tmax-depth max=0\n}\n"))
+	_, err = NewLinter(config)
+	assert.Contains(t, err.Error(), "Expected the maximum depth to be a positive integer.")
+
+	// Extra rules can be given, and configured like the built-in ones.
+	config, _ = ParseLintConfig(parseForTest(t, "lint.sdl", "rules {\n\tno-todo \"note\"\n}\n"))
+	l, err := NewLinter(config, LintRule{Name: "no-todo", Severity: SeverityError, Check: func(ctx *LintContext) {
+		ctx.Root.ForEachChildByName("todo", func(child *SdlTag) {
+			ctx.Report(child.DebugLocation, "Unfinished.", nil)
+		})
+	}})
+	assert.NoError(t, err)
+	diagnostics := l.Lint("test.sdl", "todo\nother\n")
+	assert.Equal(t, 1, len(diagnostics))
+	assert.Equal(t, Diagnostic{
+		Severity: SeverityNote,
+		Rule:     "no-todo",
+		Message:  "Unfinished.",
+		Location: SdlDebugLocation{File: "test.sdl", Line: "todo", LineNumber: 1, End: SdlPosition{Offset: 4, LineNumber: 1, Loc: 4}},
+	}, diagnostics[0])
+}
+
+func TestLint(t *testing.T) {
+	source := "a on \n\tb \"x\" `y`\n"
+	diagnostics := lintForTest(t, "", source)
+	assert.Equal(t, []string{"deprecated-boolean@1:3", "trailing-whitespace@1:5", "mixed-quotes@2:8"}, lintRules(diagnostics))
+	assert.Equal(t, SeverityWarning, diagnostics[0].Severity)
+	assert.Equal(t, "'on' is deprecated. Use 'true' instead.", diagnostics[0].Message)
+	assert.Equal(t, "test.sdl", diagnostics[0].Location.File)
+
+	diagnostics = lintForTest(t, "rules {\n\tdeprecated-boolean \"error\"\n\ttrailing-whitespace \"off\"\n}\n", source)
+	assert.Equal(t, []string{"deprecated-boolean@1:3", "mixed-quotes@2:8"}, lintRules(diagnostics))
+	assert.Equal(t, SeverityError, diagnostics[0].Severity)
+
+	// Documents that can't be parsed only report their syntax errors.
+	diagnostics = lintForTest(t, "", "a on\nb \"unterminated\n")
+	assert.Equal(t, []string{"syntax@2:3"}, lintRules(diagnostics))
+}
+
+func TestLintSuppressions(t *testing.T) {
+	source := `a on # sdl-lint-disable-line
+// sdl-lint-disable-next-line deprecated-boolean, mixed-quotes
+b on ` + "`x`" + `
+c on
+/* sdl-lint-disable deprecated-boolean */
+d on
+e on # sdl-lint-disable-line trailing-whitespace
+-- sdl-lint-enable deprecated-boolean
+f on
+g "x" # sdl-lint-disable-line deprecated-boolean
+`
+	diagnostics := lintForTest(t, "", source)
+	assert.Equal(t, []string{"deprecated-boolean@4:3", "deprecated-boolean@9:3", "mixed-quotes@10:3"}, lintRules(diagnostics))
+}
+
+func TestApplyFixes(t *testing.T) {
+	source := "0123456789"
+	fixed, applied := ApplyFixes(source, []Diagnostic{
+		{Fix: &Fix{Edits: []TextEdit{{Start: 6, End: 8, NewText: "x"}, {Start: 0, End: 1, NewText: "y"}}}},
+		{Fix: &Fix{Edits: []TextEdit{{Start: 2, End: 2, NewText: "z"}}}},
+		{Fix: &Fix{Edits: []TextEdit{{Start: 7, End: 9, NewText: "overlaps"}}}},
+		{Fix: &Fix{Edits: []TextEdit{{Start: 9, End: 11, NewText: "out of range"}}}},
+		{Fix: &Fix{}},
+		{},
+	})
+	assert.Equal(t, "y1z2345x89", fixed)
+	assert.Equal(t, 2, applied)
+}
+
+func TestLinterFix(t *testing.T) {
+	l, err := NewLinter(LintConfig{})
+	assert.NoError(t, err)
+	fixed, diagnostics := l.Fix("test.sdl", "a on off \n\nb `x` \"y\"  \nb `x` \"y\"\nc {\n\td 1\n\td 1\n}\n")
+	assert.Equal(t, "a true false\n\nb `x` `y`\nc {\n\td 1\n}\n", fixed)
+	assert.Empty(t, diagnostics)
+
+	// The byte order mark is kept, even though the lexer includes it in the first whitespace.
+	fixed, diagnostics = l.Fix("test.sdl", byteOrderMark+"  \ntag 1\n")
+	assert.Equal(t, byteOrderMark+"\ntag 1\n", fixed)
+	assert.Empty(t, diagnostics)
+	assert.Empty(t, lintForTest(t, "", byteOrderMark+"\ntag 1\n"))
+}
+
+func TestLintSuppressionsArePerRule(t *testing.T) {
+	// Rules can be enabled in a different order, or separately, from how they were disabled.
+	source := "# sdl-lint-disable deprecated-boolean mixed-quotes\na on `x` \"y\"\n# sdl-lint-enable mixed-quotes deprecated-boolean\nb on `x` \"y\"\n"
+	assert.Equal(t, []string{"deprecated-boolean@4:3", "mixed-quotes@4:10"}, lintRules(lintForTest(t, "", source)))
+
+	source = "# sdl-lint-disable deprecated-boolean mixed-quotes\na on `x` \"y\"\n# sdl-lint-enable mixed-quotes\nb on `x` \"y\"\n"
+	assert.Equal(t, []string{"mixed-quotes@4:10"}, lintRules(lintForTest(t, "", source)))
+
+	// Enabling without naming any rules enables every rule.
+	source = "# sdl-lint-disable deprecated-boolean\na on\n# sdl-lint-enable\nb on\n"
+	assert.Equal(t, []string{"deprecated-boolean@4:3"}, lintRules(lintForTest(t, "", source)))
+
+	// Enabling a rule while every rule is disabled leaves the others disabled.
+	source = "# sdl-lint-disable\na on `x` \"y\"\n# sdl-lint-enable deprecated-boolean\nb on `x` \"y\"\n# sdl-lint-enable\nc `x` \"y\"\n"
+	assert.Equal(t, []string{"deprecated-boolean@4:3", "mixed-quotes@6:7"}, lintRules(lintForTest(t, "", source)))
+}
@@ -0,0 +1,346 @@
+package sdlang
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// MergeStrategy decides how a tag is combined with the tag it overrides.
+type MergeStrategy int
+
+const (
+	// MergeDeep replaces the values (if the override has any), merges the attributes, and recursively merges the children.
+	MergeDeep MergeStrategy = iota
+
+	// MergeReplace replaces the overridden tag entirely.
+	MergeReplace
+
+	// MergeAppendChildren replaces the values (if the override has any), merges the attributes, and appends the override's children.
+	MergeAppendChildren
+
+	// MergeAttributes merges the attributes, but otherwise takes the values and children of the override.
+	MergeAttributes
+)
+
+// MergeRule describes how tags with a certain name are merged.
+type MergeRule struct {
+	Strategy MergeStrategy
+
+	// KeyAttribute, when set, matches tags by the value of this attribute rather than by their position amongst siblings of the same name.
+	KeyAttribute string
+
+	// KeyByValue matches tags by the value at KeyValueIndex rather than by their position amongst siblings of the same name.
+	KeyByValue    bool
+	KeyValueIndex int
+}
+
+// MergeOptions configures Overlay and Merge3.
+type MergeOptions struct {
+	// Rules maps a tag's qualified name onto the rule used to merge it.
+	Rules map[string]MergeRule
+
+	// Default is the rule used for tags that have no entry in Rules.
+	Default MergeRule
+}
+
+// MergeConflict describes a change made on both sides of a three-way merge that could not be reconciled.
+type MergeConflict struct {
+	// Path is the path to the conflicting tag, in the same format as Change.Path.
+	Path string
+
+	// Message describes the conflict.
+	Message string
+
+	// Base, Ours, and Theirs are where the conflicting item is in each document.
+	// If the item doesn't exist in a document, the location of the tag containing it is used instead.
+	Base   SdlDebugLocation
+	Ours   SdlDebugLocation
+	Theirs SdlDebugLocation
+}
+
+func (c MergeConflict) String() string {
+	path := c.Path
+	if path == "" {
+		path = "/"
+	}
+	return fmt.Sprintf("%s: %s (ours %s, theirs %s)", path, c.Message, c.Ours.position(), c.Theirs.position())
+}
+
+func (opts MergeOptions) rule(qualifiedName string) MergeRule {
+	if rule, exists := opts.Rules[qualifiedName]; exists {
+		return rule
+	}
+	return opts.Default
+}
+
+// childKeys computes a key for each child, used to match up children between documents.
+func (opts MergeOptions) childKeys(tag *SdlTag) []string {
+	keys := make([]string, len(tag.Children))
+	occurrences := map[string]int{}
+	for i := range tag.Children {
+		child := &tag.Children[i]
+		rule := opts.rule(child.QualifiedName)
+
+		if rule.KeyAttribute != "" {
+			if attr, exists := child.Attributes[rule.KeyAttribute]; exists {
+				keys[i] = child.QualifiedName + "@" + attr.Value.literal()
+				continue
+			}
+		} else if rule.KeyByValue && rule.KeyValueIndex >= 0 && rule.KeyValueIndex < len(child.Values) {
+			keys[i] = child.QualifiedName + "=" + child.Values[rule.KeyValueIndex].literal()
+			continue
+		}
+
+		keys[i] = child.QualifiedName + "#" + strconv.Itoa(occurrences[child.QualifiedName])
+		occurrences[child.QualifiedName]++
+	}
+	return keys
+}
+
+// Overlay merges each layer on top of the previous one, with later layers taking priority, and returns the result.
+// The layers are usually root tags, such as those returned by ParseIntoAst. None of the layers are modified.
+func Overlay(opts MergeOptions, layers ...SdlTag) SdlTag {
+	if len(layers) == 0 {
+		return SdlTag{}
+	}
+
+	result := layers[0].Clone()
+	for _, layer := range layers[1:] {
+		overlayChildren(&result, &layer, opts)
+		overlayValuesAndAttributes(&result, &layer)
+	}
+	return result
+}
+
+func overlayValuesAndAttributes(base, override *SdlTag) {
+	if len(override.Values) > 0 {
+		base.Values = override.Clone().Values
+	}
+	for key, attr := range override.Attributes {
+		if base.Attributes == nil {
+			base.Attributes = map[string]SdlAttribute{}
+		}
+		attr.Value = attr.Value.Clone()
+		base.Attributes[key] = attr
+	}
+}
+
+func overlayChildren(base, override *SdlTag, opts MergeOptions) {
+	baseKeys := opts.childKeys(base)
+	indexOf := make(map[string]int, len(baseKeys))
+	for i, key := range baseKeys {
+		indexOf[key] = i
+	}
+
+	for i, key := range opts.childKeys(override) {
+		overrideChild := &override.Children[i]
+		j, exists := indexOf[key]
+		if !exists {
+			base.Children = append(base.Children, overrideChild.Clone())
+			continue
+		}
+
+		baseChild := &base.Children[j]
+		switch opts.rule(overrideChild.QualifiedName).Strategy {
+		case MergeReplace:
+			*baseChild = overrideChild.Clone()
+		case MergeAppendChildren:
+			overlayValuesAndAttributes(baseChild, overrideChild)
+			baseChild.Children = append(baseChild.Children, overrideChild.Clone().Children...)
+		case MergeAttributes:
+			attributes := baseChild.Attributes
+			*baseChild = overrideChild.Clone()
+			baseChild.Attributes = attributes
+			overlayValuesAndAttributes(baseChild, &SdlTag{Attributes: overrideChild.Attributes})
+		default:
+			overlayChildren(baseChild, overrideChild, opts)
+			overlayValuesAndAttributes(baseChild, overrideChild)
+		}
+		baseChild.DebugLocation = overrideChild.DebugLocation
+	}
+}
+
+// Merge3 performs a three-way merge of two documents (`ours` and `theirs`) that were both derived from `base`.
+// Changes made on only one side are applied. Changes made on both sides are reported as conflicts,
+// in which case our side is kept. Tags with the MergeReplace strategy are merged as a single unit,
+// otherwise the rules only decide how children are matched up.
+func Merge3(base, ours, theirs SdlTag, opts MergeOptions) (SdlTag, []MergeConflict) {
+	var conflicts []MergeConflict
+	result := merge3Tag(&conflicts, "", &base, &ours, &theirs, opts)
+	return result, conflicts
+}
+
+func merge3Tag(conflicts *[]MergeConflict, path string, base, ours, theirs *SdlTag, opts MergeOptions) SdlTag {
+	result := ours.Clone()
+
+	if !valuesEqual(ours.Values, base.Values) && !valuesEqual(theirs.Values, base.Values) && !valuesEqual(ours.Values, theirs.Values) {
+		*conflicts = append(*conflicts, MergeConflict{
+			Path:    path,
+			Message: "values were changed on both sides",
+			Base:    base.DebugLocation,
+			Ours:    ours.DebugLocation,
+			Theirs:  theirs.DebugLocation,
+		})
+	} else if valuesEqual(ours.Values, base.Values) {
+		result.Values = theirs.Clone().Values
+	}
+
+	merge3Attributes(conflicts, path, base, ours, theirs, &result)
+	result.Children = merge3Children(conflicts, path, base, ours, theirs, opts)
+	return result
+}
+
+func valuesEqual(a, b []SdlValue) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func merge3Attributes(conflicts *[]MergeConflict, path string, base, ours, theirs, result *SdlTag) {
+	var keys []string
+	seen := map[string]bool{}
+	for _, tag := range []*SdlTag{base, ours, theirs} {
+		for key := range tag.Attributes {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		b, inBase := base.Attributes[key]
+		o, inOurs := ours.Attributes[key]
+		t, inTheirs := theirs.Attributes[key]
+		oursChanged := inOurs != inBase || (inOurs && !o.Value.Equal(b.Value))
+		theirsChanged := inTheirs != inBase || (inTheirs && !t.Value.Equal(b.Value))
+		sameChange := inOurs == inTheirs && (!inOurs || o.Value.Equal(t.Value))
+
+		if !theirsChanged || sameChange {
+			continue
+		} else if !oursChanged {
+			if inTheirs {
+				if result.Attributes == nil {
+					result.Attributes = map[string]SdlAttribute{}
+				}
+				t.Value = t.Value.Clone()
+				result.Attributes[key] = t
+			} else {
+				delete(result.Attributes, key)
+			}
+			continue
+		}
+
+		conflict := MergeConflict{
+			Path:    path,
+			Message: "attribute '" + key + "' was changed on both sides",
+			Base:    base.DebugLocation,
+			Ours:    ours.DebugLocation,
+			Theirs:  theirs.DebugLocation,
+		}
+		if inBase {
+			conflict.Base = b.DebugLocation
+		}
+		if inOurs {
+			conflict.Ours = o.DebugLocation
+		}
+		if inTheirs {
+			conflict.Theirs = t.DebugLocation
+		}
+		*conflicts = append(*conflicts, conflict)
+	}
+}
+
+func merge3Children(conflicts *[]MergeConflict, path string, base, ours, theirs *SdlTag, opts MergeOptions) []SdlTag {
+	baseIndex := map[string]int{}
+	for i, key := range opts.childKeys(base) {
+		baseIndex[key] = i
+	}
+	theirsKeys := opts.childKeys(theirs)
+	theirsIndex := map[string]int{}
+	for i, key := range theirsKeys {
+		theirsIndex[key] = i
+	}
+	oursKeys := opts.childKeys(ours)
+	oursIndex := map[string]int{}
+	for i, key := range oursKeys {
+		oursIndex[key] = i
+	}
+
+	var children []SdlTag
+	for i, key := range oursKeys {
+		o := &ours.Children[i]
+		bi, inBase := baseIndex[key]
+		ti, inTheirs := theirsIndex[key]
+		childPath := joinTagPath(path, ours, i)
+
+		if !inTheirs {
+			if inBase && base.Children[bi].Equal(*o) {
+				continue // Deleted by them, unchanged by us.
+			} else if inBase {
+				*conflicts = append(*conflicts, MergeConflict{
+					Path:    childPath,
+					Message: "tag was changed by us but deleted by them",
+					Base:    base.Children[bi].DebugLocation,
+					Ours:    o.DebugLocation,
+					Theirs:  theirs.DebugLocation,
+				})
+			}
+			children = append(children, o.Clone())
+			continue
+		}
+
+		t := &theirs.Children[ti]
+		b := &SdlTag{Namespace: o.Namespace, Name: o.Name, QualifiedName: o.QualifiedName, DebugLocation: base.DebugLocation}
+		if inBase {
+			b = &base.Children[bi]
+		}
+
+		if opts.rule(o.QualifiedName).Strategy != MergeReplace {
+			children = append(children, merge3Tag(conflicts, childPath, b, o, t, opts))
+		} else if o.Equal(*b) {
+			children = append(children, t.Clone())
+		} else {
+			if !t.Equal(*b) && !t.Equal(*o) {
+				*conflicts = append(*conflicts, MergeConflict{
+					Path:    childPath,
+					Message: "tag was replaced on both sides",
+					Base:    b.DebugLocation,
+					Ours:    o.DebugLocation,
+					Theirs:  t.DebugLocation,
+				})
+			}
+			children = append(children, o.Clone())
+		}
+	}
+
+	for i, key := range theirsKeys {
+		if _, inOurs := oursIndex[key]; inOurs {
+			continue
+		}
+
+		t := &theirs.Children[i]
+		bi, inBase := baseIndex[key]
+		if !inBase {
+			children = append(children, t.Clone()) // Added by them.
+		} else if !base.Children[bi].Equal(*t) {
+			*conflicts = append(*conflicts, MergeConflict{
+				Path:    joinTagPath(path, theirs, i),
+				Message: "tag was deleted by us but changed by them",
+				Base:    base.Children[bi].DebugLocation,
+				Ours:    ours.DebugLocation,
+				Theirs:  t.DebugLocation,
+			})
+		}
+	}
+
+	return children
+}
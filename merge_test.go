@@ -0,0 +1,90 @@
+package sdlang
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOverlayDeep(t *testing.T) {
+	base := parseForTest(t, "base.sdl", "server \"a\" port=80 host=\"x\" {\n\tlisten 1\n\tlog \"info\"\n}\nname \"base\"\n")
+	env := parseForTest(t, "env.sdl", "server port=81 {\n\tlog \"debug\"\n}\n")
+	local := parseForTest(t, "local.sdl", "name \"local\"\nextra true\n")
+
+	result := Overlay(MergeOptions{}, base, env, local)
+	expected := parseForTest(t, "", "server \"a\" port=81 host=\"x\" {\n\tlisten 1\n\tlog \"debug\"\n}\nname \"local\"\nextra true\n")
+	assert.True(t, expected.Equal(result), FormatChanges(Diff(&expected, &result)))
+
+	// The layers must not be modified.
+	port, _ := base.Children[0].Attributes["port"].Value.Int()
+	assert.Equal(t, int64(80), port)
+}
+
+func TestOverlayRules(t *testing.T) {
+	base := parseForTest(t, "", "replace 1 {\n\ta\n}\nappend {\n\ta\n}\nattrs 1 a=1 b=2 {\n\ta\n}\n")
+	override := parseForTest(t, "", "replace {\n\tb\n}\nappend {\n\tb\n}\nattrs 2 b=3 {\n\tb\n}\n")
+
+	result := Overlay(MergeOptions{Rules: map[string]MergeRule{
+		"replace": {Strategy: MergeReplace},
+		"append":  {Strategy: MergeAppendChildren},
+		"attrs":   {Strategy: MergeAttributes},
+	}}, base, override)
+	expected := parseForTest(t, "", "replace {\n\tb\n}\nappend {\n\ta\n\tb\n}\nattrs 2 a=1 b=3 {\n\tb\n}\n")
+	assert.True(t, expected.Equal(result), FormatChanges(Diff(&expected, &result)))
+}
+
+func TestOverlayKeyed(t *testing.T) {
+	base := parseForTest(t, "", "user \"alice\" admin=false\nuser \"bob\" admin=false\nhost name=\"a\" port=1\nhost name=\"b\" port=2\n")
+	override := parseForTest(t, "", "user \"bob\" admin=true\nuser \"carol\"\nhost name=\"b\" port=3\n")
+
+	result := Overlay(MergeOptions{Rules: map[string]MergeRule{
+		"user": {KeyByValue: true, KeyValueIndex: 0},
+		"host": {KeyAttribute: "name"},
+	}}, base, override)
+	expected := parseForTest(t, "", "user \"alice\" admin=false\nuser \"bob\" admin=true\nhost name=\"a\" port=1\nhost name=\"b\" port=3\nuser \"carol\"\n")
+	assert.True(t, expected.Equal(result), FormatChanges(Diff(&expected, &result)))
+}
+
+func TestMerge3(t *testing.T) {
+	base := parseForTest(t, "base.sdl", "a 1\nb 2 x=1\nc 3\nd 4\n")
+	ours := parseForTest(t, "ours.sdl", "a 10\nb 2 x=1 y=2\nc 3\nd 4\n")
+	theirs := parseForTest(t, "theirs.sdl", "a 1\nb 20 x=1\nd 4\ne 5\n")
+
+	result, conflicts := Merge3(base, ours, theirs, MergeOptions{})
+	assert.Empty(t, conflicts)
+	expected := parseForTest(t, "", "a 10\nb 20 x=1 y=2\nd 4\ne 5\n")
+	assert.True(t, expected.Equal(result), FormatChanges(Diff(&expected, &result)))
+}
+
+func TestMerge3Conflicts(t *testing.T) {
+	base := parseForTest(t, "base.sdl", "a 1 x=1\nb 2\n")
+	ours := parseForTest(t, "ours.sdl", "a 10 x=2\nb 3\n")
+	theirs := parseForTest(t, "theirs.sdl", "a 20 x=3\n")
+
+	result, conflicts := Merge3(base, ours, theirs, MergeOptions{})
+	assert.Equal(t, 3, len(conflicts))
+
+	assert.Equal(t, "a", conflicts[0].Path)
+	assert.Equal(t, "values were changed on both sides", conflicts[0].Message)
+	assert.Equal(t, "ours.sdl", conflicts[0].Ours.File)
+	assert.Equal(t, "theirs.sdl", conflicts[0].Theirs.File)
+	assert.Equal(t, "attribute 'x' was changed on both sides", conflicts[1].Message)
+	assert.Equal(t, "b", conflicts[2].Path)
+	assert.Equal(t, "tag was changed by us but deleted by them", conflicts[2].Message)
+
+	// Our side is kept when there's a conflict.
+	assert.True(t, ours.Equal(result))
+}
+
+func TestMerge3Replace(t *testing.T) {
+	base := parseForTest(t, "", "a 1 {\n\tb 1\n}\n")
+	ours := parseForTest(t, "", "a 1 {\n\tb 2\n}\n")
+	theirs := parseForTest(t, "", "a 2 {\n\tb 1\n}\n")
+
+	_, conflicts := Merge3(base, ours, theirs, MergeOptions{})
+	assert.Empty(t, conflicts)
+
+	_, conflicts = Merge3(base, ours, theirs, MergeOptions{Rules: map[string]MergeRule{"a": {Strategy: MergeReplace}}})
+	assert.Equal(t, 1, len(conflicts))
+	assert.Equal(t, "tag was replaced on both sides", conflicts[0].Message)
+}
@@ -0,0 +1,394 @@
+package sdlang
+
+import (
+	binenc "encoding/binary" // Aliased, as `binary` is a token type.
+	"fmt"
+	"math"
+	"time"
+)
+
+// MessagePack formats, which are the first byte of each item. See https://github.com/msgpack/msgpack/blob/master/spec.md
+const (
+	msgpackNil      = 0xc0
+	msgpackFalse    = 0xc2
+	msgpackTrue     = 0xc3
+	msgpackBin8     = 0xc4
+	msgpackBin16    = 0xc5
+	msgpackBin32    = 0xc6
+	msgpackExt8     = 0xc7
+	msgpackExt16    = 0xc8
+	msgpackExt32    = 0xc9
+	msgpackFloat32  = 0xca
+	msgpackFloat64  = 0xcb
+	msgpackUint8    = 0xcc
+	msgpackUint16   = 0xcd
+	msgpackUint32   = 0xce
+	msgpackUint64   = 0xcf
+	msgpackInt8     = 0xd0
+	msgpackInt16    = 0xd1
+	msgpackInt32    = 0xd2
+	msgpackInt64    = 0xd3
+	msgpackFixExt1  = 0xd4
+	msgpackFixExt16 = 0xd8
+	msgpackStr8     = 0xd9
+	msgpackStr16    = 0xda
+	msgpackStr32    = 0xdb
+	msgpackArray16  = 0xdc
+	msgpackArray32  = 0xdd
+	msgpackMap16    = 0xde
+	msgpackMap32    = 0xdf
+
+	msgpackFixMap   = 0x80 // Up to 15 entries in the low 4 bits.
+	msgpackFixArray = 0x90 // Up to 15 items in the low 4 bits.
+	msgpackFixStr   = 0xa0 // Up to 31 bytes in the low 5 bits.
+)
+
+// MessagePack extension types used for values without a type of their own.
+const (
+	msgpackExtTimestamp = -1 // Defined by the MessagePack spec.
+	msgpackExtDuration  = 1  // Application specific: nanoseconds as a big endian int64.
+)
+
+// EncodeMessagePack encodes `tag` and its children as MessagePack, in the shape described by encodeTree.
+// Debug locations aren't kept.
+//
+// Datetimes use the timestamp extension type (-1), which doesn't have a time zone, so they're always decoded in UTC.
+// Timespans use extension type 1, containing their nanoseconds as a big endian int64.
+// Everything is written in the smallest possible format, so the output is deterministic.
+func EncodeMessagePack(tag *SdlTag) []byte {
+	e := msgpackEncoder{}
+	encodeTree(&e, tag)
+	return e.out
+}
+
+// DecodeMessagePack decodes MessagePack in the shape produced by EncodeMessagePack. The data is untrusted, so it's fully
+// validated, and `limits` are applied in the same way as when parsing text. Decoding doesn't recurse, so deeply nested
+// data can't overflow the stack.
+func DecodeMessagePack(data []byte, limits ParseOptions) (SdlTag, error) {
+	d := msgpackDecoder{data: data, limits: limits}
+	t := treeDecoding{d: &d, limits: limits}
+	root := t.decodeTree()
+	if d.err == nil && d.pos != len(d.data) {
+		d.fail("unexpected data after the root tag")
+	}
+	if d.err != nil {
+		return SdlTag{}, d.err
+	}
+	return root, nil
+}
+
+type msgpackEncoder struct {
+	out []byte
+}
+
+func (e *msgpackEncoder) writeUint(format byte, value uint64, size int) {
+	e.out = append(e.out, format)
+	for i := size - 1; i >= 0; i-- {
+		e.out = append(e.out, byte(value>>(8*i)))
+	}
+}
+
+// writeLength writes the format and length of a string, binary, array, or map, using the smallest format possible.
+// `fix` is the format that holds the length in its own byte, up to `fixMax`, and 0 if there isn't one.
+func (e *msgpackEncoder) writeLength(length int, fix byte, fixMax int, format8, format16, format32 byte) {
+	switch {
+	case fix != 0 && length <= fixMax:
+		e.out = append(e.out, fix|byte(length))
+	case format8 != 0 && length <= math.MaxUint8:
+		e.writeUint(format8, uint64(length), 1)
+	case length <= math.MaxUint16:
+		e.writeUint(format16, uint64(length), 2)
+	default:
+		e.writeUint(format32, uint64(length), 4)
+	}
+}
+
+func (e *msgpackEncoder) writeMapHeader(n int) {
+	e.writeLength(n, msgpackFixMap, 15, 0, msgpackMap16, msgpackMap32)
+}
+
+func (e *msgpackEncoder) writeArrayHeader(n int) {
+	e.writeLength(n, msgpackFixArray, 15, 0, msgpackArray16, msgpackArray32)
+}
+
+func (e *msgpackEncoder) writeString(s string) {
+	e.writeLength(len(s), msgpackFixStr, 31, msgpackStr8, msgpackStr16, msgpackStr32)
+	e.out = append(e.out, s...)
+}
+
+func (e *msgpackEncoder) writeInt(value int64) {
+	switch {
+	case value >= 0 && value <= 0x7f:
+		e.out = append(e.out, byte(value))
+	case value < 0 && value >= -32:
+		e.out = append(e.out, byte(value))
+	case value >= 0 && value <= math.MaxUint8:
+		e.writeUint(msgpackUint8, uint64(value), 1)
+	case value >= 0 && value <= math.MaxUint16:
+		e.writeUint(msgpackUint16, uint64(value), 2)
+	case value >= 0 && value <= math.MaxUint32:
+		e.writeUint(msgpackUint32, uint64(value), 4)
+	case value >= 0:
+		e.writeUint(msgpackUint64, uint64(value), 8)
+	case value >= math.MinInt8:
+		e.writeUint(msgpackInt8, uint64(value), 1)
+	case value >= math.MinInt16:
+		e.writeUint(msgpackInt16, uint64(value), 2)
+	case value >= math.MinInt32:
+		e.writeUint(msgpackInt32, uint64(value), 4)
+	default:
+		e.writeUint(msgpackInt64, uint64(value), 8)
+	}
+}
+
+func (e *msgpackEncoder) writeExtHeader(extType int8, length int) {
+	switch length {
+	case 1, 2, 4, 8, 16:
+		fixExt := msgpackFixExt1
+		for size := 1; size < length; size *= 2 {
+			fixExt++
+		}
+		e.out = append(e.out, byte(fixExt))
+	default:
+		e.writeLength(length, 0, 0, msgpackExt8, msgpackExt16, msgpackExt32)
+	}
+	e.out = append(e.out, byte(extType))
+}
+
+func (e *msgpackEncoder) writeValue(v SdlValue) {
+	switch v.tag {
+	case tString:
+		e.writeString(v.vString)
+	case tInt:
+		e.writeInt(v.vInt)
+	case tFloat:
+		e.writeUint(msgpackFloat64, math.Float64bits(v.vFloat), 8)
+	case tDateTime:
+		seconds, nanoseconds := v.vDateTime.Unix(), uint64(v.vDateTime.Nanosecond())
+		switch {
+		case seconds >= 0 && seconds <= math.MaxUint32 && nanoseconds == 0:
+			e.writeExtHeader(msgpackExtTimestamp, 4)
+			e.out = append(e.out, byte(seconds>>24), byte(seconds>>16), byte(seconds>>8), byte(seconds))
+		case seconds >= 0 && seconds < 1<<34:
+			e.writeExtHeader(msgpackExtTimestamp, 8)
+			value := nanoseconds<<34 | uint64(seconds)
+			for i := 7; i >= 0; i-- {
+				e.out = append(e.out, byte(value>>(8*i)))
+			}
+		default:
+			e.writeExtHeader(msgpackExtTimestamp, 12)
+			for i := 3; i >= 0; i-- {
+				e.out = append(e.out, byte(nanoseconds>>(8*i)))
+			}
+			for i := 7; i >= 0; i-- {
+				e.out = append(e.out, byte(seconds>>(8*i)))
+			}
+		}
+	case tTimeSpan:
+		e.writeExtHeader(msgpackExtDuration, 8)
+		for i := 7; i >= 0; i-- {
+			e.out = append(e.out, byte(v.vTimeSpan>>(8*i)))
+		}
+	case tBool:
+		if v.vBool {
+			e.out = append(e.out, msgpackTrue)
+		} else {
+			e.out = append(e.out, msgpackFalse)
+		}
+	case tBinary:
+		e.writeLength(len(v.vBinary), 0, 0, msgpackBin8, msgpackBin16, msgpackBin32)
+		e.out = append(e.out, v.vBinary...)
+	default:
+		e.out = append(e.out, msgpackNil)
+	}
+}
+
+// msgpackDecoder reads from `data`. The first problem is kept in `err`, after which every read returns a zero value.
+type msgpackDecoder struct {
+	data   []byte
+	pos    int
+	limits ParseOptions
+	err    error
+}
+
+func (d *msgpackDecoder) fail(msg string) {
+	if d.err == nil {
+		d.err = fmt.Errorf("invalid MessagePack at byte %d: %s", d.pos, msg)
+	}
+}
+
+func (d *msgpackDecoder) failed() bool {
+	return d.err != nil
+}
+
+func (d *msgpackDecoder) take(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(d.data)-d.pos {
+		d.fail("unexpected end of data")
+		return nil
+	}
+	d.pos += n
+	return d.data[d.pos-n : d.pos : d.pos]
+}
+
+// readUint reads a big endian integer of `size` bytes.
+func (d *msgpackDecoder) readUint(size int) uint64 {
+	value := uint64(0)
+	for _, b := range d.take(size) {
+		value = value<<8 | uint64(b)
+	}
+	return value
+}
+
+// readLength reads the length of a string, binary, array, or map, given the formats that it could be in.
+// Every item takes at least one byte, so a length larger than the remaining data must be invalid, which prevents huge allocations.
+func (d *msgpackDecoder) readLength(what string, fix byte, fixMask byte, format8, format16, format32 byte) int {
+	format := d.take(1)
+	if format == nil {
+		return 0
+	}
+
+	length := uint64(0)
+	switch {
+	case fixMask != 0 && format[0]&^fixMask == fix:
+		length = uint64(format[0] & fixMask)
+	case format8 != 0 && format[0] == format8:
+		length = d.readUint(1)
+	case format[0] == format16:
+		length = d.readUint(2)
+	case format[0] == format32:
+		length = d.readUint(4)
+	default:
+		d.pos--
+		d.fail("expected " + what)
+		return 0
+	}
+	if length > uint64(len(d.data)-d.pos) {
+		d.fail("length is larger than the remaining data")
+		return 0
+	}
+	return int(length)
+}
+
+func (d *msgpackDecoder) readMapHeader() int {
+	return d.readLength("a map", msgpackFixMap, 0x0f, 0, msgpackMap16, msgpackMap32)
+}
+
+func (d *msgpackDecoder) readArrayHeader() int {
+	return d.readLength("an array", msgpackFixArray, 0x0f, 0, msgpackArray16, msgpackArray32)
+}
+
+func (d *msgpackDecoder) readBytes(length int) []byte {
+	if d.limits.MaxTokenLength > 0 && length > d.limits.MaxTokenLength {
+		d.fail(fmt.Sprintf("length is more than the maximum allowed %d bytes", d.limits.MaxTokenLength))
+	}
+	return d.take(length)
+}
+
+func (d *msgpackDecoder) readString() string {
+	return string(d.readBytes(d.readLength("a string", msgpackFixStr, 0x1f, msgpackStr8, msgpackStr16, msgpackStr32)))
+}
+
+func (d *msgpackDecoder) readValue() SdlValue {
+	if d.err != nil {
+		return SdlValue{}
+	}
+	if d.pos >= len(d.data) {
+		d.fail("unexpected end of data")
+		return SdlValue{}
+	}
+
+	switch format := d.data[d.pos]; {
+	case format <= 0x7f || format >= 0xe0: // Positive and negative fixint.
+		d.pos++
+		return Int(int64(int8(format)))
+	case format&^0x1f == msgpackFixStr, format == msgpackStr8, format == msgpackStr16, format == msgpackStr32:
+		return String(d.readString())
+	case format == msgpackBin8, format == msgpackBin16, format == msgpackBin32:
+		length := d.readLength("binary", 0, 0, msgpackBin8, msgpackBin16, msgpackBin32)
+		return Binary(append([]byte{}, d.readBytes(length)...))
+	}
+
+	format := d.take(1)[0]
+	switch format {
+	case msgpackNil:
+		return Null()
+	case msgpackFalse:
+		return Bool(false)
+	case msgpackTrue:
+		return Bool(true)
+	case msgpackFloat32:
+		return Float(float64(math.Float32frombits(uint32(d.readUint(4)))))
+	case msgpackFloat64:
+		return Float(math.Float64frombits(d.readUint(8)))
+	case msgpackUint8:
+		return Int(int64(d.readUint(1)))
+	case msgpackUint16:
+		return Int(int64(d.readUint(2)))
+	case msgpackUint32:
+		return Int(int64(d.readUint(4)))
+	case msgpackUint64:
+		value := d.readUint(8)
+		if value > math.MaxInt64 {
+			d.fail("integer is larger than 64-bit signed")
+		}
+		return Int(int64(value))
+	case msgpackInt8:
+		return Int(int64(int8(d.readUint(1))))
+	case msgpackInt16:
+		return Int(int64(int16(d.readUint(2))))
+	case msgpackInt32:
+		return Int(int64(int32(d.readUint(4))))
+	case msgpackInt64:
+		return Int(int64(d.readUint(8)))
+	}
+
+	length := 0
+	switch {
+	case format >= msgpackFixExt1 && format <= msgpackFixExt16:
+		length = 1 << (format - msgpackFixExt1)
+	case format == msgpackExt8:
+		length = int(d.readUint(1))
+	case format == msgpackExt16:
+		length = int(d.readUint(2))
+	case format == msgpackExt32:
+		length = int(d.readUint(4))
+	default:
+		d.pos--
+		d.fail("expected a value")
+		return SdlValue{}
+	}
+	extType := int8(d.readUint(1))
+	data := d.take(length)
+	if d.err != nil {
+		return SdlValue{}
+	}
+	return d.extension(extType, data)
+}
+
+func (d *msgpackDecoder) extension(extType int8, data []byte) SdlValue {
+	switch {
+	case extType == msgpackExtTimestamp && len(data) == 4:
+		return DateTime(time.Unix(int64(binenc.BigEndian.Uint32(data)), 0).UTC())
+	case extType == msgpackExtTimestamp && len(data) == 8:
+		value := binenc.BigEndian.Uint64(data)
+		nanoseconds := value >> 34
+		if nanoseconds >= uint64(time.Second) {
+			d.fail("timestamp has too many nanoseconds")
+		}
+		return DateTime(time.Unix(int64(value&(1<<34-1)), int64(nanoseconds)).UTC())
+	case extType == msgpackExtTimestamp && len(data) == 12:
+		nanoseconds := binenc.BigEndian.Uint32(data)
+		seconds := int64(binenc.BigEndian.Uint64(data[4:]))
+		if nanoseconds >= uint32(time.Second) {
+			d.fail("timestamp has too many nanoseconds")
+		}
+		return DateTime(time.Unix(seconds, int64(nanoseconds)).UTC())
+	case extType == msgpackExtDuration && len(data) == 8:
+		return TimeSpan(time.Duration(binenc.BigEndian.Uint64(data)))
+	}
+	d.fail(fmt.Sprintf("unsupported extension type %d with %d bytes", extType, len(data)))
+	return SdlValue{}
+}
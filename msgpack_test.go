@@ -0,0 +1,107 @@
+package sdlang
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessagePackValues(t *testing.T) {
+	tests := []struct {
+		value   SdlValue
+		encoded []byte
+	}{
+		{Int(0), []byte{0x00}},
+		{Int(127), []byte{0x7f}},
+		{Int(128), []byte{0xcc, 0x80}},
+		{Int(256), []byte{0xcd, 0x01, 0x00}},
+		{Int(65536), []byte{0xce, 0x00, 0x01, 0x00, 0x00}},
+		{Int(1 << 32), []byte{0xcf, 0, 0, 0, 0x01, 0, 0, 0, 0}},
+		{Int(-1), []byte{0xff}},
+		{Int(-32), []byte{0xe0}},
+		{Int(-33), []byte{0xd0, 0xdf}},
+		{Int(-129), []byte{0xd1, 0xff, 0x7f}},
+		{Int(-32769), []byte{0xd2, 0xff, 0xff, 0x7f, 0xff}},
+		{Int(math.MinInt64), []byte{0xd3, 0x80, 0, 0, 0, 0, 0, 0, 0}},
+		{Float(1.5), []byte{0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}},
+		{Null(), []byte{0xc0}},
+		{Bool(false), []byte{0xc2}},
+		{Bool(true), []byte{0xc3}},
+		{String("a"), []byte{0xa1, 'a'}},
+		{String(strings.Repeat("a", 32)), append([]byte{0xd9, 32}, strings.Repeat("a", 32)...)},
+		{String(strings.Repeat("a", 256)), append([]byte{0xda, 0x01, 0x00}, strings.Repeat("a", 256)...)},
+		{Binary([]byte{1, 2}), []byte{0xc4, 0x02, 0x01, 0x02}},
+		{DateTime(time.Unix(1, 0)), []byte{0xd6, 0xff, 0, 0, 0, 0x01}},
+		{DateTime(time.Unix(1, 1)), []byte{0xd7, 0xff, 0, 0, 0, 0x04, 0, 0, 0, 0x01}},
+		{DateTime(time.Unix(-1, 0)), []byte{0xc7, 12, 0xff, 0, 0, 0, 0, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{TimeSpan(time.Second), []byte{0xd7, 0x01, 0, 0, 0, 0, 0x3b, 0x9a, 0xca, 0x00}},
+		{TimeSpan(-time.Nanosecond), []byte{0xd7, 0x01, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+	}
+	for _, test := range tests {
+		e := msgpackEncoder{}
+		e.writeValue(test.value)
+		assert.Equal(t, test.encoded, e.out, test.value.literal())
+
+		d := msgpackDecoder{data: test.encoded}
+		decoded := d.readValue()
+		assert.NoError(t, d.err, test.value.literal())
+		assert.Equal(t, len(test.encoded), d.pos)
+		assert.True(t, test.value.Equal(decoded), "%s decoded as %s", test.value.literal(), decoded.literal())
+	}
+
+	// Datetimes don't keep their time zone.
+	root := NewTag("", "")
+	root.AddValue(DateTime(time.Unix(0, 0).In(time.FixedZone("", 3600))))
+	root, err := DecodeMessagePack(EncodeMessagePack(&root), ParseOptions{})
+	assert.NoError(t, err)
+	value, _ := root.Values[0].DateTime()
+	assert.Equal(t, time.UTC, value.Location())
+}
+
+func TestMessagePackDecodeOnly(t *testing.T) {
+	// Encodings that EncodeMessagePack doesn't produce, but which are accepted.
+	tests := []struct {
+		encoded []byte
+		value   SdlValue
+	}{
+		{[]byte{0xca, 0x3f, 0xc0, 0, 0}, Float(1.5)},
+		{[]byte{0xcc, 0x01}, Int(1)},
+		{[]byte{0xd0, 0x01}, Int(1)},
+		{[]byte{0xdb, 0, 0, 0, 0x01, 'a'}, String("a")},
+		{[]byte{0xc6, 0, 0, 0, 0x01, 0x02}, Binary([]byte{2})},
+		{[]byte{0xc7, 12, 0xff, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x01}, DateTime(time.Unix(1, 0))},
+	}
+	for _, test := range tests {
+		d := msgpackDecoder{data: test.encoded}
+		decoded := d.readValue()
+		assert.NoError(t, d.err, "%x", test.encoded)
+		assert.True(t, test.value.Equal(decoded), "%x decoded as %s", test.encoded, decoded.literal())
+	}
+}
+
+func TestMessagePackInvalidValues(t *testing.T) {
+	tests := []struct {
+		encoded []byte
+		message string
+	}{
+		{[]byte{0xc1}, "expected a value"},
+		{[]byte{0x90}, "expected a value"},
+		{[]byte{0xcf, 0x80, 0, 0, 0, 0, 0, 0, 0}, "integer is larger than 64-bit signed"},
+		{[]byte{0xd4, 0x05, 0x00}, "unsupported extension type 5 with 1 bytes"},
+		{[]byte{0xd6, 0x01, 0, 0, 0, 0}, "unsupported extension type 1 with 4 bytes"},
+		{[]byte{0xd7, 0xff, 0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0}, "timestamp has too many nanoseconds"},
+		{[]byte{0xc7, 12, 0xff, 0x3b, 0x9a, 0xca, 0x00, 0, 0, 0, 0, 0, 0, 0, 0}, "timestamp has too many nanoseconds"},
+		{[]byte{0xd9, 0x05, 'a'}, "length is larger than the remaining data"},
+		{[]byte{0xc9, 0xff, 0xff, 0xff, 0xff, 0x01}, "unexpected end of data"},
+	}
+	for _, test := range tests {
+		d := msgpackDecoder{data: test.encoded}
+		d.readValue()
+		if assert.Error(t, d.err, test.message) {
+			assert.Contains(t, d.err.Error(), test.message)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package sdlang
+
+import (
+	"errors"
+	"strings"
+)
+
+// NewTag creates an empty tag with the given namespace and name.
+// The namespace may be empty.
+func NewTag(namespace, name string) SdlTag {
+	return SdlTag{Namespace: namespace, Name: name, QualifiedName: qualifiedName(namespace, name)}
+}
+
+// NewAttribute creates an attribute with the given namespace, name, and value.
+// The namespace may be empty.
+func NewAttribute(namespace, name string, value SdlValue) SdlAttribute {
+	return SdlAttribute{Namespace: namespace, Name: name, QualifiedName: qualifiedName(namespace, name), Value: value}
+}
+
+// SetName changes the name of this tag, keeping QualifiedName up to date.
+func (t *SdlTag) SetName(name string) *SdlTag {
+	t.Name = name
+	t.QualifiedName = qualifiedName(t.Namespace, t.Name)
+	return t
+}
+
+// SetNamespace changes the namespace of this tag, keeping QualifiedName up to date.
+func (t *SdlTag) SetNamespace(namespace string) *SdlTag {
+	t.Namespace = namespace
+	t.QualifiedName = qualifiedName(t.Namespace, t.Name)
+	return t
+}
+
+// AddValue appends the given values onto this tag.
+func (t *SdlTag) AddValue(values ...SdlValue) *SdlTag {
+	t.Values = append(t.Values, values...)
+	return t
+}
+
+// SetAttr sets the attribute with the given qualified ("namespace:name" or "name") name, overwriting any existing value.
+func (t *SdlTag) SetAttr(qualifiedName string, value SdlValue) *SdlTag {
+	namespace, name := splitQualifiedName(qualifiedName)
+	if t.Attributes == nil {
+		t.Attributes = map[string]SdlAttribute{}
+	}
+
+	attr, exists := t.Attributes[qualifiedName]
+	if !exists {
+		attr = NewAttribute(namespace, name, value)
+	}
+	attr.Value = value
+	t.Attributes[attr.QualifiedName] = attr
+	return t
+}
+
+// RemoveAttr removes the attribute with the given qualified name, returning whether it existed.
+func (t *SdlTag) RemoveAttr(qualifiedName string) bool {
+	if _, exists := t.Attributes[qualifiedName]; !exists {
+		return false
+	}
+	delete(t.Attributes, qualifiedName)
+	return true
+}
+
+// AddChild appends the given children onto this tag.
+func (t *SdlTag) AddChild(children ...SdlTag) *SdlTag {
+	t.Children = append(t.Children, children...)
+	return t
+}
+
+// RemoveChildByName removes the first child that has the specified `name`, returning whether one was removed.
+func (t *SdlTag) RemoveChildByName(name string) bool {
+	for i := 0; i < len(t.Children); i++ {
+		if t.Children[i].Name == name {
+			t.Children = append(t.Children[:i], t.Children[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// RemoveChildrenByName removes every child that has the specified `name`, returning how many were removed.
+func (t *SdlTag) RemoveChildrenByName(name string) int {
+	kept := t.Children[:0]
+	for _, child := range t.Children {
+		if child.Name != name {
+			kept = append(kept, child)
+		}
+	}
+	removed := len(t.Children) - len(kept)
+	for i := len(kept); i < len(t.Children); i++ {
+		t.Children[i] = SdlTag{} // Don't keep removed children alive through the backing array.
+	}
+	t.Children = kept
+	return removed
+}
+
+// InsertChildAt inserts `child` so that it ends up at `index`.
+// `index` may be equal to the amount of children, in which case this acts like AddChild.
+func (t *SdlTag) InsertChildAt(index int, child SdlTag) error {
+	if index < 0 || index > len(t.Children) {
+		return errors.New("child index is out of bounds")
+	}
+	t.Children = append(t.Children, SdlTag{})
+	copy(t.Children[index+1:], t.Children[index:])
+	t.Children[index] = child
+	return nil
+}
+
+// ReplaceChild replaces the child at `index` with `child`.
+func (t *SdlTag) ReplaceChild(index int, child SdlTag) error {
+	if index < 0 || index >= len(t.Children) {
+		return errors.New("child index is out of bounds")
+	}
+	t.Children[index] = child
+	return nil
+}
+
+// SetName changes the name of this attribute, keeping QualifiedName up to date.
+// If the attribute is stored inside of a tag, it must be re-added under its new QualifiedName.
+func (a *SdlAttribute) SetName(name string) *SdlAttribute {
+	a.Name = name
+	a.QualifiedName = qualifiedName(a.Namespace, a.Name)
+	return a
+}
+
+// SetNamespace changes the namespace of this attribute, keeping QualifiedName up to date.
+// If the attribute is stored inside of a tag, it must be re-added under its new QualifiedName.
+func (a *SdlAttribute) SetNamespace(namespace string) *SdlAttribute {
+	a.Namespace = namespace
+	a.QualifiedName = qualifiedName(a.Namespace, a.Name)
+	return a
+}
+
+func qualifiedName(namespace, name string) string {
+	if namespace == "" {
+		return name
+	}
+	return namespace + ":" + name
+}
+
+func splitQualifiedName(qualifiedName string) (string, string) {
+	i := strings.IndexByte(qualifiedName, ':')
+	if i < 0 {
+		return "", qualifiedName
+	}
+	return qualifiedName[:i], qualifiedName[i+1:]
+}
+
+// Clone creates a deep copy of this value, so that modifying one never affects the other.
+func (v SdlValue) Clone() SdlValue {
+	if v.vBinary != nil {
+		v.vBinary = append([]byte{}, v.vBinary...)
+	}
+	return v
+}
+
+// Clone creates a deep copy of this tag, including its children, values, attributes, and binary data.
+func (t SdlTag) Clone() SdlTag {
+	if t.Values != nil {
+		values := make([]SdlValue, len(t.Values))
+		for i, value := range t.Values {
+			values[i] = value.Clone()
+		}
+		t.Values = values
+	}
+
+	if t.Attributes != nil {
+		attributes := make(map[string]SdlAttribute, len(t.Attributes))
+		for key, attr := range t.Attributes {
+			attr.Value = attr.Value.Clone()
+			attributes[key] = attr
+		}
+		t.Attributes = attributes
+	}
+
+	if t.Children != nil {
+		children := make([]SdlTag, len(t.Children))
+		for i, child := range t.Children {
+			children[i] = child.Clone()
+		}
+		t.Children = children
+	}
+
+	return t
+}
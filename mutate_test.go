@@ -0,0 +1,94 @@
+package sdlang
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewTag(t *testing.T) {
+	tag := NewTag("", "person")
+	assert.Equal(t, "person", tag.QualifiedName)
+
+	tag = NewTag("my_namespace", "person")
+	assert.Equal(t, "my_namespace:person", tag.QualifiedName)
+
+	tag.SetNamespace("")
+	assert.Equal(t, "person", tag.QualifiedName)
+
+	tag.SetNamespace("ns").SetName("people")
+	assert.Equal(t, "ns", tag.Namespace)
+	assert.Equal(t, "people", tag.Name)
+	assert.Equal(t, "ns:people", tag.QualifiedName)
+}
+
+func TestBuildTag(t *testing.T) {
+	tag := NewTag("", "person")
+	tag.AddValue(String("Akiko"), String("Johnson")).
+		SetAttr("height", Int(68)).
+		SetAttr("name:first", String("Akiko")).
+		AddChild(NewTag("", "son"), NewTag("", "daughter"))
+
+	assert.Equal(t, 2, len(tag.Values))
+	assert.Equal(t, 2, len(tag.Children))
+
+	attr := tag.Attributes["name:first"]
+	assert.Equal(t, "name", attr.Namespace)
+	assert.Equal(t, "first", attr.Name)
+	s, err := attr.Value.String()
+	assert.NoError(t, err)
+	assert.Equal(t, "Akiko", s)
+
+	tag.SetAttr("height", Int(70))
+	i, err := tag.Attributes["height"].Value.Int()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(70), i)
+
+	assert.True(t, tag.RemoveAttr("height"))
+	assert.False(t, tag.RemoveAttr("height"))
+}
+
+func TestEditChildren(t *testing.T) {
+	tag := NewTag("", "root")
+	tag.AddChild(NewTag("", "a"), NewTag("", "b"), NewTag("", "a"), NewTag("", "c"))
+
+	assert.True(t, tag.RemoveChildByName("b"))
+	assert.False(t, tag.RemoveChildByName("b"))
+	assert.Equal(t, 2, tag.RemoveChildrenByName("a"))
+	assert.Equal(t, 1, len(tag.Children))
+	assert.Equal(t, "c", tag.Children[0].Name)
+
+	assert.NoError(t, tag.InsertChildAt(0, NewTag("", "first")))
+	assert.NoError(t, tag.InsertChildAt(2, NewTag("", "last")))
+	assert.Error(t, tag.InsertChildAt(4, NewTag("", "oob")))
+	assert.Equal(t, "first", tag.Children[0].Name)
+	assert.Equal(t, "c", tag.Children[1].Name)
+	assert.Equal(t, "last", tag.Children[2].Name)
+
+	assert.NoError(t, tag.ReplaceChild(1, NewTag("", "middle")))
+	assert.Error(t, tag.ReplaceChild(3, NewTag("", "oob")))
+	assert.Equal(t, "middle", tag.Children[1].Name)
+}
+
+func TestClone(t *testing.T) {
+	child := NewTag("", "child")
+	child.AddValue(Binary([]byte{1, 2, 3}))
+	tag := NewTag("", "root")
+	tag.SetAttr("a", Int(1)).AddValue(String("v")).AddChild(child)
+
+	clone := tag.Clone()
+	assert.True(t, tag.Equal(clone))
+
+	clone.SetAttr("a", Int(2))
+	clone.Values[0] = String("changed")
+	b, _ := clone.Children[0].Values[0].Binary()
+	b[0] = 255
+
+	i, _ := tag.Attributes["a"].Value.Int()
+	assert.Equal(t, int64(1), i)
+	s, _ := tag.Values[0].String()
+	assert.Equal(t, "v", s)
+	b, _ = tag.Children[0].Values[0].Binary()
+	assert.Equal(t, []byte{1, 2, 3}, b)
+	assert.False(t, tag.Equal(clone))
+}
@@ -0,0 +1,320 @@
+package sdlang
+
+import (
+	"fmt"
+	"strconv"
+	"unicode/utf8"
+)
+
+// NamespaceDeclarationPrefix is the namespace of the tags that declare namespaces within a document.
+// Like XML's `xmlns` attributes, `xmlns:app "https://example.com/app"` declares the prefix `app` and binds it to a URI,
+// while `xmlns:app` declares the prefix without binding it to anything. Declarations must be top-level tags.
+const NamespaceDeclarationPrefix = "xmlns"
+
+const ruleNamespace = "namespace"
+
+// Namespace is a declared namespace prefix.
+type Namespace struct {
+	// Prefix is the namespace as it's written in the document, e.g. `app` in `app:name`.
+	Prefix string
+
+	// URI identifies who owns the namespace. It's empty if the prefix isn't bound to a URI.
+	URI string
+
+	// DebugLocation is the span of the tag that declared the namespace. It's the zero value for namespaces declared with Declare.
+	DebugLocation SdlDebugLocation
+}
+
+// NamespaceRegistry keeps track of which namespace prefixes have been declared, and which URIs they're bound to.
+// The zero value is an empty registry that's ready to use.
+type NamespaceRegistry struct {
+	namespaces []Namespace
+	byPrefix   map[string]int
+}
+
+// Declare declares `prefix`, optionally binding it to `uri`. Declaring a prefix again is allowed as long as it isn't
+// bound to a different URI; if it was previously declared without a URI, it becomes bound to `uri`.
+func (r *NamespaceRegistry) Declare(prefix, uri string) error {
+	return r.declare(Namespace{Prefix: prefix, URI: uri})
+}
+
+func (r *NamespaceRegistry) declare(ns Namespace) error {
+	if prefix := ns.Prefix; !isTextName("", prefix) || prefix == NamespaceDeclarationPrefix {
+		return fmt.Errorf("%q can't be used as a namespace prefix", prefix)
+	}
+	if i, exists := r.byPrefix[ns.Prefix]; exists {
+		existing := &r.namespaces[i]
+		if !urisCompatible(existing.URI, ns.URI) {
+			return fmt.Errorf("the namespace %q is already bound to %q", ns.Prefix, existing.URI)
+		}
+		if existing.URI == "" {
+			existing.URI = ns.URI
+		}
+		return nil
+	}
+	if r.byPrefix == nil {
+		r.byPrefix = map[string]int{}
+	}
+	r.byPrefix[ns.Prefix] = len(r.namespaces)
+	r.namespaces = append(r.namespaces, ns)
+	return nil
+}
+
+// urisCompatible determines whether two declarations of the same prefix agree. A prefix without a URI agrees with any URI.
+func urisCompatible(a, b string) bool {
+	return a == "" || b == "" || a == b
+}
+
+// DeclareFromDocument declares every namespace declared by the top-level declaration tags of `root`
+// (see NamespaceDeclarationPrefix). Declaration tags can have a single string value, which is the URI,
+// and can't have attributes or children.
+//
+// An error pointing at the offending tag is returned if a declaration is malformed, or if it conflicts with an earlier one.
+// Every valid declaration before the error is still declared.
+func (r *NamespaceRegistry) DeclareFromDocument(root *SdlTag) error {
+	for i := range root.Children {
+		tag := &root.Children[i]
+		if tag.Namespace != NamespaceDeclarationPrefix {
+			continue
+		}
+		if len(tag.Values) > 1 || len(tag.Attributes) > 0 || len(tag.Children) > 0 || (len(tag.Values) == 1 && !tag.Values[0].IsString()) {
+			return tag.DebugLocation.newRuleError(ruleNamespace, "Namespace declarations can only have a single string value, which is the namespace's URI.")
+		}
+		ns := Namespace{Prefix: tag.Name, DebugLocation: tag.DebugLocation}
+		if len(tag.Values) == 1 {
+			ns.URI = tag.Values[0].vString
+		}
+
+		if existing, exists := r.Lookup(ns.Prefix); exists && !urisCompatible(existing.URI, ns.URI) {
+			err := &SdlError{
+				Location: tag.DebugLocation,
+				Message:  "The namespace '" + ns.Prefix + "' was already declared with a different URI.",
+				Rule:     ruleNamespace,
+			}
+			if existing.DebugLocation != (SdlDebugLocation{}) {
+				err.Related = append(err.Related, SdlError{Location: existing.DebugLocation, Message: "Previously declared here"})
+			}
+			return err
+		}
+		if err := r.declare(ns); err != nil {
+			return tag.DebugLocation.newRuleError(ruleNamespace, "'"+ns.Prefix+"' can't be used as a namespace prefix.")
+		}
+	}
+	return nil
+}
+
+// Lookup finds the declaration of `prefix`.
+func (r *NamespaceRegistry) Lookup(prefix string) (Namespace, bool) {
+	i, exists := r.byPrefix[prefix]
+	if !exists {
+		return Namespace{}, false
+	}
+	return r.namespaces[i], true
+}
+
+// PrefixOf finds the first prefix that was bound to `uri`.
+func (r *NamespaceRegistry) PrefixOf(uri string) (string, bool) {
+	if uri == "" {
+		return "", false
+	}
+	for _, ns := range r.namespaces {
+		if ns.URI == uri {
+			return ns.Prefix, true
+		}
+	}
+	return "", false
+}
+
+// Namespaces returns every declared namespace, in the order they were first declared.
+func (r *NamespaceRegistry) Namespaces() []Namespace {
+	return append([]Namespace{}, r.namespaces...)
+}
+
+// DeclarationTags returns a declaration tag for each declared namespace, which can be added to a document
+// so that DeclareFromDocument declares the same namespaces.
+func (r *NamespaceRegistry) DeclarationTags() []SdlTag {
+	tags := make([]SdlTag, 0, len(r.namespaces))
+	for _, ns := range r.namespaces {
+		tag := NewTag(NamespaceDeclarationPrefix, ns.Prefix)
+		if ns.URI != "" {
+			tag.AddValue(String(ns.URI))
+		}
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// Validate checks that every namespace used by the tags and attributes of `root` has been declared, returning an
+// error for each use of an undeclared namespace. Declaration tags are expected to be top-level tags, so an error is
+// also returned for any that are nested within other tags.
+func (r *NamespaceRegistry) Validate(root *SdlTag) []error {
+	var errs []error
+	check := func(namespace string, loc SdlDebugLocation) {
+		if namespace != "" {
+			if _, exists := r.byPrefix[namespace]; !exists {
+				errs = append(errs, namespaceLocation(namespace, loc).newRuleError(ruleNamespace, "The namespace '"+namespace+"' hasn't been declared."))
+			}
+		}
+	}
+	Walk(root, VisitorFuncs{
+		OnEnterTag: func(path *WalkPath, tag *SdlTag) WalkAction {
+			if tag.Namespace != NamespaceDeclarationPrefix {
+				check(tag.Namespace, tag.DebugLocation)
+			} else if path.Depth() > 1 {
+				errs = append(errs, tag.DebugLocation.newRuleError(ruleNamespace, "Namespace declarations must be top-level tags."))
+			}
+			return WalkContinue
+		},
+		OnAttribute: func(path *WalkPath, attr *SdlAttribute) WalkAction {
+			check(attr.Namespace, attr.DebugLocation)
+			return WalkContinue
+		},
+	})
+	return errs
+}
+
+// namespaceLocation narrows the location of a tag or attribute down to its namespace, which is always on a single line.
+func namespaceLocation(namespace string, loc SdlDebugLocation) SdlDebugLocation {
+	if loc == (SdlDebugLocation{}) {
+		return loc
+	}
+	loc.End = SdlPosition{
+		Offset:     loc.Offset + len(namespace),
+		LineNumber: loc.LineNumber,
+		Loc:        loc.Loc + utf8.RuneCountInString(namespace),
+	}
+	return loc
+}
+
+// Import declares every namespace of `other` in this registry, and returns how the prefixes used with `other` need
+// to be renamed (see RemapNamespaces) so that they mean the same thing in this registry.
+//
+// A namespace that's bound to a URI this registry already has a prefix for is mapped onto that prefix. A prefix that's
+// already declared with a different URI is renamed by adding a number to it, e.g. `app` becomes `app2`.
+// Prefixes that don't need to change aren't included in the returned map.
+func (r *NamespaceRegistry) Import(other *NamespaceRegistry) map[string]string {
+	mapping := map[string]string{}
+	for _, ns := range other.namespaces {
+		if prefix, exists := r.PrefixOf(ns.URI); exists {
+			if prefix != ns.Prefix {
+				mapping[ns.Prefix] = prefix
+			}
+			continue
+		}
+
+		if existing, exists := r.Lookup(ns.Prefix); exists && !urisCompatible(existing.URI, ns.URI) {
+			for i := 2; ; i++ {
+				prefix := ns.Prefix + strconv.Itoa(i)
+				_, declared := r.byPrefix[prefix]
+				_, used := other.byPrefix[prefix]
+				if !declared && !used {
+					mapping[ns.Prefix] = prefix
+					ns.Prefix = prefix
+					break
+				}
+			}
+		}
+		// Both registries only hold valid declarations, and any conflicts were resolved above.
+		_ = r.declare(ns)
+	}
+	return mapping
+}
+
+// RemapNamespaces renames the namespaces of `root` and its descendants, along with those of their attributes,
+// according to `mapping` (old prefix -> new prefix). Declaration tags are renamed too.
+func RemapNamespaces(root *SdlTag, mapping map[string]string) {
+	if len(mapping) == 0 {
+		return
+	}
+	Walk(root, VisitorFuncs{
+		OnEnterTag: func(path *WalkPath, tag *SdlTag) WalkAction {
+			if prefix, exists := mapping[tag.Namespace]; exists {
+				tag.SetNamespace(prefix)
+			} else if prefix, exists := mapping[tag.Name]; exists && tag.Namespace == NamespaceDeclarationPrefix {
+				tag.SetName(prefix)
+			}
+
+			var renamed []SdlAttribute
+			for key, attr := range tag.Attributes {
+				if prefix, exists := mapping[attr.Namespace]; exists {
+					delete(tag.Attributes, key)
+					renamed = append(renamed, *attr.SetNamespace(prefix))
+				}
+			}
+			for _, attr := range renamed {
+				tag.Attributes[attr.QualifiedName] = attr
+			}
+			return WalkContinue
+		},
+	})
+}
+
+// OverlayWithNamespaces is the same as Overlay, except that the namespaces declared by each layer are taken into
+// account. Layers that use a different prefix for the same URI, or the same prefix for different URIs, are remapped
+// onto the namespaces of the earlier layers (see NamespaceRegistry.Import) before being overlaid.
+// The result starts with a declaration tag for every namespace. None of the layers are modified.
+func OverlayWithNamespaces(opts MergeOptions, layers ...SdlTag) (SdlTag, error) {
+	var registry NamespaceRegistry
+	remapped := make([]SdlTag, len(layers))
+	for i, layer := range layers {
+		var declared NamespaceRegistry
+		if err := declared.DeclareFromDocument(&layer); err != nil {
+			return SdlTag{}, err
+		}
+
+		remapped[i] = layer.Clone()
+		children := remapped[i].Children[:0]
+		for _, child := range remapped[i].Children {
+			if child.Namespace != NamespaceDeclarationPrefix {
+				children = append(children, child)
+			}
+		}
+		remapped[i].Children = children
+		RemapNamespaces(&remapped[i], registry.Import(&declared))
+	}
+
+	result := Overlay(opts, remapped...)
+	result.Children = append(registry.DeclarationTags(), result.Children...)
+	return result, nil
+}
+
+// NamespaceURI finds the URI that the namespace of this tag is bound to within `r`.
+func (t SdlTag) NamespaceURI(r *NamespaceRegistry) (string, bool) {
+	ns, exists := r.Lookup(t.Namespace)
+	if !exists || ns.URI == "" {
+		return "", false
+	}
+	return ns.URI, true
+}
+
+// ForEachChildByURI applies the function `f` onto each child of the tag whose namespace is bound to `uri` within `r`,
+// regardless of which prefix it uses.
+func (t SdlTag) ForEachChildByURI(r *NamespaceRegistry, uri string, f func(child *SdlTag)) {
+	t.ForEachChildFiltered(func(child *SdlTag) bool {
+		childURI, exists := child.NamespaceURI(r)
+		return exists && childURI == uri
+	}, f)
+}
+
+// ChildByURI finds the first child with the given `name` whose namespace is bound to `uri` within `r`.
+func (t SdlTag) ChildByURI(r *NamespaceRegistry, uri, name string) (*SdlTag, bool) {
+	for i := range t.Children {
+		child := &t.Children[i]
+		if childURI, exists := child.NamespaceURI(r); exists && childURI == uri && child.Name == name {
+			return child, true
+		}
+	}
+	return nil, false
+}
+
+// AttributeByURI finds the attribute with the given `name` whose namespace is bound to `uri` within `r`.
+// If several prefixes are bound to `uri`, the attribute whose qualified name sorts first is returned.
+func (t SdlTag) AttributeByURI(r *NamespaceRegistry, uri, name string) (SdlAttribute, bool) {
+	for _, key := range t.sortedAttributeKeys() {
+		attr := t.Attributes[key]
+		if ns, exists := r.Lookup(attr.Namespace); exists && ns.URI == uri && uri != "" && attr.Name == name {
+			return attr, true
+		}
+	}
+	return SdlAttribute{}, false
+}
@@ -0,0 +1,152 @@
+package sdlang
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNamespaceRegistryDeclare(t *testing.T) {
+	var r NamespaceRegistry
+	assert.Nil(t, r.Declare("app", ""))
+	assert.Nil(t, r.Declare("app", "urn:app"), "an unbound prefix can be bound later")
+	assert.Nil(t, r.Declare("app", "urn:app"))
+	assert.Nil(t, r.Declare("db", "urn:db"))
+
+	assert.NotNil(t, r.Declare("app", "urn:other"))
+	assert.NotNil(t, r.Declare("1a", ""))
+	assert.NotNil(t, r.Declare("true", ""))
+	assert.NotNil(t, r.Declare(NamespaceDeclarationPrefix, ""))
+
+	ns, exists := r.Lookup("app")
+	assert.True(t, exists)
+	assert.Equal(t, Namespace{Prefix: "app", URI: "urn:app"}, ns)
+	_, exists = r.Lookup("other")
+	assert.False(t, exists)
+
+	prefix, exists := r.PrefixOf("urn:db")
+	assert.True(t, exists)
+	assert.Equal(t, "db", prefix)
+	_, exists = r.PrefixOf("")
+	assert.False(t, exists)
+
+	assert.Equal(t, []Namespace{{Prefix: "app", URI: "urn:app"}, {Prefix: "db", URI: "urn:db"}}, r.Namespaces())
+}
+
+func TestNamespaceRegistryDeclareFromDocument(t *testing.T) {
+	root := parseForTest(t, "test.sdl", "xmlns:app \"urn:app\"\nxmlns:local\napp:a local:b=1\n")
+	var r NamespaceRegistry
+	assert.Nil(t, r.DeclareFromDocument(&root))
+	ns, _ := r.Lookup("app")
+	assert.Equal(t, "urn:app", ns.URI)
+	assert.Equal(t, 1, ns.DebugLocation.LineNumber)
+	ns, _ = r.Lookup("local")
+	assert.Equal(t, "", ns.URI)
+
+	root = parseForTest(t, "test.sdl", "xmlns:app \"urn:app\"\nxmlns:app \"urn:other\"\n")
+	err := (&NamespaceRegistry{}).DeclareFromDocument(&root)
+	sdlErr := err.(*SdlError)
+	assert.Equal(t, "namespace", sdlErr.Rule)
+	assert.Equal(t, 2, sdlErr.Location.LineNumber)
+	assert.Equal(t, 1, len(sdlErr.Related))
+	assert.Equal(t, 1, sdlErr.Related[0].Location.LineNumber)
+
+	for _, source := range []string{"xmlns:app 1\n", "xmlns:app \"a\" \"b\"\n", "xmlns:app x=1\n", "xmlns:app {\n\ta\n}\n", "xmlns:true\n"} {
+		root = parseForTest(t, "test.sdl", source)
+		assert.NotNil(t, (&NamespaceRegistry{}).DeclareFromDocument(&root), source)
+	}
+}
+
+func TestNamespaceRegistryValidate(t *testing.T) {
+	root := parseForTest(t, "test.sdl", "xmlns:app \"urn:app\"\napp:a db:key=1 {\n\tother:b\n\txmlns:nested\n}\nc\n")
+	var r NamespaceRegistry
+	assert.Nil(t, r.DeclareFromDocument(&root))
+
+	errs := r.Validate(&root)
+	assert.Equal(t, 3, len(errs))
+	assert.Equal(t, "The namespace 'db' hasn't been declared.", errs[0].(*SdlError).Message)
+	assert.Equal(t, SdlDebugLocation{File: "test.sdl", Line: "app:a db:key=1 {", LineNumber: 2, Loc: 6, Offset: 26, End: SdlPosition{Offset: 28, LineNumber: 2, Loc: 8}}, errs[0].(*SdlError).Location)
+	assert.Equal(t, "The namespace 'other' hasn't been declared.", errs[1].(*SdlError).Message)
+	assert.Equal(t, 3, errs[1].(*SdlError).Location.LineNumber)
+	assert.Equal(t, "Namespace declarations must be top-level tags.", errs[2].(*SdlError).Message)
+
+	assert.Nil(t, r.Declare("db", ""))
+	assert.Nil(t, r.Declare("other", ""))
+	assert.Equal(t, 1, len(r.Validate(&root)))
+}
+
+func TestNamespaceRegistryImport(t *testing.T) {
+	var base, other NamespaceRegistry
+	assert.Nil(t, base.Declare("app", "urn:app"))
+	assert.Nil(t, base.Declare("db", "urn:db"))
+	assert.Nil(t, base.Declare("local", ""))
+	assert.Nil(t, other.Declare("a", "urn:app"))
+	assert.Nil(t, other.Declare("db", "urn:database"))
+	assert.Nil(t, other.Declare("db2", "urn:db2"))
+	assert.Nil(t, other.Declare("local", "urn:local"))
+	assert.Nil(t, other.Declare("new", ""))
+
+	mapping := base.Import(&other)
+	assert.Equal(t, map[string]string{"a": "app", "db": "db3"}, mapping)
+	assert.Equal(t, []Namespace{
+		{Prefix: "app", URI: "urn:app"},
+		{Prefix: "db", URI: "urn:db"},
+		{Prefix: "local", URI: "urn:local"},
+		{Prefix: "db3", URI: "urn:database"},
+		{Prefix: "db2", URI: "urn:db2"},
+		{Prefix: "new"},
+	}, base.Namespaces())
+}
+
+func TestRemapNamespaces(t *testing.T) {
+	root := parseForTest(t, "", "xmlns:a \"urn:app\"\na:tag a:x=1 b:y=2 z=3 {\n\tb:child\n}\n")
+	RemapNamespaces(&root, map[string]string{"a": "app", "b": "other"})
+
+	expected := parseForTest(t, "", "xmlns:app \"urn:app\"\napp:tag app:x=1 other:y=2 z=3 {\n\tother:child\n}\n")
+	assert.True(t, expected.Equal(root), FormatChanges(Diff(&expected, &root)))
+}
+
+func TestOverlayWithNamespaces(t *testing.T) {
+	base := parseForTest(t, "base.sdl", "xmlns:app \"urn:app\"\napp:server port=80\n")
+	override := parseForTest(t, "override.sdl", "xmlns:a \"urn:app\"\nxmlns:app \"urn:other\"\na:server port=81\napp:server 1\n")
+
+	result, err := OverlayWithNamespaces(MergeOptions{}, base, override)
+	assert.Nil(t, err)
+	expected := parseForTest(t, "", "xmlns:app \"urn:app\"\nxmlns:app2 \"urn:other\"\napp:server port=81\napp2:server 1\n")
+	assert.True(t, expected.Equal(result), FormatChanges(Diff(&expected, &result)))
+	assert.Equal(t, "a", override.Children[0].Name, "the layers must not be modified")
+
+	override = parseForTest(t, "override.sdl", "xmlns:app 1\n")
+	_, err = OverlayWithNamespaces(MergeOptions{}, base, override)
+	assert.NotNil(t, err)
+}
+
+func TestNamespaceLookups(t *testing.T) {
+	root := parseForTest(t, "", "xmlns:a \"urn:app\"\nxmlns:b \"urn:app\"\nxmlns:local\na:one\nb:two b:x=1 a:x=2\nlocal:three\nfour\n")
+	var r NamespaceRegistry
+	assert.Nil(t, r.DeclareFromDocument(&root))
+
+	var names []string
+	root.ForEachChildByURI(&r, "urn:app", func(child *SdlTag) {
+		names = append(names, child.Name)
+	})
+	assert.Equal(t, []string{"one", "two"}, names)
+
+	child, exists := root.ChildByURI(&r, "urn:app", "two")
+	assert.True(t, exists)
+	assert.Equal(t, "b:two", child.QualifiedName)
+	_, exists = root.ChildByURI(&r, "urn:other", "two")
+	assert.False(t, exists)
+
+	uri, exists := child.NamespaceURI(&r)
+	assert.True(t, exists)
+	assert.Equal(t, "urn:app", uri)
+	_, exists = root.Children[5].NamespaceURI(&r)
+	assert.False(t, exists, "local isn't bound to a URI")
+
+	attr, exists := child.AttributeByURI(&r, "urn:app", "x")
+	assert.True(t, exists)
+	assert.Equal(t, "a:x", attr.QualifiedName)
+	_, exists = child.AttributeByURI(&r, "", "x")
+	assert.False(t, exists)
+}
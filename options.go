@@ -0,0 +1,34 @@
+package sdlang
+
+// ParseOptions limits how much input the parser accepts, to protect against hostile input.
+// Exceeding a limit produces an error pointing at the offending token. A limit of 0 means there is no limit.
+//
+// The parser never recurses, so deeply nested input can't overflow the stack while parsing. However, functions
+// that process a tree recursively (such as Equal, Clone, or Diff) should be given trees with a limited depth.
+type ParseOptions struct {
+	// MaxDepth is how deeply tags can be nested, where a tag at the top of the document has a depth of 1.
+	MaxDepth int
+
+	// MaxTokenLength is the maximum length, in bytes, of a single token such as a string or binary literal.
+	MaxTokenLength int
+
+	// MaxTags is the maximum amount of tags in the entire document.
+	MaxTags int
+
+	// MaxAttributes is the maximum amount of attributes a single tag can have.
+	MaxAttributes int
+
+	// MaxValues is the maximum amount of values a single tag can have.
+	MaxValues int
+}
+
+// DefaultParseOptions returns limits that are suitable for parsing untrusted input.
+func DefaultParseOptions() ParseOptions {
+	return ParseOptions{
+		MaxDepth:       128,
+		MaxTokenLength: 1024 * 1024,
+		MaxTags:        100000,
+		MaxAttributes:  256,
+		MaxValues:      4096,
+	}
+}
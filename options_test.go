@@ -0,0 +1,69 @@
+package sdlang
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLimits(t *testing.T) {
+	for _, test := range []struct {
+		code    string
+		options ParseOptions
+		message string
+		line    int
+	}{
+		{"a {\n\tb {\n\t\tc\n\t}\n}\n", ParseOptions{MaxDepth: 2}, "nested deeper", 3},
+		{"a \"12345\"\n", ParseOptions{MaxTokenLength: 6}, "longer than the maximum", 1},
+		{"a [AAAAAAAA]\n", ParseOptions{MaxTokenLength: 6}, "longer than the maximum", 1},
+		{"a\nb\nc\n", ParseOptions{MaxTags: 2}, "more than the maximum allowed 2 tags", 3},
+		{"a x=1 y=2 z=3\n", ParseOptions{MaxAttributes: 2}, "maximum allowed 2 attributes", 1},
+		{"a 1 2 3\n", ParseOptions{MaxValues: 2}, "maximum allowed 2 values", 1},
+	} {
+		p := SaxParser{Input: test.code, Options: test.options}
+		_, err := p.ParseIntoAst()
+
+		var sdlErr *SdlError
+		if assert.True(t, errors.As(err, &sdlErr), test.code) {
+			assert.Contains(t, sdlErr.Message, test.message)
+			assert.Equal(t, test.line, sdlErr.Location.LineNumber)
+		}
+
+		p = SaxParser{Input: test.code}
+		_, err = p.ParseIntoAst()
+		assert.NoError(t, err)
+	}
+}
+
+func TestDefaultParseOptions(t *testing.T) {
+	p := SaxParser{Input: "a 1 2 x=3 {\n\tb \"hello\"\n}\n", Options: DefaultParseOptions()}
+	_, err := p.ParseIntoAst()
+	assert.NoError(t, err)
+
+	p = SaxParser{Input: strings.Repeat("a {\n", 200), Options: DefaultParseOptions()}
+	_, err = p.ParseIntoAst()
+	assert.Error(t, err)
+}
+
+func TestParseDeepNestingWithoutLimits(t *testing.T) {
+	const depth = 100000
+	p := SaxParser{Input: strings.Repeat("a {\n", depth) + strings.Repeat("}\n", depth)}
+	ast, err := p.ParseIntoAst()
+	assert.NoError(t, err)
+
+	count := 0
+	Walk(&ast, VisitorFuncs{OnEnterTag: func(path *WalkPath, tag *SdlTag) WalkAction {
+		count++
+		return WalkContinue
+	}})
+	assert.Equal(t, depth+1, count)
+}
+
+func TestManyCommentsDontRecurse(t *testing.T) {
+	p := SaxParser{Input: strings.Repeat("# comment\n\\\n", 100000) + "a"}
+	for assert.NoError(t, p.Next()) && !p.IsTagName() {
+	}
+	assert.Equal(t, "a", p.Text())
+}
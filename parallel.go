@@ -0,0 +1,127 @@
+package sdlang
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// ParseFilesOptions configures ParseFiles and ParseFS.
+type ParseFilesOptions struct {
+	// Workers is how many files are parsed at the same time. Defaults to runtime.GOMAXPROCS(0).
+	Workers int
+
+	// Options limits how much input each file may contain.
+	Options ParseOptions
+}
+
+// ParseResult is the outcome of parsing a single file with ParseFiles or ParseFS.
+type ParseResult struct {
+	// FileName is the name the file was given in, which is also used as `SaxParser.FileName`.
+	FileName string
+
+	// Root is the file's AST, or the zero value if Err is set.
+	Root SdlTag
+
+	// Err is why the file couldn't be read or parsed. Files that weren't parsed because the context
+	// was cancelled have the context's error.
+	Err error
+}
+
+// FileErrors contains the error of every file that failed to parse, in the order the files were given in.
+type FileErrors []error
+
+func (e FileErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// ParseFiles reads and parses the files at the given paths concurrently, using up to `opts.Workers` goroutines.
+//
+// There's one result per path, in the same order as the paths. If any file fails then the returned error is a
+// FileErrors, unless the context was cancelled, in which case it's the context's error. Files that are already
+// being parsed when the context is cancelled are finished, but no new ones are started.
+func ParseFiles(ctx context.Context, paths []string, opts ParseFilesOptions) ([]ParseResult, error) {
+	return parseFiles(ctx, paths, os.ReadFile, opts)
+}
+
+// ParseFS is the same as ParseFiles, except the files are read from `fsys`.
+func ParseFS(ctx context.Context, fsys fs.FS, names []string, opts ParseFilesOptions) ([]ParseResult, error) {
+	return parseFiles(ctx, names, func(name string) ([]byte, error) {
+		return fs.ReadFile(fsys, name)
+	}, opts)
+}
+
+func parseFiles(ctx context.Context, names []string, read func(name string) ([]byte, error), opts ParseFilesOptions) ([]ParseResult, error) {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > len(names) {
+		workers = len(names)
+	}
+
+	results := make([]ParseResult, len(names))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if err := ctx.Err(); err != nil {
+					results[i] = ParseResult{FileName: names[i], Err: err}
+					continue
+				}
+				results[i] = parseFile(names[i], read, opts.Options)
+			}
+		}()
+	}
+
+	sent := 0
+send:
+	for ; sent < len(names) && ctx.Err() == nil; sent++ {
+		select {
+		case indexes <- sent:
+		case <-ctx.Done():
+			break send
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	for i := sent; i < len(names); i++ {
+		results[i] = ParseResult{FileName: names[i], Err: ctx.Err()}
+	}
+
+	var errs FileErrors
+	for _, result := range results {
+		if result.Err != nil && result.Err == ctx.Err() {
+			return results, result.Err
+		}
+		if result.Err != nil {
+			errs = append(errs, result.Err)
+		}
+	}
+	if errs != nil {
+		return results, errs
+	}
+	return results, nil
+}
+
+func parseFile(name string, read func(name string) ([]byte, error), limits ParseOptions) ParseResult {
+	input, err := read(name)
+	if err != nil {
+		return ParseResult{FileName: name, Err: err}
+	}
+
+	parser := SaxParser{Input: string(input), FileName: name, Options: limits}
+	root, err := parser.ParseIntoAst()
+	return ParseResult{FileName: name, Root: root, Err: err}
+}
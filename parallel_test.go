@@ -0,0 +1,125 @@
+package sdlang
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFS(t *testing.T) {
+	fsys := fstest.MapFS{}
+	var names []string
+	for i := 0; i < 50; i++ {
+		name := fmt.Sprintf("conf/%02d.sdl", i)
+		fsys[name] = &fstest.MapFile{Data: []byte(fmt.Sprintf("file %d\n", i))}
+		names = append(names, name)
+	}
+
+	for _, workers := range []int{0, 1, 4, 100} {
+		results, err := ParseFS(context.Background(), fsys, names, ParseFilesOptions{Workers: workers})
+		assert.NoError(t, err)
+		assert.Equal(t, len(names), len(results))
+		for i, result := range results {
+			assert.NoError(t, result.Err)
+			assert.Equal(t, names[i], result.FileName)
+			assert.Equal(t, names[i], result.Root.Children[0].DebugLocation.File)
+			value, _ := result.Root.Children[0].Values[0].Int()
+			assert.Equal(t, int64(i), value, "workers: %d", workers)
+		}
+	}
+
+	results, err := ParseFS(context.Background(), fsys, nil, ParseFilesOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, results)
+}
+
+func TestParseFSErrors(t *testing.T) {
+	fsys := fstest.MapFS{
+		"good.sdl":    {Data: []byte("a 1\n")},
+		"syntax.sdl":  {Data: []byte("a \"unterminated\n")},
+		"limited.sdl": {Data: []byte("a 1 2 3\n")},
+	}
+	names := []string{"syntax.sdl", "good.sdl", "missing.sdl", "limited.sdl"}
+
+	results, err := ParseFS(context.Background(), fsys, names, ParseFilesOptions{Options: ParseOptions{MaxValues: 2}})
+	errs, ok := err.(FileErrors)
+	assert.True(t, ok, "%T", err)
+	assert.Equal(t, 3, len(errs))
+	assert.Contains(t, errs[0].Error(), "syntax.sdl")
+	assert.Contains(t, errs[1].Error(), "missing.sdl")
+	assert.Contains(t, errs[2].Error(), "maximum allowed 2 values")
+	assert.Contains(t, err.Error(), "missing.sdl")
+
+	assert.Equal(t, errs[0], results[0].Err)
+	assert.NoError(t, results[1].Err)
+	assert.Equal(t, "a", results[1].Root.Children[0].Name)
+	assert.ErrorIs(t, results[2].Err, fs.ErrNotExist)
+	assert.Equal(t, errs[2], results[3].Err)
+	assert.Equal(t, "limited.sdl", results[3].Err.(*SdlError).Location.File)
+}
+
+// cancellingFS cancels a context once a certain file has been read.
+type cancellingFS struct {
+	files  fstest.MapFS
+	name   string
+	cancel context.CancelFunc
+}
+
+func (c cancellingFS) Open(name string) (fs.File, error) {
+	if name == c.name {
+		c.cancel()
+	}
+	return c.files.Open(name)
+}
+
+func TestParseFSCancel(t *testing.T) {
+	fsys := fstest.MapFS{}
+	var names []string
+	for i := 0; i < 10; i++ {
+		name := fmt.Sprintf("%d.sdl", i)
+		fsys[name] = &fstest.MapFile{Data: []byte("a\n")}
+		names = append(names, name)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	results, err := ParseFS(ctx, cancellingFS{fsys, "2.sdl", cancel}, names, ParseFilesOptions{Workers: 1})
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, len(names), len(results))
+	for i, result := range results {
+		assert.Equal(t, names[i], result.FileName)
+		if i <= 2 {
+			assert.NoError(t, result.Err, names[i])
+		} else {
+			assert.Equal(t, context.Canceled, result.Err, names[i])
+		}
+	}
+
+	results, err = ParseFS(ctx, fsys, names, ParseFilesOptions{})
+	assert.Equal(t, context.Canceled, err)
+	for _, result := range results {
+		assert.Equal(t, context.Canceled, result.Err)
+	}
+}
+
+func TestParseFiles(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.sdl")
+	second := filepath.Join(dir, "second.sdl")
+	assert.NoError(t, os.WriteFile(first, []byte("first\n"), 0o644))
+	assert.NoError(t, os.WriteFile(second, []byte("second 2\n"), 0o644))
+
+	results, err := ParseFiles(context.Background(), []string{second, first}, ParseFilesOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "second", results[0].Root.Children[0].Name)
+	assert.Equal(t, second, results[0].Root.Children[0].DebugLocation.File)
+	assert.Equal(t, "first", results[1].Root.Children[0].Name)
+
+	_, err = ParseFiles(context.Background(), []string{filepath.Join(dir, "missing.sdl")}, ParseFilesOptions{})
+	assert.ErrorIs(t, err.(FileErrors)[0], fs.ErrNotExist)
+}
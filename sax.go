@@ -1,11 +1,15 @@
 package sdlang
 
 import (
+	"encoding/base64"
 	"errors"
+	"math"
 	"strconv"
+	"strings"
 	"time"
-
-	"github.com/BradleyChatha/decorator"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
 )
 
 type saxType int
@@ -31,6 +35,7 @@ const (
 	eof
 	openTag
 	closeTag
+	comment
 )
 
 // SaxParser provides a SAX-style of parsing.
@@ -41,15 +46,44 @@ type SaxParser struct {
 
 	// FileName is used for debug messages.
 	FileName string
-	cursor   int
-	t        saxType
-	text     string
-	addText  string
-	dateTime time.Time
-	timeSpan time.Duration
-	boolean  bool
+
+	// ReportComments makes Next produce a token for each comment (see IsComment), rather than skipping over them.
+	ReportComments bool
+
+	// Options limits how much input is accepted. The zero value has no limits.
+	Options ParseOptions
+
+	cursor     int
+	tokenStart int
+	t          saxType
+	context    saxType // The type of the most recent token that wasn't a comment.
+	text       string
+	addText    string
+	dateTime   time.Time
+	timeSpan   time.Duration
+	boolean    bool
+
+	nameEnd int // Where the most recent tag or attribute name ends.
+	tagEnd  int // Where the most recently started tag ends so far, which is maintained by Parse.
+
+	// pos is the most recently located position. Locations are usually requested in order, so each one is found by
+	// continuing forward from the previous one, rather than by rescanning its line or the input before it.
+	pos       linePosition
+	scanned   int // How many bytes have been scanned to find locations, which tests use to check that it's linear.
+	validated bool
+}
+
+// linePosition is a position within the input, along with the line it's on.
+type linePosition struct {
+	offset    int
+	line      int // Starts from 1. It's 0 if the position hasn't been found yet.
+	lineStart int
+	lineEnd   int // Where the text of the line ends, or -1 if it hasn't been found yet.
+	column    int // The amount of runes between the start of the line and the position.
 }
 
+const byteOrderMark = "\uFEFF"
+
 func (s *SaxParser) IsTagName() bool {
 	return s.t == tagName
 }
@@ -107,9 +141,13 @@ func (s *SaxParser) IsOpenTag() bool {
 func (s *SaxParser) IsCloseTag() bool {
 	return s.t == closeTag
 }
+func (s *SaxParser) IsComment() bool {
+	return s.t == comment
+}
 
 // Text is the parsed text.
 // For tag/attribute names, this is the non-namespace value.
+// For comments, this is the text following the comment marker.
 func (s *SaxParser) Text() string {
 	return s.text
 }
@@ -135,6 +173,67 @@ func (s *SaxParser) Bool() bool {
 	return s.boolean
 }
 
+// Location is the location of the most recently parsed token.
+// This is where the token ends, see TokenLocation for where it starts.
+func (s *SaxParser) Location() SdlDebugLocation {
+	return s.locationAt(s.cursor)
+}
+
+// TokenLocation is the span of the most recently parsed token, from where it starts to where it ends.
+// The span of a tag or attribute name doesn't include any namespace separator or '=' that follows it.
+func (s *SaxParser) TokenLocation() SdlDebugLocation {
+	end := s.cursor
+	if s.t == tagName || s.t == attributeName {
+		end = s.nameEnd
+	}
+	return s.spanAt(s.tokenStart, end)
+}
+
+// Value decodes the most recently parsed literal into an SdlValue.
+// An error is returned if the most recent token isn't a literal.
+func (s *SaxParser) Value() (SdlValue, error) {
+	v := SdlValue{DebugLocation: s.spanAt(s.tokenStart, s.cursor)}
+	switch s.t {
+	case binary:
+		v.tag = tBinary
+		var err error
+		v.vBinary, err = base64.StdEncoding.DecodeString(s.text)
+		if err != nil {
+			v.vBinary, err = base64.RawStdEncoding.DecodeString(s.text)
+		}
+		if err != nil {
+			return SdlValue{}, s.NewError(0, "Invalid base64 in binary literal.")
+		}
+	case boolean:
+		v.tag = tBool
+		v.vBool = s.boolean
+	case date, dateTime:
+		v.tag = tDateTime
+		v.vDateTime = s.dateTime
+	case decimal, double, float:
+		v.tag = tFloat
+		v.vFloat, _ = strconv.ParseFloat(s.text, 64)
+	case integer, long:
+		v.tag = tInt
+		var err error
+		v.vInt, err = strconv.ParseInt(s.text, 10, 64)
+		if err != nil {
+			return SdlValue{}, s.tokenError(ruleSyntax, "Invalid integer.")
+		}
+	case null:
+		v.tag = tNull
+	case string_, character:
+		v.tag = tString
+		v.vString = s.text
+	case timeSpan:
+		v.tag = tTimeSpan
+		v.vTimeSpan = s.timeSpan
+	default:
+		return SdlValue{}, s.NewError(0, "Expected a value.")
+	}
+	return v, nil
+}
+
 func (s *SaxParser) peek(offset int) byte {
 	if s.cursor+offset >= len(s.Input) {
 		return '\u00ff'
@@ -142,6 +241,21 @@ func (s *SaxParser) peek(offset int) byte {
 	return s.Input[s.cursor+offset]
 }
 
+// peekRune decodes the rune at the cursor, returning utf8.RuneError at the end of input.
+func (s *SaxParser) peekRune() (rune, int) {
+	return utf8.DecodeRuneInString(s.Input[s.cursor:])
+}
+
+func (s *SaxParser) skipIdentifier() {
+	for {
+		r, size := s.peekRune()
+		if !isIdentifierContinue(r) {
+			return
+		}
+		s.advance(size)
+	}
+}
+
 func (s *SaxParser) advance(amount int) {
 	s.cursor += amount
 }
@@ -156,41 +270,85 @@ func (s *SaxParser) eatWhite() {
 	}
 }
 
-func (s *SaxParser) getLine(at int) (string, int, int) {
-	start := at
-	end := at
+// seek moves s.pos to the offset `at`, which is clamped to the input.
+func (s *SaxParser) seek(at int) *linePosition {
+	p := &s.pos
+	if p.line == 0 || at < p.lineStart {
+		*p = linePosition{line: 1, lineEnd: -1}
+	} else if at < p.offset {
+		p.offset, p.column = p.lineStart, 0
+	}
+	s.scanned += p.advance(s.Input, at)
+	return p
+}
 
-	if start != 0 && (start >= len(s.Input) || s.Input[start] == '\n') {
-		start--
+// advance moves `p` forward to the offset `at`, which is clamped to the input, and returns how many bytes it scanned.
+func (p *linePosition) advance(input string, at int) int {
+	if at > len(input) {
+		at = len(input)
 	}
-	for start > 0 && s.Input[start] != '\n' {
-		start--
+	scanned := 0
+	if at > p.offset {
+		scanned = at - p.offset
 	}
-	for end < len(s.Input) && s.Input[end] != '\n' && s.Input[end] != '\r' {
-		end++
+	for p.offset < at {
+		i := strings.IndexByte(input[p.offset:at], '\n')
+		if i < 0 {
+			p.column += utf8.RuneCountInString(input[p.offset:at])
+			p.offset = at
+			return scanned
+		}
+		p.offset += i + 1
+		p.line++
+		p.lineStart, p.lineEnd, p.column = p.offset, -1, 0
 	}
+	return scanned
+}
 
-	if s.Input[start] == '\n' {
-		start++
-	}
+// Generates a fancy error message.
+// The returned error is an *SdlError.
+func (s *SaxParser) NewError(offset int, msg string) error {
+	return s.errorAt(s.cursor+offset, msg)
+}
 
-	line := 1
-	for i := 0; i < start; i++ {
-		if s.Input[i] == '\n' {
-			line++
+func (s *SaxParser) errorAt(at int, msg string) *SdlError {
+	return &SdlError{Location: s.locationAt(at), Message: msg, Rule: ruleSyntax}
+}
+
+// tokenError is an error that spans the most recent token.
+func (s *SaxParser) tokenError(rule, msg string) *SdlError {
+	return &SdlError{Location: s.spanAt(s.tokenStart, s.cursor), Message: msg, Rule: rule}
+}
+
+func (s *SaxParser) locationAt(at int) SdlDebugLocation {
+	p := s.seek(at)
+	if p.lineEnd < 0 {
+		p.lineEnd = len(s.Input)
+		if i := strings.IndexByte(s.Input[p.lineStart:], '\n'); i >= 0 {
+			p.lineEnd = p.lineStart + i
+		}
+		s.scanned += p.lineEnd - p.lineStart
+		if p.lineEnd > p.lineStart && s.Input[p.lineEnd-1] == '\r' {
+			p.lineEnd--
 		}
 	}
+	return SdlDebugLocation{File: s.FileName, Line: s.Input[p.lineStart:p.lineEnd], Loc: p.column, LineNumber: p.line, Offset: at}
+}
 
-	return s.Input[start:end], at - start, line
+func (s *SaxParser) positionAt(at int) SdlPosition {
+	p := s.seek(at)
+	return SdlPosition{Offset: at, LineNumber: p.line, Loc: p.column}
 }
 
-// Generates a fancy error message.
-func (s *SaxParser) NewError(offset int, msg string) error {
-	line, loc, ln := s.getLine(s.cursor + offset)
-	var d decorator.Decorator
-	d.AddLine(line, decorator.LineMetadata{FileName: s.FileName, LineNumber: ln})
-	d.AddBottomComment(0, loc, msg)
-	return errors.New(d.String())
+// spanAt is the location of the span between the offsets `start` and `end`.
+// The end is found by continuing from the start, which remains the most recently located position,
+// so that the locations of anything within the span can still be found by moving forward.
+func (s *SaxParser) spanAt(start, end int) SdlDebugLocation {
+	l := s.locationAt(start)
+	p := s.pos
+	s.scanned += p.advance(s.Input, end)
+	l.End = SdlPosition{Offset: end, LineNumber: p.line, Loc: p.column}
+	return l
 }
 
 // Next parses the next token.
@@ -199,52 +357,108 @@ func (s *SaxParser) NewError(offset int, msg string) error {
 // You should keep calling this function until either an error is returned, or `IsEof` returns true.
 // Error messages are already formatted for a user-friendly experience.
 func (s *SaxParser) Next() error {
-	s.eatWhite()
-	if s.eof() {
-		s.t = eof
-		return nil
+	if !s.validated {
+		s.validated = true
+		if strings.HasPrefix(s.Input, byteOrderMark) && s.cursor == 0 {
+			s.advance(len(byteOrderMark))
+		}
+		if at := invalidUTF8(s.Input); at >= 0 {
+			err := s.errorAt(at, "Invalid UTF-8. SDLang documents must be encoded as UTF-8.")
+			err.Rule = ruleEncoding
+			return err
+		}
 	}
 
-	if (s.peek(0) == '/' && s.peek(1) == '/') || (s.peek(0) == '-' && s.peek(1) == '-') || s.peek(0) == '#' {
-		for !s.eof() && s.peek(0) != '\n' {
-			s.advance(1)
-		}
-		return s.Next()
+	err := s.next()
+	if err == nil && s.t != comment {
+		s.context = s.t
 	}
+	if err == nil && s.Options.MaxTokenLength > 0 && s.cursor-s.tokenStart > s.Options.MaxTokenLength {
+		return s.tokenError(ruleLimit, "This token is longer than the maximum allowed length of "+strconv.Itoa(s.Options.MaxTokenLength)+" bytes.")
+	}
+	return err
+}
 
-	if s.peek(0) == '\n' {
-		s.advance(1)
-		s.t = newLine
-		return nil
-	} else if s.peek(0) == '\r' {
-		if s.peek(1) != '\n' {
-			line, _, ln := s.getLine(s.cursor)
-			var d decorator.Decorator
-			d.AddLine(line+" ", decorator.LineMetadata{FileName: s.FileName, LineNumber: ln})
-			d.AddBottomComment(0, len(line), "Stray \\r without a \\n following it.")
-			return errors.New(d.String())
+func (s *SaxParser) next() error {
+	// Comments and line continuations are skipped by looping, rather than recursing, so that any amount of them can be handled.
+	for {
+		s.eatWhite()
+		s.tokenStart = s.cursor
+		if s.eof() {
+			s.t = eof
+			return nil
 		}
-		s.advance(2)
-		s.t = newLine
-		return nil
-	} else if s.peek(0) == '\\' && s.peek(1) == '\n' {
-		s.advance(2)
-		return s.Next()
+
+		if (s.peek(0) == '/' && s.peek(1) == '/') || (s.peek(0) == '-' && s.peek(1) == '-') || s.peek(0) == '#' {
+			if s.peek(0) == '#' {
+				s.advance(1)
+			} else {
+				s.advance(2)
+			}
+			start := s.cursor
+			for !s.eof() && s.peek(0) != '\n' && !(s.peek(0) == '\r' && s.peek(1) == '\n') {
+				s.advance(1)
+			}
+			if !s.ReportComments {
+				continue
+			}
+			s.t = comment
+			s.text = s.Input[start:s.cursor]
+			return nil
+		} else if s.peek(0) == '/' && s.peek(1) == '*' {
+			debugStart := s.cursor
+			s.advance(2)
+			start := s.cursor
+			for !s.eof() && !(s.peek(0) == '*' && s.peek(1) == '/') {
+				s.advance(1)
+			}
+			if s.eof() {
+				err := s.errorAt(debugStart, "Unterminated block comment")
+				err.Related = append(err.Related, *s.errorAt(s.cursor, "Expected a terminating '*/' before hitting end of file"))
+				return err
+			}
+			text := s.Input[start:s.cursor]
+			s.advance(2)
+			if !s.ReportComments {
+				continue
+			}
+			s.t = comment
+			s.text = text
+			return nil
+		}
+
+		if s.peek(0) == '\n' || s.peek(0) == ';' {
+			s.advance(1)
+			s.t = newLine
+			return nil
+		} else if s.peek(0) == '\r' {
+			if s.peek(1) != '\n' {
+				return s.errorAt(s.cursor, "Stray \\r without a \\n following it.")
+			}
+			s.advance(2)
+			s.t = newLine
+			return nil
+		} else if s.peek(0) == '\\' && s.peek(1) == '\n' {
+			s.advance(2)
+			continue
+		} else if s.peek(0) == '\\' && s.peek(1) == '\r' && s.peek(2) == '\n' {
+			s.advance(3)
+			continue
+		}
+
+		break
 	}
 
 	ch := s.peek(0)
-	if isIdentifierStart(ch) {
+	if r, _ := s.peekRune(); isIdentifierStart(r) {
 		err := s.nextIdentifier()
 		if err != nil {
 			return err
 		}
-		if s.t == attributeName {
+		// Anything following a brace is an error, which Parse reports in terms of the brace instead.
+		if s.t == attributeName && s.context != openTag && s.context != closeTag {
 			if s.peek(0) != '=' {
-				line, loc, ln := s.getLine(s.cursor)
-				var d decorator.Decorator
-				d.AddLine(line, decorator.LineMetadata{FileName: s.FileName, LineNumber: ln})
-				d.AddBottomComment(0, loc, "Expected '=' following attribute name")
-				return errors.New(d.String())
+				return s.errorAt(s.cursor, "Expected '=' following attribute name")
 			}
 			s.advance(1)
 		}
@@ -261,9 +475,11 @@ func (s *SaxParser) Next() error {
 		return nil
 	}
 
-	if s.t == newLine || s.t == failsafe {
+	if s.context == newLine || s.context == failsafe {
 		s.t = tagName
 		s.text = "content"
+		s.addText = ""
+		s.nameEnd = s.cursor
 		return nil
 	}
 
@@ -271,30 +487,26 @@ func (s *SaxParser) Next() error {
 		return s.nextDoubleQuotedString()
 	} else if ch == '`' {
 		return s.nextBacktickString()
+	} else if ch == '\'' {
+		return s.nextCharacter()
 	} else if ch == '[' {
 		return s.nextBinary()
 	} else if isDigit(ch) || ch == '-' {
 		return s.nextNumeric()
 	}
 
-	line, loc, ln := s.getLine(s.cursor)
-	var d decorator.Decorator
-	d.AddLine(line, decorator.LineMetadata{FileName: s.FileName, LineNumber: ln})
-	d.AddBottomComment(0, loc, "Unexpected character.")
-	return errors.New(d.String())
+	return s.errorAt(s.cursor, "Unexpected character.")
 }
 
 func (s *SaxParser) nextIdentifier() error {
-	if s.t == newLine || s.t == failsafe {
+	if s.context == newLine || s.context == failsafe {
 		s.t = tagName
 	} else {
 		s.t = attributeName
 	}
 
 	start := s.cursor
-	for isIdentifierContinue(s.peek(0)) {
-		s.advance(1)
-	}
+	s.skipIdentifier()
 	end := s.cursor
 
 	if s.Input[start:end] == "true" || s.Input[start:end] == "on" {
@@ -316,18 +528,22 @@ func (s *SaxParser) nextIdentifier() error {
 	if s.peek(0) != ':' {
 		s.text = s.Input[start:end]
 		s.addText = ""
+		s.nameEnd = end
 		return nil
 	} else {
 		s.addText = s.Input[start:end]
 	}
 	s.advance(1)
 
-	start = s.cursor
-	for isIdentifierContinue(s.peek(0)) {
-		s.advance(1)
+	// The name following a namespace may be a keyword, as in "ns:true", but it must still start like any other name.
+	if r, _ := s.peekRune(); !isIdentifierStart(r) {
+		return s.errorAt(s.cursor, "Expected a name following the namespace's ':'.")
 	}
+	start = s.cursor
+	s.skipIdentifier()
 	end = s.cursor
 	s.text = s.Input[start:end]
+	s.nameEnd = end
 
 	return nil
 }
@@ -337,53 +553,40 @@ func (s *SaxParser) nextDoubleQuotedString() error {
 	s.advance(1)
 	s.t = string_
 
-	text := ""
+	// Strings without escapes are sliced straight out of the input, so the builder is only used once an escape is found.
+	var text strings.Builder
 	start := s.cursor
 	for !s.eof() {
 		if s.peek(0) == '\\' {
-			text += s.Input[start:s.cursor]
+			text.WriteString(s.Input[start:s.cursor])
 
 			s.advance(1)
-			ch := s.peek(0)
-			switch ch {
-			case 'n':
-				text += "\n"
-				s.advance(1)
-			case 't':
-				text += "\t"
-				s.advance(1)
-			case 'r':
-				text += "\r"
-				s.advance(1)
-			case '"':
-				text += "\""
-				s.advance(1)
-			case '\\':
-				text += "\\"
-				s.advance(1)
-			case '\n':
+			if s.peek(0) == '\n' || (s.peek(0) == '\r' && s.peek(1) == '\n') {
+				if s.peek(0) == '\r' {
+					s.advance(1)
+				}
 				s.advance(1)
 				for s.peek(0) == ' ' || s.peek(0) == '\t' {
 					s.advance(1)
 				}
-			default:
-				line, loc, ln := s.getLine(s.cursor)
-				var d decorator.Decorator
-				d.AddLine(line, decorator.LineMetadata{FileName: s.FileName, LineNumber: ln})
-				d.AddBottomComment(0, loc, "Invalid escape character. Only \\t, \\n, \\r, \\\", and \\\\ are allowed.")
-				return errors.New(d.String())
+			} else {
+				escaped, err := s.nextEscape()
+				if err != nil {
+					return err
+				}
+				text.WriteString(escaped)
 			}
 
 			start = s.cursor
 			continue
 		} else if s.peek(0) == '"' {
-			if text == "" {
-				text = s.Input[start:s.cursor]
+			if text.Len() == 0 {
+				s.text = s.Input[start:s.cursor]
 			} else {
-				text += s.Input[start:s.cursor]
+				text.WriteString(s.Input[start:s.cursor])
+				s.text = text.String()
 			}
 			s.advance(1)
-			s.text = text
 			return nil
 		} else if s.peek(0) == '\n' {
 			break
@@ -391,14 +594,9 @@ func (s *SaxParser) nextDoubleQuotedString() error {
 		s.advance(1)
 	}
 
-	line, loc, ln := s.getLine(debugStart)
-	var d decorator.Decorator
-	d.AddLine(line, decorator.LineMetadata{FileName: s.FileName, LineNumber: ln})
-	d.AddBottomComment(0, loc, "Unterminated string")
-	line, loc, ln = s.getLine(s.cursor)
-	d.AddLine(line, decorator.LineMetadata{FileName: s.FileName, LineNumber: ln})
-	d.AddBottomComment(1, loc, "Expected a terminating '\"' before hitting end of file/line")
-	return errors.New(d.String())
+	err := s.errorAt(debugStart, "Unterminated string")
+	err.Related = append(err.Related, *s.errorAt(s.cursor, "Expected a terminating '\"' before hitting end of file/line"))
+	return err
 }
 
 func (s *SaxParser) nextBacktickString() error {
@@ -413,23 +611,105 @@ func (s *SaxParser) nextBacktickString() error {
 			s.advance(1)
 			return nil
 		} else if s.peek(0) == '\r' {
-			line, loc, ln := s.getLine(s.cursor)
-			var d decorator.Decorator
-			d.AddLine(line, decorator.LineMetadata{FileName: s.FileName, LineNumber: ln})
-			d.AddBottomComment(0, loc, "Backtick strings do not support \\r characters")
-			return errors.New(d.String())
+			return s.errorAt(s.cursor, "Backtick strings do not support \\r characters")
 		}
 		s.advance(1)
 	}
 
-	line, loc, ln := s.getLine(debugStart)
-	var d decorator.Decorator
-	d.AddLine(line, decorator.LineMetadata{FileName: s.FileName, LineNumber: ln})
-	d.AddBottomComment(0, loc, "Unterminated string")
-	line, loc, ln = s.getLine(s.cursor)
-	d.AddLine(line, decorator.LineMetadata{FileName: s.FileName, LineNumber: ln})
-	d.AddBottomComment(1, loc, "Expected a terminating '`' before hitting end of file")
-	return errors.New(d.String())
+	err := s.errorAt(debugStart, "Unterminated string")
+	err.Related = append(err.Related, *s.errorAt(s.cursor, "Expected a terminating '`' before hitting end of file"))
+	return err
+}
+
+// nextEscape decodes the escape sequence following a '\\', which the cursor must be past.
+func (s *SaxParser) nextEscape() (string, error) {
+	ch := s.peek(0)
+	s.advance(1)
+	switch ch {
+	case 'n':
+		return "\n", nil
+	case 't':
+		return "\t", nil
+	case 'r':
+		return "\r", nil
+	case 'f':
+		return "\f", nil
+	case 'b':
+		return "\b", nil
+	case '0':
+		return "\x00", nil
+	case '"', '\'', '\\':
+		return string(ch), nil
+	case 'u':
+		r, err := s.nextUnicodeEscape()
+		if err != nil {
+			return "", err
+		}
+		if utf16.IsSurrogate(r) {
+			if r >= 0xDC00 {
+				return "", s.errorAt(s.cursor-6, "Unexpected low surrogate without a preceding high surrogate.")
+			} else if s.peek(0) != '\\' || s.peek(1) != 'u' {
+				return "", s.errorAt(s.cursor, "Expected a \\u escape for the low surrogate following a high surrogate.")
+			}
+			s.advance(2)
+			low, err := s.nextUnicodeEscape()
+			if err != nil {
+				return "", err
+			}
+			r = utf16.DecodeRune(r, low)
+			if r == utf8.RuneError {
+				return "", s.errorAt(s.cursor-6, "Expected a low surrogate following a high surrogate.")
+			}
+		}
+		return string(r), nil
+	}
+
+	s.advance(-1)
+	return "", s.errorAt(s.cursor, "Invalid escape character. Only \\t, \\n, \\r, \\f, \\b, \\0, \\uXXXX, \\', \\\", and \\\\ are allowed.")
+}
+
+// nextUnicodeEscape decodes the 4 hex digits of a \\u escape.
+func (s *SaxParser) nextUnicodeEscape() (rune, error) {
+	if s.cursor+4 > len(s.Input) {
+		return 0, s.errorAt(s.cursor, "Expected exactly 4 hex digits following \\u.")
+	}
+	r, err := strconv.ParseUint(s.Input[s.cursor:s.cursor+4], 16, 32)
+	if err != nil {
+		return 0, s.errorAt(s.cursor, "Expected exactly 4 hex digits following \\u.")
+	}
+	s.advance(4)
+	return rune(r), nil
+}
+
+func (s *SaxParser) nextCharacter() error {
+	debugStart := s.cursor
+	s.advance(1)
+	s.t = character
+
+	start := s.cursor
+	if s.peek(0) == '\\' {
+		s.advance(1)
+		var err error
+		s.text, err = s.nextEscape()
+		if err != nil {
+			return err
+		}
+	} else {
+		_, size := utf8.DecodeRuneInString(s.Input[s.cursor:])
+		if s.eof() || s.peek(0) == '\n' || s.peek(0) == '\'' {
+			return s.errorAt(s.cursor, "Expected a character between the quotes of a character literal.")
+		}
+		s.advance(size)
+		s.text = s.Input[start:s.cursor]
+	}
+
+	if s.peek(0) != '\'' {
+		err := s.errorAt(debugStart, "Unterminated character")
+		err.Related = append(err.Related, *s.errorAt(s.cursor, "Expected a terminating \"'\" after a single character"))
+		return err
+	}
+	s.advance(1)
+	return nil
 }
 
 func (s *SaxParser) nextBinary() error {
@@ -451,22 +731,21 @@ func (s *SaxParser) nextBinary() error {
 		s.advance(1)
 	}
 
-	line, loc, ln := s.getLine(debugStart)
-	var d decorator.Decorator
-	d.AddLine(line, decorator.LineMetadata{FileName: s.FileName, LineNumber: ln})
-	d.AddBottomComment(0, loc, "Unterminated string")
-	line, loc, ln = s.getLine(s.cursor)
-	d.AddLine(line, decorator.LineMetadata{FileName: s.FileName, LineNumber: ln})
-	d.AddBottomComment(1, loc, "Expected a terminating '`' before hitting end of file")
-	return errors.New(d.String())
+	err := s.errorAt(debugStart, "Unterminated binary")
+	err.Related = append(err.Related, *s.errorAt(s.cursor, "Expected a terminating ']' before hitting end of file"))
+	return err
 }
 
 func (s *SaxParser) nextNumeric() error {
-	if s.peek(4) == '/' {
+	if s.peek(4) == '/' && isDigit(s.peek(0)) && isDigit(s.peek(1)) && isDigit(s.peek(2)) && isDigit(s.peek(3)) {
 		if s.peek(13) == ':' && isDigit(s.peek(11)) && isDigit(s.peek(12)) {
 			return s.nextDateTime()
 		}
-		return s.nextDate()
+		err := s.nextDate()
+		if err == nil && !s.eof() && !isNumberTerminator(s.peek(0)) {
+			return s.errorAt(s.cursor, "Expected whitespace or End of line/file after Date.")
+		}
+		return err
 	}
 
 	start := s.cursor
@@ -479,11 +758,7 @@ func (s *SaxParser) nextNumeric() error {
 	for !s.eof() {
 		if s.peek(0) == '.' {
 			if foundDot {
-				line, loc, ln := s.getLine(s.cursor)
-				var d decorator.Decorator
-				d.AddLine(line, decorator.LineMetadata{LineNumber: ln, FileName: s.FileName})
-				d.AddBottomComment(0, loc, "There are multiple decimal places in this number.")
-				return errors.New(d.String())
+				return s.errorAt(s.cursor, "There are multiple decimal places in this number.")
 			}
 			foundDot = true
 		} else if !isDigit(s.peek(0)) {
@@ -493,30 +768,32 @@ func (s *SaxParser) nextNumeric() error {
 	}
 	num := s.Input[start:s.cursor]
 
-	if s.peek(0) == 'd' || s.peek(0) == ':' {
+	if (s.peek(0) == 'd' && s.peek(1) == ':') || s.peek(0) == ':' {
 		return s.nextTimeSpan(num)
 	}
 
 	s.t = integer
-	if s.peek(0) == 'L' {
+	switch {
+	case (s.peek(0) == 'B' && s.peek(1) == 'D') || (s.peek(0) == 'b' && s.peek(1) == 'd'):
+		s.t = decimal
+		s.advance(2)
+	case s.peek(0) == 'L' || s.peek(0) == 'l':
 		s.t = long
 		s.advance(1)
-	} else if s.peek(0) == 'F' {
+	case s.peek(0) == 'F' || s.peek(0) == 'f':
 		s.t = float
 		s.advance(1)
-	} else if s.peek(0) == 'D' {
+	case s.peek(0) == 'D' || s.peek(0) == 'd':
 		s.t = double
 		s.advance(1)
-	} else if foundDot {
+	case foundDot:
 		s.t = double
 	}
 
-	if !s.eof() && s.peek(0) != ' ' && s.peek(0) != '\n' && s.peek(0) != '\r' {
-		line, loc, ln := s.getLine(s.cursor)
-		var d decorator.Decorator
-		d.AddLine(line, decorator.LineMetadata{LineNumber: ln, FileName: s.FileName})
-		d.AddBottomComment(0, loc, "Expected whitespace or End of line/file after number.")
-		return errors.New(d.String())
+	if foundDot && s.t == long {
+		return s.errorAt(s.cursor-1, "Long numbers cannot have a decimal place.")
+	} else if !s.eof() && !isNumberTerminator(s.peek(0)) {
+		return s.errorAt(s.cursor, "Expected whitespace or End of line/file after number.")
 	}
 
 	s.text = num
@@ -528,12 +805,10 @@ func (s *SaxParser) nextTimeSpan(first string) error {
 	isNegative := first[0] == '-'
 	if s.peek(0) == 'd' {
 		days = first
-		if s.peek(1) != ':' {
-			line, loc, ln := s.getLine(s.cursor)
-			var d decorator.Decorator
-			d.AddLine(line, decorator.LineMetadata{LineNumber: ln, FileName: s.FileName})
-			d.AddBottomComment(0, loc, "Expected a : following the days component of a TimeSpan.")
-			return errors.New(d.String())
+		if _, err := strconv.Atoi(days); err != nil {
+			return s.errorAt(s.cursor-len(first), "Invalid number of days in TimeSpan.")
+		} else if s.peek(1) != ':' {
+			return s.errorAt(s.cursor, "Expected a : following the days component of a TimeSpan.")
 		}
 		s.advance(2)
 	} else {
@@ -545,17 +820,14 @@ func (s *SaxParser) nextTimeSpan(first string) error {
 	}
 
 	if s.peek(2) != ':' {
-		line, loc, ln := s.getLine(s.cursor + 2)
-		var d decorator.Decorator
-		d.AddLine(line, decorator.LineMetadata{LineNumber: ln, FileName: s.FileName})
-		d.AddBottomComment(0, loc, "Expected a : following the hours component of a TimeSpan.")
-		return errors.New(d.String())
+		return s.errorAt(s.cursor+2, "Expected a : following the hours component of a TimeSpan.")
 	} else if s.peek(5) != ':' {
-		line, loc, ln := s.getLine(s.cursor + 5)
-		var d decorator.Decorator
-		d.AddLine(line, decorator.LineMetadata{LineNumber: ln, FileName: s.FileName})
-		d.AddBottomComment(0, loc, "Expected a : following the minutes component of a TimeSpan.")
-		return errors.New(d.String())
+		return s.errorAt(s.cursor+5, "Expected a : following the minutes component of a TimeSpan.")
+	}
+	for _, offset := range []int{0, 1, 3, 4, 6, 7} {
+		if !isDigit(s.peek(offset)) {
+			return s.errorAt(s.cursor+offset, "Expected exactly 2 digits for each of the hours, minutes, and seconds of a TimeSpan.")
+		}
 	}
 
 	hasNsecs := s.peek(8) == '.'
@@ -566,11 +838,7 @@ func (s *SaxParser) nextTimeSpan(first string) error {
 
 	if hasNsecs {
 		if !isDigit(s.peek(9)) || !isDigit(s.peek(10)) || !isDigit(s.peek(11)) {
-			line, loc, ln := s.getLine(s.cursor + 9)
-			var d decorator.Decorator
-			d.AddLine(line, decorator.LineMetadata{LineNumber: ln, FileName: s.FileName})
-			d.AddBottomComment(0, loc, "Expected exactly 3 digits for the nsecs portion of a TimeSpan.")
-			return errors.New(d.String())
+			return s.errorAt(s.cursor+9, "Expected exactly 3 digits for the nsecs portion of a TimeSpan.")
 		}
 		nsecs = s.Input[s.cursor+9 : s.cursor+12]
 		s.advance(12)
@@ -579,10 +847,13 @@ func (s *SaxParser) nextTimeSpan(first string) error {
 	}
 
 	daysn, _ := strconv.Atoi(days)
-	hoursn, _ := strconv.Atoi(hours)
-	minsn, _ := strconv.Atoi(minutes)
-	secondsn, _ := strconv.Atoi(seconds)
-	nsecsn, _ := strconv.Atoi(nsecs)
+	hoursn, _ := parseDigits(hours)
+	minsn, _ := parseDigits(minutes)
+	secondsn, _ := parseDigits(seconds)
+	nsecsn, _ := parseDigits(nsecs)
+	if minsn > 59 || secondsn > 59 {
+		return s.tokenError(ruleSyntax, "TimeSpan has an out of range minute or second.")
+	}
 
 	isNegative = isNegative || daysn < 0
 	if isNegative {
@@ -590,11 +861,17 @@ func (s *SaxParser) nextTimeSpan(first string) error {
 	}
 
 	s.t = timeSpan
-	s.timeSpan = (time.Hour * time.Duration(24) * time.Duration(daysn)) +
-		(time.Hour * time.Duration(hoursn)) +
-		(time.Minute * time.Duration(minsn)) +
-		(time.Second * time.Duration(secondsn)) +
-		(time.Millisecond * time.Duration(nsecsn))
+	s.timeSpan = 0
+	parts := []struct {
+		amount int
+		unit   time.Duration
+	}{{daysn, 24 * time.Hour}, {hoursn, time.Hour}, {minsn, time.Minute}, {secondsn, time.Second}, {nsecsn, time.Millisecond}}
+	for _, part := range parts {
+		if part.amount < 0 || part.amount > int(math.MaxInt64/part.unit) || s.timeSpan > math.MaxInt64-time.Duration(part.amount)*part.unit {
+			return s.tokenError(ruleLimit, "TimeSpan is too large.")
+		}
+		s.timeSpan += time.Duration(part.amount) * part.unit
+	}
 	if isNegative {
 		s.timeSpan *= -1
 	}
@@ -604,43 +881,25 @@ func (s *SaxParser) nextTimeSpan(first string) error {
 
 func (s *SaxParser) nextDate() error {
 	if s.cursor+10 > len(s.Input) {
-		line, loc, ln := s.getLine(s.cursor)
-		var d decorator.Decorator
-		d.AddLine(line, decorator.LineMetadata{FileName: s.FileName, LineNumber: ln})
-		d.AddBottomComment(0, loc, "Found what looks like a Date, but there's not enough characters to make a Date.")
-		return errors.New(d.String())
+		return s.errorAt(s.cursor, "Found what looks like a Date, but there's not enough characters to make a Date.")
 	}
 
 	if s.peek(7) != '/' {
-		line, loc, ln := s.getLine(s.cursor + 7)
-		var d decorator.Decorator
-		d.AddLine(line, decorator.LineMetadata{FileName: s.FileName, LineNumber: ln})
-		d.AddBottomComment(0, loc, "Expected a '/'")
-		return errors.New(d.String())
+		return s.errorAt(s.cursor+7, "Expected a '/'")
 	}
 
-	year, yerr := strconv.Atoi(s.Input[s.cursor : s.cursor+4])
-	month, merr := strconv.Atoi(s.Input[s.cursor+5 : s.cursor+7])
-	day, derr := strconv.Atoi(s.Input[s.cursor+8 : s.cursor+10])
+	year, yerr := parseDigits(s.Input[s.cursor : s.cursor+4])
+	month, merr := parseDigits(s.Input[s.cursor+5 : s.cursor+7])
+	day, derr := parseDigits(s.Input[s.cursor+8 : s.cursor+10])
 
 	if yerr != nil || merr != nil || derr != nil {
-		line, loc, ln := s.getLine(s.cursor)
-		var d decorator.Decorator
-		d.AddLine(line, decorator.LineMetadata{FileName: s.FileName, LineNumber: ln})
-
-		if yerr != nil {
-			d.AddBottomComment(0, loc, "Invalid number")
-		}
-		if merr != nil {
-			d.AddBottomComment(0, loc+5, "Invalid number")
-		}
-		if derr != nil {
-			d.AddBottomComment(0, loc+8, "Invalid number")
-		}
-		return errors.New(d.String())
+		return s.invalidNumbersError([]error{yerr, merr, derr}, []int{0, 5, 8})
 	}
 
 	s.dateTime = time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	if month < 1 || month > 12 || s.dateTime.Day() != day {
+		return s.errorAt(s.cursor+5, "Date has an out of range month or day.")
+	}
 	s.t = date
 	s.advance(10)
 	return nil
@@ -653,86 +912,178 @@ func (s *SaxParser) nextDateTime() error {
 	}
 
 	s.eatWhite()
-	if s.cursor+8 > len(s.Input) {
-		line, loc, ln := s.getLine(s.cursor)
-		var d decorator.Decorator
-		d.AddLine(line, decorator.LineMetadata{FileName: s.FileName, LineNumber: ln})
-		d.AddBottomComment(0, loc, "Found what looks like a DateTime, but there's not enough characters to make a DateTime.")
-		return errors.New(d.String())
+	if s.cursor+5 > len(s.Input) {
+		return s.errorAt(s.cursor, "Found what looks like a DateTime, but there's not enough characters to make a DateTime.")
 	}
 
-	if s.peek(5) != ':' {
-		line, loc, ln := s.getLine(s.cursor + 5)
-		var d decorator.Decorator
-		d.AddLine(line, decorator.LineMetadata{FileName: s.FileName, LineNumber: ln})
-		d.AddBottomComment(0, loc, "Expected a ':'.")
-		return errors.New(d.String())
+	if s.peek(2) != ':' {
+		return s.errorAt(s.cursor+2, "Expected a ':'.")
 	}
 
-	hours, herr := strconv.Atoi(s.Input[s.cursor : s.cursor+2])
-	minutes, merr := strconv.Atoi(s.Input[s.cursor+3 : s.cursor+5])
-	seconds, serr := strconv.Atoi(s.Input[s.cursor+6 : s.cursor+8])
-	frac := 0
-
-	if herr != nil || merr != nil || serr != nil {
-		line, loc, ln := s.getLine(s.cursor)
-		var d decorator.Decorator
-		d.AddLine(line, decorator.LineMetadata{FileName: s.FileName, LineNumber: ln})
+	hours, herr := parseDigits(s.Input[s.cursor : s.cursor+2])
+	minutes, merr := parseDigits(s.Input[s.cursor+3 : s.cursor+5])
+	if herr != nil || merr != nil {
+		return s.invalidNumbersError([]error{herr, merr}, []int{0, 3})
+	}
+	s.advance(5)
 
-		if herr != nil {
-			d.AddBottomComment(0, loc, "Invalid number")
-		}
-		if merr != nil {
-			d.AddBottomComment(0, loc+3, "Invalid number")
+	// Seconds are optional, as in "2005/12/05 14:12".
+	seconds := 0
+	if s.peek(0) == ':' {
+		if !isDigit(s.peek(1)) || !isDigit(s.peek(2)) {
+			return s.errorAt(s.cursor+1, "Expected exactly 2 digits for the seconds of a DateTime.")
 		}
-		if serr != nil {
-			d.AddBottomComment(0, loc+6, "Invalid number")
-		}
-		return errors.New(d.String())
+		seconds, _ = parseDigits(s.Input[s.cursor+1 : s.cursor+3])
+		s.advance(3)
 	}
 
-	s.advance(8)
+	// The fractional part is always 3 digits of milliseconds.
+	frac := 0
 	if s.peek(0) == '.' {
-		if s.cursor+4 > len(s.Input) {
-			line, loc, ln := s.getLine(s.cursor)
-			var d decorator.Decorator
-			d.AddLine(line, decorator.LineMetadata{FileName: s.FileName, LineNumber: ln})
-			d.AddBottomComment(0, loc, "Found what looks like the fractional part of a DateTime, but there's not enough characters.")
-			return errors.New(d.String())
+		if !isDigit(s.peek(1)) || !isDigit(s.peek(2)) || !isDigit(s.peek(3)) {
+			return s.errorAt(s.cursor+1, "Expected exactly 3 digits for the milliseconds of a DateTime.")
 		}
-
-		var ferr error
-		frac, ferr = strconv.Atoi(s.Input[s.cursor+1 : s.cursor+4])
+		frac, _ = parseDigits(s.Input[s.cursor+1 : s.cursor+4])
 		s.advance(4)
+	}
 
-		if ferr != nil {
-			line, loc, ln := s.getLine(s.cursor - 3)
-			var d decorator.Decorator
-			d.AddLine(line, decorator.LineMetadata{FileName: s.FileName, LineNumber: ln})
-			d.AddBottomComment(0, loc, "Invalid number")
-			return errors.New(d.String())
+	loc := time.UTC
+	if s.peek(0) == '-' && isASCIILetter(s.peek(1)) {
+		loc, err = s.nextTimeZone()
+		if err != nil {
+			return err
 		}
 	}
 
+	if hours > 23 || minutes > 59 || seconds > 59 {
+		return s.tokenError(ruleSyntax, "DateTime has an out of range hour, minute, or second.")
+	} else if !s.eof() && !isNumberTerminator(s.peek(0)) {
+		return s.errorAt(s.cursor, "Expected whitespace or End of line/file after DateTime.")
+	}
+
 	s.t = dateTime
-	s.dateTime = time.Date(s.dateTime.Year(), s.dateTime.Month(), s.dateTime.Day(), hours, minutes, seconds, frac, time.UTC)
+	s.dateTime = time.Date(s.dateTime.Year(), s.dateTime.Month(), s.dateTime.Day(), hours, minutes, seconds, frac*int(time.Millisecond), loc)
 
 	return nil
 }
 
-func isIdentifierStart(ch byte) bool {
-	return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || ch == '_'
+// timeZoneAbbreviations maps the abbreviations of common time zones onto their offsets from UTC, in minutes.
+// Abbreviations that are ambiguous, such as CST, use the zone that Java's TimeZone uses for them.
+var timeZoneAbbreviations = map[string]int{
+	"HST": -10 * 60, "AKST": -9 * 60, "AKDT": -8 * 60, "PST": -8 * 60, "PDT": -7 * 60, "MST": -7 * 60, "MDT": -6 * 60,
+	"CST": -6 * 60, "CDT": -5 * 60, "EST": -5 * 60, "EDT": -4 * 60, "AST": -4 * 60, "ADT": -3 * 60, "NST": -3*60 - 30,
+	"WET": 0, "WEST": 60, "BST": 60, "CET": 60, "CEST": 2 * 60, "EET": 2 * 60, "EEST": 3 * 60, "MSK": 3 * 60,
+	"IST": 5*60 + 30, "HKT": 8 * 60, "AWST": 8 * 60, "JST": 9 * 60, "KST": 9 * 60, "ACST": 9*60 + 30,
+	"AEST": 10 * 60, "AEDT": 11 * 60, "NZST": 12 * 60, "NZDT": 13 * 60,
+}
+
+// nextTimeZone parses a time zone such as "-UTC", "-JST", or "-GMT+02:00".
+// Offsets are only allowed after UTC and GMT. Other zones are fixed offsets named by their abbreviation.
+func (s *SaxParser) nextTimeZone() (*time.Location, error) {
+	s.advance(1)
+	start := s.cursor
+	for isASCIILetter(s.peek(0)) {
+		s.advance(1)
+	}
+	name := s.Input[start:s.cursor]
+	if offset, exists := timeZoneAbbreviations[name]; exists {
+		return time.FixedZone(name, offset*60), nil
+	} else if name != "UTC" && name != "GMT" {
+		return nil, s.errorAt(start, "Unknown time zone '"+name+"'. Expected UTC or GMT with an optional offset such as GMT+02:00, or an abbreviation such as PST.")
+	}
+	if s.peek(0) != '+' && s.peek(0) != '-' {
+		return time.UTC, nil
+	}
+
+	sign := 1
+	if s.peek(0) == '-' {
+		sign = -1
+	}
+	s.advance(1)
+	if !isDigit(s.peek(0)) || !isDigit(s.peek(1)) {
+		return nil, s.errorAt(s.cursor, "Expected exactly 2 digits for the hours of a time zone offset.")
+	}
+	hours, _ := parseDigits(s.Input[s.cursor : s.cursor+2])
+	s.advance(2)
+	minutes := 0
+	if s.peek(0) == ':' {
+		if !isDigit(s.peek(1)) || !isDigit(s.peek(2)) {
+			return nil, s.errorAt(s.cursor+1, "Expected exactly 2 digits for the minutes of a time zone offset.")
+		}
+		minutes, _ = parseDigits(s.Input[s.cursor+1 : s.cursor+3])
+		s.advance(3)
+	}
+	if hours > 23 || minutes > 59 {
+		return nil, s.errorAt(start, "Time zone offset is out of range.")
+	}
+
+	offset := sign * (hours*60*60 + minutes*60)
+	return time.FixedZone(s.Input[start:s.cursor], offset), nil
+}
+
+// invalidUTF8 returns the byte offset of the first invalid UTF-8 sequence in `input`, or -1 if it is valid.
+func invalidUTF8(input string) int {
+	if utf8.ValidString(input) {
+		return -1
+	}
+	for i, r := range input {
+		if r == utf8.RuneError {
+			if _, size := utf8.DecodeRuneInString(input[i:]); size == 1 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// parseDigits parses a fixed-width field of a date, time, or timespan. Unlike strconv.Atoi, it doesn't allow a sign.
+func parseDigits(text string) (int, error) {
+	n := 0
+	for i := 0; i < len(text); i++ {
+		if !isDigit(text[i]) {
+			return 0, errors.New("expected only digits")
+		}
+		n = n*10 + int(text[i]-'0')
+	}
+	return n, nil
+}
+
+// invalidNumbersError points out every number that failed to parse, where `offsets` are relative to the cursor.
+func (s *SaxParser) invalidNumbersError(errs []error, offsets []int) error {
+	var err *SdlError
+	for i := range errs {
+		if errs[i] == nil {
+			continue
+		} else if err == nil {
+			err = s.errorAt(s.cursor+offsets[i], "Invalid number")
+		} else {
+			err.Related = append(err.Related, *s.errorAt(s.cursor+offsets[i], "Invalid number"))
+		}
+	}
+	return err
+}
+
+func isNumberTerminator(ch byte) bool {
+	return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r' || ch == ';' || ch == '}'
+}
+
+// isIdentifierStart and isIdentifierContinue accept any Unicode letter (and digit for the latter), as the spec allows.
+func isIdentifierStart(ch rune) bool {
+	return unicode.IsLetter(ch) || ch == '_'
+}
+
+func isASCIILetter(ch byte) bool {
+	return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
 }
 
 func isDigit(ch byte) bool {
 	return ch >= '0' && ch <= '9'
 }
 
-func isIdentifierContinue(ch byte) bool {
-	return (ch >= 'a' && ch <= 'z') ||
-		(ch >= 'A' && ch <= 'Z') ||
+func isIdentifierContinue(ch rune) bool {
+	return unicode.IsLetter(ch) ||
+		unicode.IsDigit(ch) ||
 		ch == '_' ||
-		(ch >= '0' && ch <= '9') ||
 		ch == '-' ||
 		ch == '.' ||
 		ch == '$'
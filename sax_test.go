@@ -1,6 +1,8 @@
 package sdlang
 
 import (
+	"errors"
+	"strings"
 	"testing"
 	"time"
 
@@ -27,7 +29,7 @@ func TestNewLine(t *testing.T) {
 }
 
 func TestIdentifier(t *testing.T) {
-	p := SaxParser{Input: "abc one:23="}
+	p := SaxParser{Input: "abc one:a23="}
 	assert.NoError(t, p.Next())
 	assert.True(t, p.IsTagName())
 	assert.Equal(t, "abc", p.Text())
@@ -35,7 +37,21 @@ func TestIdentifier(t *testing.T) {
 	assert.NoError(t, p.Next())
 	assert.True(t, p.IsAttributeName())
 	assert.Equal(t, "one", p.AdditionalText())
-	assert.Equal(t, "23", p.Text())
+	assert.Equal(t, "a23", p.Text())
+}
+
+func TestNamespaceMustBeFollowedByName(t *testing.T) {
+	for _, input := range []string{"A:", "A:0", "a ns:23=1", "ns: 1", "ns:-a"} {
+		_, err := SaxParser{Input: input}.ParseIntoAst()
+		if assert.Error(t, err, input) {
+			assert.Equal(t, "Expected a name following the namespace's ':'.", err.(*SdlError).Message, input)
+		}
+	}
+
+	root, err := SaxParser{Input: "ns:true ns:a-1=1"}.ParseIntoAst()
+	assert.NoError(t, err)
+	assert.Equal(t, "ns:true", root.Children[0].QualifiedName)
+	assert.Contains(t, root.Children[0].Attributes, "ns:a-1")
 }
 
 func TestAttributeMustHaveEquals(t *testing.T) {
@@ -133,6 +149,33 @@ func TestDate(t *testing.T) {
 	p = SaxParser{Input: "t 1aaa/bb/cc"}
 	p.Next()
 	assert.Error(t, p.Next())
+
+	// Each field must only be digits, without a sign.
+	for _, input := range []string{"t 2005/+1/05", "t 2005/01/-5", "t 2005/01/05 12:-3", "t 2005/01/05 12:30:+5"} {
+		p = SaxParser{Input: input}
+		p.Next()
+		assert.Error(t, p.Next(), input)
+	}
+}
+
+func TestTimeZones(t *testing.T) {
+	zones := map[string]int{"UTC": 0, "GMT+02:00": 2 * 60 * 60, "GMT-10": -10 * 60 * 60, "PST": -8 * 60 * 60, "JST": 9 * 60 * 60, "IST": 5*60*60 + 30*60}
+	for zone, offset := range zones {
+		p := SaxParser{Input: "t 2005/12/05 14:12:23-" + zone}
+		p.Next()
+		if assert.NoError(t, p.Next(), zone) {
+			_, actual := p.Time().Zone()
+			assert.Equal(t, offset, actual, zone)
+			assert.Equal(t, 14, p.Time().Hour(), zone)
+		}
+	}
+
+	p := SaxParser{Input: "t 2005/12/05 14:12:23-XYZ"}
+	p.Next()
+	err := p.Next()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.(*SdlError).Message, "Unknown time zone 'XYZ'.")
+	}
 }
 
 func TestDateTime(t *testing.T) {
@@ -141,7 +184,7 @@ func TestDateTime(t *testing.T) {
 
 	assert.NoError(t, p.Next())
 	assert.True(t, p.IsDateTime())
-	assert.Equal(t, time.Date(1111, time.Month(12), 01, 11, 22, 33, 456, time.UTC), p.Time())
+	assert.Equal(t, time.Date(1111, time.Month(12), 01, 11, 22, 33, 456*int(time.Millisecond), time.UTC), p.Time())
 
 	p = SaxParser{Input: "t 1111/11/11 22:bb:cc"}
 	p.Next()
@@ -216,6 +259,27 @@ func TestTimeSpan(t *testing.T) {
 
 	assert.NoError(t, p.Next())
 	assert.True(t, p.IsTimeSpan())
+
+	p = SaxParser{Input: "t 106751d:00:00:00"}
+	p.Next()
+	assert.NoError(t, p.Next())
+	assert.Equal(t, 106751*24*time.Hour, p.TimeSpan())
+
+	// Rather than wrapping around, timespans that don't fit in a time.Duration are an error.
+	for _, input := range []string{"t 106751d:23:59:59", "t -106751d:23:59:59", "t -9223372036854775808d:00:00:00"} {
+		p = SaxParser{Input: input}
+		p.Next()
+		err := p.Next()
+		if assert.Error(t, err, input) {
+			assert.Equal(t, ruleLimit, err.(*SdlError).Rule, input)
+		}
+	}
+
+	for _, input := range []string{"t 00:99:99", "t 00:60:00", "t 1d:00:00:60"} {
+		p = SaxParser{Input: input}
+		p.Next()
+		assert.Error(t, p.Next(), input)
+	}
 }
 
 func TestBoolean(t *testing.T) {
@@ -364,3 +428,168 @@ func TestExamplesCanParse(t *testing.T) {
 		}
 	}
 }
+
+func TestComment(t *testing.T) {
+	p := SaxParser{Input: "# one\n// two\r\n-- three", ReportComments: true}
+
+	assert.NoError(t, p.Next())
+	assert.True(t, p.IsComment())
+	assert.Equal(t, " one", p.Text())
+	assert.NoError(t, p.Next())
+	assert.True(t, p.IsNewLine())
+
+	assert.NoError(t, p.Next())
+	assert.True(t, p.IsComment())
+	assert.Equal(t, " two", p.Text())
+	assert.NoError(t, p.Next())
+	assert.True(t, p.IsNewLine())
+
+	assert.NoError(t, p.Next())
+	assert.True(t, p.IsComment())
+	assert.Equal(t, " three", p.Text())
+	assert.NoError(t, p.Next())
+	assert.True(t, p.IsEof())
+}
+
+func TestBlockComment(t *testing.T) {
+	p := SaxParser{Input: "/* one\ntwo */ tag", ReportComments: true}
+
+	assert.NoError(t, p.Next())
+	assert.True(t, p.IsComment())
+	assert.Equal(t, " one\ntwo ", p.Text())
+	assert.NoError(t, p.Next())
+	assert.True(t, p.IsTagName())
+	assert.Equal(t, "tag", p.Text())
+
+	p = SaxParser{Input: "/* unterminated"}
+	assert.Error(t, p.Next())
+}
+
+func TestCharacter(t *testing.T) {
+	p := SaxParser{Input: "t 'a' '\\''"}
+	p.Next()
+
+	assert.NoError(t, p.Next())
+	assert.True(t, p.IsChar())
+	assert.Equal(t, "a", p.Text())
+	assert.NoError(t, p.Next())
+	assert.True(t, p.IsChar())
+	assert.Equal(t, "'", p.Text())
+
+	p = SaxParser{Input: "t 'ab'"}
+	p.Next()
+	assert.Error(t, p.Next())
+}
+
+func TestSemicolon(t *testing.T) {
+	p := SaxParser{Input: "a; b"}
+
+	assert.NoError(t, p.Next())
+	assert.True(t, p.IsTagName())
+	assert.NoError(t, p.Next())
+	assert.True(t, p.IsNewLine())
+	assert.NoError(t, p.Next())
+	assert.True(t, p.IsTagName())
+	assert.Equal(t, "b", p.Text())
+}
+
+func TestUnicodeIdentifier(t *testing.T) {
+	p := SaxParser{Input: "prénom 名前=1 ns:ключ"}
+
+	assert.NoError(t, p.Next())
+	assert.True(t, p.IsTagName())
+	assert.Equal(t, "prénom", p.Text())
+	assert.NoError(t, p.Next())
+	assert.True(t, p.IsAttributeName())
+	assert.Equal(t, "名前", p.Text())
+	assert.NoError(t, p.Next())
+	assert.True(t, p.IsInteger())
+	assert.Error(t, p.Next())
+}
+
+func TestInvalidUTF8(t *testing.T) {
+	p := SaxParser{Input: "a \"ok\"\nb \"\xff\""}
+	err := p.Next()
+
+	var sdlErr *SdlError
+	assert.True(t, errors.As(err, &sdlErr))
+	assert.Equal(t, 2, sdlErr.Location.LineNumber)
+	assert.Equal(t, 3, sdlErr.Location.Loc)
+}
+
+func TestByteOrderMark(t *testing.T) {
+	p := SaxParser{Input: "\uFEFFtag"}
+
+	assert.NoError(t, p.Next())
+	assert.True(t, p.IsTagName())
+	assert.Equal(t, "tag", p.Text())
+}
+
+func TestColumnsAreCountedInRunes(t *testing.T) {
+	p := SaxParser{Input: "tëst \"ü\" @"}
+	p.Next()
+	p.Next()
+
+	var sdlErr *SdlError
+	assert.True(t, errors.As(p.Next(), &sdlErr))
+	assert.Equal(t, 9, sdlErr.Location.Loc)
+}
+
+func TestDoubleQuotedStringUnicodeEscape(t *testing.T) {
+	p := SaxParser{Input: `t "é😀" "\0\f\b\'" "\uD83D" "\uDE00" "\u12G4"`}
+	p.Next()
+
+	assert.NoError(t, p.Next())
+	assert.Equal(t, "é😀", p.Text())
+	assert.NoError(t, p.Next())
+	assert.Equal(t, "\x00\f\b'", p.Text())
+
+	var sdlErr *SdlError
+	assert.True(t, errors.As(p.Next(), &sdlErr))
+	assert.Equal(t, "Expected a \\u escape for the low surrogate following a high surrogate.", sdlErr.Message)
+	p.cursor = 30
+	assert.True(t, errors.As(p.Next(), &sdlErr))
+	assert.Equal(t, "Unexpected low surrogate without a preceding high surrogate.", sdlErr.Message)
+	p.cursor = 39
+	assert.True(t, errors.As(p.Next(), &sdlErr))
+	assert.Equal(t, "Expected exactly 4 hex digits following \\u.", sdlErr.Message)
+}
+
+func TestLocatingIsLinear(t *testing.T) {
+	inputs := map[string]string{
+		"long line":     strings.Repeat("a 1 x=2;", 10000),
+		"many lines":    strings.Repeat("a 1 x=2\n", 10000),
+		"nested tags":   "a {\n" + strings.Repeat("\tb 1 {\n\t\tc 2\n\t}\n", 5000) + "}\n",
+		"long children": strings.Repeat("a {\n"+strings.Repeat("\tb 1\n", 100)+"}\n", 100),
+	}
+	for name, input := range inputs {
+		p := SaxParser{Input: input}
+		b := astBuilder{parser: &p, stack: []SdlTag{{}}}
+		assert.NoError(t, p.Parse(&b), name)
+		// Each token's span and each line's text is scanned about once, whereas rescanning would scan the input many times over.
+		assert.Less(t, p.scanned, 4*len(input), name)
+	}
+}
+
+func TestEscapedStringsAreLinear(t *testing.T) {
+	allocations := func(n int) float64 {
+		input := "a \"" + strings.Repeat(`\n`, n) + "\""
+		return testing.AllocsPerRun(5, func() {
+			_, err := SaxParser{Input: input}.ParseIntoAst()
+			assert.NoError(t, err)
+		})
+	}
+	// Growing the decoded text by appending to a string would allocate once per escape.
+	assert.Less(t, allocations(40000), allocations(10000)+10)
+}
+
+func BenchmarkParseLongLine(b *testing.B) {
+	input := strings.Repeat("a 1;", 40000)
+	b.SetBytes(int64(len(input)))
+	for i := 0; i < b.N; i++ {
+		_, err := SaxParser{Input: input, Options: DefaultParseOptions()}.ParseIntoAst()
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
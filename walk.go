@@ -0,0 +1,179 @@
+package sdlang
+
+// WalkAction tells Walk how to continue after calling a Visitor.
+type WalkAction int
+
+const (
+	// WalkContinue continues walking as normal.
+	WalkContinue WalkAction = iota
+
+	// WalkSkipChildren skips over the values, attributes, and children of the tag that was just entered.
+	// LeaveTag is still called for the tag. When returned from any other callback this acts like WalkContinue.
+	WalkSkipChildren
+
+	// WalkStop stops walking immediately. No further callbacks are made.
+	WalkStop
+)
+
+// Visitor is called by Walk for each tag, value, and attribute in a tree.
+type Visitor interface {
+	// EnterTag is called before any of the tag's values, attributes, or children are visited.
+	EnterTag(path *WalkPath, tag *SdlTag) WalkAction
+
+	// Value is called for each value of the tag at the end of `path`. The value may be modified in place.
+	Value(path *WalkPath, index int, value *SdlValue) WalkAction
+
+	// Attribute is called for each attribute, in order of qualified name, of the tag at the end of `path`.
+	// Changes made to the attribute's value are stored back into the tag.
+	Attribute(path *WalkPath, attr *SdlAttribute) WalkAction
+
+	// LeaveTag is called after all of the tag's values, attributes, and children have been visited.
+	LeaveTag(path *WalkPath, tag *SdlTag) WalkAction
+}
+
+// VisitorFuncs implements Visitor using optional functions, so that only the callbacks that are needed have to be provided.
+type VisitorFuncs struct {
+	OnEnterTag  func(path *WalkPath, tag *SdlTag) WalkAction
+	OnValue     func(path *WalkPath, index int, value *SdlValue) WalkAction
+	OnAttribute func(path *WalkPath, attr *SdlAttribute) WalkAction
+	OnLeaveTag  func(path *WalkPath, tag *SdlTag) WalkAction
+}
+
+func (v VisitorFuncs) EnterTag(path *WalkPath, tag *SdlTag) WalkAction {
+	if v.OnEnterTag == nil {
+		return WalkContinue
+	}
+	return v.OnEnterTag(path, tag)
+}
+func (v VisitorFuncs) Value(path *WalkPath, index int, value *SdlValue) WalkAction {
+	if v.OnValue == nil {
+		return WalkContinue
+	}
+	return v.OnValue(path, index, value)
+}
+func (v VisitorFuncs) Attribute(path *WalkPath, attr *SdlAttribute) WalkAction {
+	if v.OnAttribute == nil {
+		return WalkContinue
+	}
+	return v.OnAttribute(path, attr)
+}
+func (v VisitorFuncs) LeaveTag(path *WalkPath, tag *SdlTag) WalkAction {
+	if v.OnLeaveTag == nil {
+		return WalkContinue
+	}
+	return v.OnLeaveTag(path, tag)
+}
+
+// WalkPath is the path from the tag passed to Walk, down to (and including) the tag currently being visited.
+type WalkPath struct {
+	tags    []*SdlTag
+	indices []int
+}
+
+// Tags returns every tag on the path, starting with the tag passed to Walk. The slice must not be modified.
+func (p *WalkPath) Tags() []*SdlTag {
+	return p.tags
+}
+
+// Depth is how many tags deep the current tag is, where the tag passed to Walk has a depth of 0.
+func (p *WalkPath) Depth() int {
+	return len(p.tags) - 1
+}
+
+// Tag returns the tag currently being visited.
+func (p *WalkPath) Tag() *SdlTag {
+	return p.tags[len(p.tags)-1]
+}
+
+// Parent returns the parent of the tag currently being visited, or nil if it is the tag passed to Walk.
+func (p *WalkPath) Parent() *SdlTag {
+	if len(p.tags) < 2 {
+		return nil
+	}
+	return p.tags[len(p.tags)-2]
+}
+
+// Index returns the index of the tag currently being visited within its parent's children, or -1 if it has no parent.
+func (p *WalkPath) Index() int {
+	return p.indices[len(p.indices)-1]
+}
+
+// String formats the path in the same way as Change.Path, e.g. "servers/server[1]".
+func (p *WalkPath) String() string {
+	path := ""
+	for i := 1; i < len(p.tags); i++ {
+		path = joinTagPath(path, p.tags[i-1], p.indices[i])
+	}
+	return path
+}
+
+// Walk visits `tag` and all of its descendants depth-first, calling `v` for each tag, value, and attribute.
+// Children may be added or removed from a tag while it is being walked. They're visited by index, and the amount of
+// children is checked again before visiting each one, so a child appended while its earlier siblings are being walked,
+// e.g. by their LeaveTag callback, is visited too. Removing a child before the next one to be visited makes it skip one.
+// Other than in LeaveTag, callbacks shouldn't change the children of the tag's ancestors, as that can move the tags
+// being walked. Walk does not recurse, so arbitrarily deep trees can be walked.
+// Walk returns false if it was stopped early by WalkStop.
+func Walk(tag *SdlTag, v Visitor) bool {
+	type frame struct {
+		nextChild int
+		entered   bool
+	}
+
+	path := &WalkPath{tags: []*SdlTag{tag}, indices: []int{-1}}
+	frames := []frame{{}}
+	for len(frames) > 0 {
+		top := &frames[len(frames)-1]
+		current := path.Tag()
+
+		if !top.entered {
+			top.entered = true
+			action := v.EnterTag(path, current)
+			if action == WalkStop {
+				return false
+			} else if action == WalkSkipChildren {
+				top.nextChild = -1
+			} else if !walkValuesAndAttributes(path, current, v) {
+				return false
+			}
+		}
+
+		if top.nextChild >= 0 && top.nextChild < len(current.Children) {
+			index := top.nextChild
+			top.nextChild++
+			path.tags = append(path.tags, &current.Children[index])
+			path.indices = append(path.indices, index)
+			frames = append(frames, frame{})
+			continue
+		}
+
+		if v.LeaveTag(path, current) == WalkStop {
+			return false
+		}
+		path.tags = path.tags[:len(path.tags)-1]
+		path.indices = path.indices[:len(path.indices)-1]
+		frames = frames[:len(frames)-1]
+	}
+	return true
+}
+
+func walkValuesAndAttributes(path *WalkPath, tag *SdlTag, v Visitor) bool {
+	for i := 0; i < len(tag.Values); i++ {
+		if v.Value(path, i, &tag.Values[i]) == WalkStop {
+			return false
+		}
+	}
+
+	for _, key := range tag.sortedAttributeKeys() {
+		attr, exists := tag.Attributes[key]
+		if !exists {
+			continue // Removed by a previous callback.
+		}
+		action := v.Attribute(path, &attr)
+		tag.Attributes[key] = attr
+		if action == WalkStop {
+			return false
+		}
+	}
+	return true
+}
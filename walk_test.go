@@ -0,0 +1,136 @@
+package sdlang
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingVisitor struct {
+	events []string
+}
+
+func (r *recordingVisitor) EnterTag(path *WalkPath, tag *SdlTag) WalkAction {
+	r.events = append(r.events, "enter "+path.String())
+	if tag.Name == "skip" {
+		return WalkSkipChildren
+	}
+	return WalkContinue
+}
+func (r *recordingVisitor) Value(path *WalkPath, index int, value *SdlValue) WalkAction {
+	r.events = append(r.events, "value "+value.literal())
+	return WalkContinue
+}
+func (r *recordingVisitor) Attribute(path *WalkPath, attr *SdlAttribute) WalkAction {
+	r.events = append(r.events, "attr "+attr.QualifiedName)
+	return WalkContinue
+}
+func (r *recordingVisitor) LeaveTag(path *WalkPath, tag *SdlTag) WalkAction {
+	r.events = append(r.events, "leave "+path.String())
+	return WalkContinue
+}
+
+func TestWalk(t *testing.T) {
+	ast := parseForTest(t, "", "a 1 y=2 x=3 {\n\tb\n\tb 2\n}\nskip 1 {\n\tc\n}\n")
+
+	var r recordingVisitor
+	assert.True(t, Walk(&ast, &r))
+	assert.Equal(t, strings.Join([]string{
+		"enter ",
+		"enter a",
+		"value 1",
+		"attr x",
+		"attr y",
+		"enter a/b",
+		"leave a/b",
+		"enter a/b[1]",
+		"value 2",
+		"leave a/b[1]",
+		"leave a",
+		"enter skip",
+		"leave skip",
+		"leave ",
+	}, "\n"), strings.Join(r.events, "\n"))
+}
+
+func TestWalkStop(t *testing.T) {
+	ast := parseForTest(t, "", "a {\n\tb {\n\t\tstop\n\t\tnever\n\t}\n}\nnever\n")
+
+	var visited []string
+	completed := Walk(&ast, VisitorFuncs{
+		OnEnterTag: func(path *WalkPath, tag *SdlTag) WalkAction {
+			visited = append(visited, tag.Name)
+			if tag.Name == "stop" {
+				assert.Equal(t, 3, path.Depth())
+				assert.Equal(t, "b", path.Parent().Name)
+				assert.Equal(t, 0, path.Index())
+				assert.Equal(t, 4, len(path.Tags()))
+				return WalkStop
+			}
+			return WalkContinue
+		},
+	})
+	assert.False(t, completed)
+	assert.Equal(t, []string{"", "a", "b", "stop"}, visited)
+}
+
+func TestWalkAppendedChildren(t *testing.T) {
+	ast := parseForTest(t, "", "a {\n\tb\n}\n")
+
+	var visited []string
+	Walk(&ast, VisitorFuncs{
+		OnEnterTag: func(path *WalkPath, tag *SdlTag) WalkAction {
+			visited = append(visited, tag.Name)
+			return WalkContinue
+		},
+		OnLeaveTag: func(path *WalkPath, tag *SdlTag) WalkAction {
+			if tag.Name == "b" {
+				path.Parent().AddChild(NewTag("", "c"))
+			}
+			return WalkContinue
+		},
+	})
+	assert.Equal(t, []string{"", "a", "b", "c"}, visited)
+}
+
+func TestWalkModify(t *testing.T) {
+	ast := parseForTest(t, "", "a 1 x=1 {\n\tremove\n\tb 2\n}\n")
+
+	Walk(&ast, VisitorFuncs{
+		OnEnterTag: func(path *WalkPath, tag *SdlTag) WalkAction {
+			tag.RemoveChildrenByName("remove")
+			return WalkContinue
+		},
+		OnValue: func(path *WalkPath, index int, value *SdlValue) WalkAction {
+			i, _ := value.Int()
+			*value = Int(i * 10)
+			return WalkContinue
+		},
+		OnAttribute: func(path *WalkPath, attr *SdlAttribute) WalkAction {
+			attr.Value = String("changed")
+			return WalkContinue
+		},
+	})
+
+	expected := parseForTest(t, "", "a 10 x=\"changed\" {\n\tb 20\n}\n")
+	assert.True(t, expected.Equal(ast), FormatChanges(Diff(&expected, &ast)))
+}
+
+func TestWalkDeep(t *testing.T) {
+	root := NewTag("", "root")
+	current := &root
+	for i := 0; i < 100000; i++ {
+		current.AddChild(NewTag("", "child"))
+		current = &current.Children[0]
+	}
+
+	depth := 0
+	Walk(&root, VisitorFuncs{
+		OnEnterTag: func(path *WalkPath, tag *SdlTag) WalkAction {
+			depth = path.Depth()
+			return WalkContinue
+		},
+	})
+	assert.Equal(t, 100000, depth)
+}
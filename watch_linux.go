@@ -0,0 +1,130 @@
+package sdlang
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// The events that mean a file may have new contents. Directories are watched rather than the files themselves,
+// as editors often save a file by writing a new one and renaming it over the original.
+const watchMask = syscall.IN_CLOSE_WRITE | syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO
+
+// fileWatcher sends to `changes` when any of the watched files change, using inotify.
+type fileWatcher struct {
+	fd      int
+	file    *os.File
+	changes chan struct{}
+
+	mu    sync.Mutex
+	dirs  map[int32]string // Watch descriptor -> directory.
+	wds   map[string]int32 // Directory -> watch descriptor.
+	files map[string]bool
+}
+
+func newFileWatcher() (*fileWatcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+
+	w := &fileWatcher{
+		fd: fd,
+		// As the descriptor is non-blocking, reads go through the runtime's poller and can be interrupted by Close.
+		file:    os.NewFile(uintptr(fd), "inotify"),
+		changes: make(chan struct{}, 1),
+		dirs:    map[int32]string{},
+		wds:     map[string]int32{},
+	}
+	go w.read()
+	return w, nil
+}
+
+// watch replaces the set of watched files.
+func (w *fileWatcher) watch(files []string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.files = map[string]bool{}
+	dirs := map[string]bool{}
+	for _, file := range files {
+		file = filepath.FromSlash(file)
+		w.files[file] = true
+		dirs[filepath.Dir(file)] = true
+	}
+
+	for dir := range dirs {
+		if _, exists := w.wds[dir]; exists {
+			continue
+		}
+		wd, err := syscall.InotifyAddWatch(w.fd, dir, watchMask)
+		if err == syscall.ENOENT {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("could not watch %s: %w", dir, err)
+		}
+		w.dirs[int32(wd)] = dir
+		w.wds[dir] = int32(wd)
+	}
+	for dir, wd := range w.wds {
+		if !dirs[dir] {
+			syscall.InotifyRmWatch(w.fd, uint32(wd))
+			delete(w.wds, dir)
+			delete(w.dirs, wd)
+		}
+	}
+	return nil
+}
+
+func (w *fileWatcher) read() {
+	defer close(w.changes)
+
+	buffer := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := w.file.Read(buffer)
+		if err != nil {
+			return
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buffer[offset]))
+			start := offset + syscall.SizeofInotifyEvent
+			offset = start + int(event.Len)
+			if offset > n {
+				break
+			}
+
+			if w.matches(event.Wd, event.Mask, string(bytes.TrimRight(buffer[start:offset], "\x00"))) {
+				select {
+				case w.changes <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}
+}
+
+func (w *fileWatcher) matches(wd int32, mask uint32, name string) bool {
+	if mask&syscall.IN_Q_OVERFLOW != 0 {
+		// Some events were lost, so any of the files could have changed.
+		return true
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	dir, exists := w.dirs[wd]
+	if mask&syscall.IN_IGNORED != 0 && exists {
+		delete(w.dirs, wd)
+		delete(w.wds, dir)
+		return false
+	}
+	return exists && w.files[filepath.Join(dir, name)]
+}
+
+func (w *fileWatcher) close() error {
+	return w.file.Close()
+}
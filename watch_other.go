@@ -0,0 +1,22 @@
+//go:build !linux
+// +build !linux
+
+package sdlang
+
+import "errors"
+
+type fileWatcher struct {
+	changes chan struct{}
+}
+
+func newFileWatcher() (*fileWatcher, error) {
+	return nil, errors.New("watching files is only supported on Linux")
+}
+
+func (w *fileWatcher) watch(files []string) error {
+	return nil
+}
+
+func (w *fileWatcher) close() error {
+	return nil
+}